@@ -1,10 +1,11 @@
 package main
 
 import (
-	"errors"
 	"fmt"
+	"math/rand"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Agent struct {
@@ -17,9 +18,41 @@ type Agent struct {
 	dirtCleaned   int
 	tilesMoved    int
 	logs          []string
+	stepLogs      []int // index into logs of the log of every step
+	startX        int
+	startY        int
+	startBattery  int
+	steps         []Step
+	capacity      int // battery a dock charges up to
+	chargeRate    int
+	mustEndOnDock bool
+	terrain       [][][2]int // movement and vacuuming multipliers per tile, nil for plain floor
+	sensorRadius  int        // 0 when the whole map is known
+	known         [][]bool   // tiles seen so far, only with a sensor radius
+	discovered    int
+	env           *environment            // regrowth, events and horizon, nil for a static map
+	moveSuccess   float64                 // chance a move goes where it was meant to, 1 on a plain floor
+	random        *rand.Rand              // rolls the slips, nil on a plain floor
+	occupied      func(x int, y int) bool // tiles taken by other robots of a fleet, a slip cannot end there
+	dirt          *dirtLedger             // dirt left on the map, shared like the tiles by the agents of a fleet
+	nearest       *travelCosts            // search arrays findNearestValuable keeps between calls
+	stepLimit     int                     // actions the run may take, 0 for no limit
+	deadline      time.Time               // the agent stops acting once it passed, the zero time for never
+	stopReason    string                  // TERMINATION_ reason a planner gave up for, empty when it did not
+	explain       bool                    // record the planner's decisions
+	decisions     []Decision
 }
 
-func CreateAgent(initialState InitialState) (Agent, error) {
+const STEP_LIMIT_PER_TILE = 10       // Default step limit per tile of the map
+const STEP_LIMIT_MIN = 100_000       // Smallest default step limit
+const STALL_ITERATIONS_PER_TILE = 10 // Planner iterations per tile without progress that count as stalled
+const STALL_ITERATIONS_MIN = 1000
+
+func CreateAgent(initialState InitialState, options RunOptions) (Agent, error) {
+	if err := validationError(ValidateInitialState(initialState)); err != nil {
+		return Agent{}, err
+	}
+
 	// convert tiles from strings to integers
 	tiles := [][]int{}
 	for y, row := range initialState.Tiles {
@@ -27,14 +60,27 @@ func CreateAgent(initialState InitialState) (Agent, error) {
 		for x, tile := range row {
 			val, err := strconv.Atoi(strings.TrimSpace(tile))
 			if err != nil {
-				return Agent{}, errors.New(fmt.Sprintf("Error parsing tile at (%d, %d): %v", x, y, err))
+				return Agent{}, fmt.Errorf("Error parsing tile at (%d, %d): %v", x, y, err)
 			}
 
 			tiles[y][x] = val
 		}
 	}
 
-	return Agent{
+	var terrain [][][2]int
+	for y, row := range initialState.Terrain {
+		terrain = append(terrain, make([][2]int, len(row)))
+		for x, cell := range row {
+			multipliers, err := parseTerrain(cell)
+			if err != nil {
+				return Agent{}, fmt.Errorf("Error parsing terrain at (%d, %d): %v", x, y, err)
+			}
+
+			terrain[y][x] = multipliers
+		}
+	}
+
+	agent := Agent{
 		posX:          initialState.X0,
 		posY:          initialState.Y0,
 		tiles:         tiles,
@@ -44,7 +90,109 @@ func CreateAgent(initialState InitialState) (Agent, error) {
 		dirtCleaned:   0,
 		tilesMoved:    0,
 		logs:          []string{},
-	}, nil
+		startX:        initialState.X0,
+		startY:        initialState.Y0,
+		startBattery:  initialState.Battery,
+		steps:         []Step{},
+		capacity:      initialState.Battery,
+		chargeRate:    initialState.ChargeRate,
+		mustEndOnDock: initialState.MustEndOnDock,
+		terrain:       terrain,
+		sensorRadius:  initialState.SensorRadius,
+		moveSuccess:   1,
+		stepLimit:     options.StepLimit,
+		deadline:      options.Deadline,
+		explain:       options.Explain,
+	}
+	if agent.stepLimit == 0 {
+		agent.stepLimit = STEP_LIMIT_PER_TILE * agent.totalTiles()
+		if agent.stepLimit < STEP_LIMIT_MIN {
+			agent.stepLimit = STEP_LIMIT_MIN
+		}
+		if agent.stepLimit < initialState.Horizon {
+			agent.stepLimit = initialState.Horizon
+		}
+	} else if agent.stepLimit < 0 {
+		agent.stepLimit = 0
+	}
+
+	agent.dirt = newDirtLedger(tiles)
+
+	if initialState.MoveSuccess > 0 && initialState.MoveSuccess < 1 {
+		agent.moveSuccess = initialState.MoveSuccess
+		agent.random = rand.New(rand.NewSource(options.Seed))
+	}
+
+	if initialState.Regrowth != nil || len(initialState.Events) > 0 || initialState.Horizon > 0 {
+		env, err := newEnvironment(initialState)
+		if err != nil {
+			return Agent{}, err
+		}
+		agent.env = env
+		agent.env.dirt, agent.env.dirtOverTime = agent.dirt, agent.dirt.total
+	}
+
+	if agent.sensorRadius > 0 {
+		agent.known = make([][]bool, len(tiles))
+		for y := range tiles {
+			agent.known[y] = make([]bool, len(tiles[y]))
+		}
+		agent.sense()
+	}
+
+	return agent, nil
+}
+
+// Marks the tiles within the sensor radius as known
+func (agent *Agent) sense() {
+	if agent.known == nil {
+		return
+	}
+
+	for dy := -agent.sensorRadius; dy <= agent.sensorRadius; dy++ {
+		for dx := -agent.sensorRadius; dx <= agent.sensorRadius; dx++ {
+			x, y := agent.posX+dx, agent.posY+dy
+			if sign(dx)*dx+sign(dy)*dy > agent.sensorRadius || y < 0 || y >= len(agent.known) || x < 0 || x >= len(agent.known[y]) {
+				continue
+			}
+			if !agent.known[y][x] {
+				agent.known[y][x] = true
+				agent.discovered++
+			}
+		}
+	}
+}
+
+// Whether the agent has seen (x, y), always true without a sensor radius
+func (agent *Agent) knows(x int, y int) bool {
+	if agent.known == nil {
+		return true
+	}
+	return y >= 0 && y < len(agent.known) && x >= 0 && x < len(agent.known[y]) && agent.known[y][x]
+}
+
+// Copy of the agent whose tiles are its belief: tiles it has not seen yet are walls
+func (agent *Agent) beliefView() Agent {
+	view := *agent
+	view.tiles = make([][]int, len(agent.tiles))
+	for y, row := range agent.tiles {
+		view.tiles[y] = make([]int, len(row))
+		for x, tile := range row {
+			view.tiles[y][x] = WALL_VALUE
+			if agent.knows(x, y) {
+				view.tiles[y][x] = tile
+			}
+		}
+	}
+	return view
+}
+
+// Tiles per row, all rows are as long as the first
+func (agent *Agent) gridWidth() int {
+	if len(agent.tiles) == 0 {
+		return 0
+	}
+	return len(agent.tiles[0])
 }
 
 func (agent Agent) getTileValue(x int, y int) int {
@@ -56,22 +204,198 @@ func (agent Agent) getTileValue(x int, y int) int {
 	}
 }
 
-func (agent *Agent) printStatistics() {
-	fmt.Printf("Dirt cleaned: %d\n", agent.dirtCleaned)
-	fmt.Printf("Tiles moved: %d\n", agent.tilesMoved)
-	fmt.Printf("Battery remaining: %d\n", agent.battery)
+// Battery needed to step onto (x, y)
+func (agent Agent) moveCostTo(x int, y int) int {
+	if y >= 0 && y < len(agent.terrain) && x >= 0 && x < len(agent.terrain[y]) {
+		return agent.movementCost * agent.terrain[y][x][0]
+	}
+	return agent.movementCost
+}
+
+// Battery needed to vacuum (x, y)
+func (agent Agent) vacuumCostAt(x int, y int) int {
+	if y >= 0 && y < len(agent.terrain) && x >= 0 && x < len(agent.terrain[y]) {
+		return agent.vacuumingCost * agent.terrain[y][x][1]
+	}
+	return agent.vacuumingCost
 }
 
-func (agent *Agent) allTilesCleaned() bool {
+// Collects what the agent did so far into a PlanResult
+func (agent *Agent) result() PlanResult {
+	actions := []Action{}
+	for _, step := range agent.steps {
+		actions = append(actions, step.Action)
+	}
+
+	result := PlanResult{
+		StartX:        agent.startX,
+		StartY:        agent.startY,
+		Battery:       agent.startBattery,
+		Actions:       actions,
+		Steps:         agent.steps,
+		DirtCleaned:   agent.dirtCleaned,
+		TilesMoved:    agent.tilesMoved,
+		BatteryLeft:   agent.battery,
+		MustEndOnDock: agent.mustEndOnDock,
+		EndedOnDock:   agent.onDock(),
+		SensorRadius:  agent.sensorRadius,
+		Discovered:    agent.discovered,
+		TotalTiles:    agent.totalTiles(),
+		Termination:   agent.termination(),
+		Logs:          agent.logs,
+		StepLogs:      agent.stepLogs,
+		Decisions:     agent.decisions,
+	}
+	if agent.env != nil {
+		result.Dynamic = true
+		result.Horizon, result.Turns, result.DirtAppeared = agent.env.horizon, agent.env.turn, agent.env.appeared
+		result.AverageDirt = agent.env.averageDirt()
+	}
+
+	return result
+}
+
+// Appends a step with its log line and lets the environment advance by one turn
+func (agent *Agent) record(step Step, log string) {
+	agent.steps = append(agent.steps, step)
+	agent.stepLogs = append(agent.stepLogs, len(agent.logs))
+	agent.logs = append(agent.logs, log)
+	if agent.env != nil {
+		agent.logs = append(agent.logs, agent.env.advance(agent.tiles)...)
+	}
+}
+
+// Whether the horizon of a dynamic map is over, the step limit reached or the deadline passed, the agent
+// cannot act any more
+func (agent *Agent) outOfTime() bool {
+	return agent.env != nil && agent.env.over() || agent.stepLimit > 0 && len(agent.steps) >= agent.stepLimit ||
+		agent.pastDeadline()
+}
+
+// Whether the planner ran past the deadline of the run, searches check it too so they end soon after
+func (agent *Agent) pastDeadline() bool {
+	return !agent.deadline.IsZero() && time.Now().After(agent.deadline)
+}
+
+// Why the run ended: the reason the planner gave up for, or else what the agent sees on the map
+func (agent *Agent) termination() string {
+	switch {
+	case agent.stopReason != "":
+		return agent.stopReason
+	case agent.dirt.total == 0:
+		return TERMINATION_CLEAN
+	case agent.pastDeadline():
+		return TERMINATION_DEADLINE
+	case agent.stepLimit > 0 && len(agent.steps) >= agent.stepLimit:
+		return TERMINATION_STEP_CAP
+	case agent.env != nil && agent.env.over():
+		return TERMINATION_HORIZON
+	case len(reachableDirtyTiles(agent)) == 0:
+		return TERMINATION_UNREACHABLE
+	case agent.canAffordProgress():
+		return TERMINATION_STOPPED
+	}
+	return TERMINATION_BATTERY
+}
+
+// Whether the battery still pays for getting to a dirty tile and vacuuming it, or to a dock that would charge
+func (agent *Agent) canAffordProgress() bool {
+	route := dijkstraWithin(agent, [][2]int{{agent.posX, agent.posY}}, false, agent.battery, nil)
+	for _, i := range route.order {
+		x, y := route.position(i)
+		if dirtOf(agent.tiles[y][x]) > 0 && route.cost[i]+agent.vacuumCostAt(x, y) <= agent.battery ||
+			agent.tiles[y][x] == DOCK_VALUE && agent.battery < agent.capacity {
+			return true
+		}
+	}
+	return false
+}
+
+// Notices a planner loop that goes on without the battery or the dirt cleaned changing
+type stallDetector struct {
+	limit       int
+	idle        int
+	battery     int
+	dirtCleaned int
+}
+
+func newStallDetector(agent *Agent) *stallDetector {
+	limit := STALL_ITERATIONS_PER_TILE * agent.totalTiles()
+	if limit < STALL_ITERATIONS_MIN {
+		limit = STALL_ITERATIONS_MIN
+	}
+	return &stallDetector{limit: limit, battery: agent.battery, dirtCleaned: agent.dirtCleaned}
+}
+
+// Called once per loop iteration, true when the last limit iterations changed nothing.
+// The agent then stops with TERMINATION_STALLED.
+func (stall *stallDetector) stalled(agent *Agent) bool {
+	if agent.battery != stall.battery || agent.dirtCleaned != stall.dirtCleaned {
+		stall.idle, stall.battery, stall.dirtCleaned = 0, agent.battery, agent.dirtCleaned
+		return false
+	}
+
+	stall.idle++
+	if stall.idle < stall.limit {
+		return false
+	}
+	agent.stopReason = TERMINATION_STALLED
+	agent.logs = append(agent.logs, fmt.Sprintf("Stalled: nothing changed in %d iterations", stall.limit))
+	return true
+}
+
+func (agent *Agent) totalTiles() int {
+	total := 0
 	for _, row := range agent.tiles {
+		total += len(row)
+	}
+	return total
+}
+
+// Dirt on a tile with the given value, 0 for walls and docks
+func dirtOf(tile int) int {
+	if tile > 0 && tile < WALL_VALUE {
+		return tile
+	}
+	return 0
+}
+
+func (agent *Agent) allTilesCleaned() bool {
+	return agent.dirt.total == 0
+}
+
+// Dirt left on the map, kept up to date as tiles are vacuumed and dirt appears rather than scanning the grid
+type dirtLedger struct {
+	total int
+	tiles []int // number of tiles per amount of dirt
+	most  int   // highest amount of dirt on a single tile, 0 on a clean map
+}
+
+func newDirtLedger(tiles [][]int) *dirtLedger {
+	ledger := dirtLedger{tiles: make([]int, WALL_VALUE)}
+	for _, row := range tiles {
 		for _, tile := range row {
-			if tile > 0 && tile < 9001 {
-				return false
-			}
+			ledger.change(0, dirtOf(tile))
 		}
 	}
+	return &ledger
+}
 
-	return true
+// Records a tile going from before to after dirt
+func (ledger *dirtLedger) change(before int, after int) {
+	ledger.total += after - before
+	if before > 0 {
+		ledger.tiles[before]--
+	}
+	if after > 0 {
+		ledger.tiles[after]++
+	}
+	if after > ledger.most {
+		ledger.most = after
+	}
+	for ledger.most > 0 && ledger.tiles[ledger.most] == 0 {
+		ledger.most--
+	}
 }
 
 func (agent *Agent) currentTile() int {
@@ -94,17 +418,115 @@ func (agent *Agent) getDownMoveValue() int {
 	return agent.getTileValue(agent.posX, agent.posY+1)
 }
 
+// Moves by (x, y). On a slippery floor the move may end up sideways or in place instead,
+// it costs what the intended move costs either way.
 func (agent *Agent) moveBy(x int, y int) {
-	if agent.battery >= agent.movementCost {
-		agent.posX += x
-		agent.posY += y
-		agent.battery -= agent.movementCost
-		agent.tilesMoved += 1
+	cost := agent.moveCostTo(agent.posX+x, agent.posY+y)
+	if agent.battery >= cost && !agent.outOfTime() {
+		batteryBefore := agent.battery
+		dx, dy := agent.slide(x, y)
+		agent.posX += dx
+		agent.posY += dy
+		agent.battery -= cost
+		if dx != 0 || dy != 0 {
+			agent.tilesMoved += 1
+		}
+		agent.sense()
+
+		log := fmt.Sprintf("Moved to (%d, %d)", agent.posX, agent.posY)
+		if dx == 0 && dy == 0 {
+			log = fmt.Sprintf("Slipped and stayed at (%d, %d)", agent.posX, agent.posY)
+		} else if dx != x || dy != y {
+			log = fmt.Sprintf("Slipped to (%d, %d)", agent.posX, agent.posY)
+		}
+		agent.record(Step{Action: moveAction(x, y), X: agent.posX, Y: agent.posY,
+			BatteryBefore: batteryBefore, BatteryAfter: agent.battery}, log)
+	}
+}
+
+// Where a move by (x, y) really goes: as meant with the move success chance, otherwise sideways
+// either way or nowhere, equally likely. Sliding into a wall, another robot or off the grid stays in place.
+func (agent *Agent) slide(x int, y int) (int, int) {
+	if agent.random == nil {
+		return x, y
+	}
+
+	outcomes := moveOutcomes(x, y)
+	roll := agent.random.Float64()
+	if roll < agent.moveSuccess {
+		return x, y
+	}
+
+	slip := outcomes[1+int((roll-agent.moveSuccess)/(1-agent.moveSuccess)*3)%3]
+	nx, ny := agent.posX+slip[0], agent.posY+slip[1]
+	if agent.getTileValue(nx, ny) == WALL_VALUE || (agent.occupied != nil && agent.occupied(nx, ny)) {
+		return 0, 0
+	}
+	return slip[0], slip[1]
+}
+
+// Moves (dx, dy) a move by (x, y) can end as: the move itself, sideways left and right of it, and staying put
+func moveOutcomes(x int, y int) [4][2]int {
+	return [4][2]int{{x, y}, {y, -x}, {-y, x}, {0, 0}}
+}
+
+func moveAction(x int, y int) Action {
+	if x < 0 {
+		return ActionLeft
+	} else if x > 0 {
+		return ActionRight
+	} else if y < 0 {
+		return ActionUp
+	}
+	return ActionDown
+}
+
+// Grid offset of a move action, (0, 0) for vacuuming
+func actionDelta(action Action) (int, int) {
+	switch action {
+	case ActionLeft:
+		return -1, 0
+	case ActionRight:
+		return 1, 0
+	case ActionUp:
+		return 0, -1
+	case ActionDown:
+		return 0, 1
+	}
+	return 0, 0
+}
 
-		agent.logs = append(agent.logs, fmt.Sprintf("Moved to (%d, %d)", agent.posX, agent.posY))
+// Performs a single action with the usual rules (walls, battery)
+func (agent *Agent) perform(action Action) {
+	switch action {
+	case ActionLeft:
+		agent.moveLeft()
+	case ActionRight:
+		agent.moveRight()
+	case ActionUp:
+		agent.moveUp()
+	case ActionDown:
+		agent.moveDown()
+	case ActionVacuum:
+		agent.vacuumIfDirty()
+	case ActionCharge:
+		agent.charge()
+	case ActionWait:
+		agent.wait()
 	}
 }
 
+// Stays in place for a turn, e.g. to keep fleet robots in lockstep or to let dirt come back
+func (agent *Agent) wait() {
+	if agent.outOfTime() {
+		return
+	}
+
+	agent.record(Step{Action: ActionWait, X: agent.posX, Y: agent.posY,
+		BatteryBefore: agent.battery, BatteryAfter: agent.battery},
+		fmt.Sprintf("Waited at (%d, %d)", agent.posX, agent.posY))
+}
+
 func (agent *Agent) moveLeft() {
 	if agent.getLeftMoveValue() != WALL_VALUE {
 		agent.moveBy(-1, 0)
@@ -131,15 +553,60 @@ func (agent *Agent) moveDown() {
 
 func (agent *Agent) vacuumIfDirty() int {
 	dirtOnTile := agent.currentTile()
-	if dirtOnTile > 0 && dirtOnTile < 9001 && agent.battery >= agent.vacuumingCost {
-		agent.battery -= agent.vacuumingCost
+	cost := agent.vacuumCostAt(agent.posX, agent.posY)
+	if dirtOnTile > 0 && dirtOnTile < 9001 && agent.battery >= cost && !agent.outOfTime() {
+		agent.battery -= cost
 		agent.tiles[agent.posY][agent.posX] = 0
 		agent.dirtCleaned += dirtOnTile
-
-		agent.logs = append(agent.logs,
+		agent.dirt.change(dirtOnTile, 0)
+		agent.record(Step{Action: ActionVacuum, X: agent.posX, Y: agent.posY,
+			BatteryBefore: agent.battery + cost, BatteryAfter: agent.battery, DirtVacuumed: dirtOnTile},
 			fmt.Sprintf("Vacuumed tile at (%d, %d), cleaned (%d) dirt", agent.posX, agent.posY, dirtOnTile))
 		return dirtOnTile
 	}
 
 	return 0
 }
+
+// Positions (x, y) of all charging docks
+func (agent *Agent) docks() [][2]int {
+	docks := [][2]int{}
+	for y, row := range agent.tiles {
+		for x, tile := range row {
+			if tile == DOCK_VALUE {
+				docks = append(docks, [2]int{x, y})
+			}
+		}
+	}
+	return docks
+}
+
+// Error for the planners that never head for a dock, which would otherwise leave the docks unused
+// and may end anywhere
+func (agent *Agent) rejectDocks(algorithm string) error {
+	if agent.mustEndOnDock || len(agent.docks()) > 0 {
+		return fmt.Errorf("The %s algorithm plans no charging, it supports neither docks nor ending on a dock", algorithm)
+	}
+	return nil
+}
+
+func (agent *Agent) onDock() bool {
+	return agent.currentTile() == DOCK_VALUE
+}
+
+// Charges on a dock, by chargeRate or fully when it is 0. Takes a turn but no battery.
+func (agent *Agent) charge() int {
+	if !agent.onDock() || agent.battery >= agent.capacity || agent.outOfTime() {
+		return 0
+	}
+
+	batteryBefore := agent.battery
+	agent.battery = agent.capacity
+	if agent.chargeRate > 0 && batteryBefore+agent.chargeRate < agent.capacity {
+		agent.battery = batteryBefore + agent.chargeRate
+	}
+	agent.record(Step{Action: ActionCharge, X: agent.posX, Y: agent.posY,
+		BatteryBefore: batteryBefore, BatteryAfter: agent.battery},
+		fmt.Sprintf("Charged at (%d, %d) to (%d) battery", agent.posX, agent.posY, agent.battery))
+	return agent.battery - batteryBefore
+}
@@ -1,38 +1,71 @@
 package main
 
 import (
+	"container/heap"
 	"fmt"
-	"log"
 	"math/rand"
+	"time"
 )
 
+func init() {
+	RegisterPlanner(NewPlannerFunc("greedy",
+		"Moves to the dirtiest neighbor, random allowed move when nothing is dirty around",
+		FindAndTraverseGreedyPath))
+	RegisterPlanner(NewPlannerFunc("optimal",
+		"Greedy neighbor picks combined with BFS to the dirtiest tile within battery range",
+		FindAndTraverseOptimalPath))
+}
+
 // A bit dummy traversal algorithm that moves the agent in a greedy way.
 // It moves the agent to the closest most dirty cell and cleans it.
-func FindAndTraverseGreedyPath(initialState InitialState) {
-	agent, err := CreateAgent(initialState)
+// Its random moves come from the seed of the run, so the same seed gives the same run.
+func FindAndTraverseGreedyPath(initialState InitialState, options RunOptions) (PlanResult, error) {
+	agent, err := CreateAgent(initialState, options)
 	if err != nil {
-		log.Fatal(err)
+		return PlanResult{}, err
+	}
+	if err := agent.rejectDocks("greedy"); err != nil {
+		return PlanResult{}, err
 	}
 
 	agent.logs = append(agent.logs, fmt.Sprintf("Initial position: (%d, %d)", agent.posX, agent.posY))
 
+	random := rand.New(rand.NewSource(options.Seed))
 	allActions := []func(){agent.moveLeft, agent.moveRight, agent.moveUp, agent.moveDown}
 	bestAction := func() {}
 	noBestMoveDirectionIndex := 0
+	stall := newStallDetector(&agent)
+
+	for agent.battery > 0 && !agent.outOfTime() {
+		if stall.stalled(&agent) {
+			return agent.result(), nil
+		}
 
-	for agent.battery > 0 {
 		allActionWeights := []int{agent.getLeftMoveValue(), agent.getRightMoveValue(), agent.getUpMoveValue(), agent.getDownMoveValue()}
 		bestAction = nil
 		bestActionWeight := 0
 
+		bestActionCost := 0
+		neighbors := [][2]int{{agent.posX - 1, agent.posY}, {agent.posX + 1, agent.posY}, {agent.posX, agent.posY - 1}, {agent.posX, agent.posY + 1}}
+
+		// moves the battery cannot pay for are refused, they count as walls
+		for i := range allActionWeights {
+			if allActionWeights[i] != WALL_VALUE && agent.moveCostTo(neighbors[i][0], neighbors[i][1]) > agent.battery {
+				allActionWeights[i] = WALL_VALUE
+			}
+		}
+
 		for i, action := range allActions {
 			if allActionWeights[i] == WALL_VALUE {
 				continue
 			}
 
-			if allActionWeights[i] > bestActionWeight {
+			x, y := neighbors[i][0], neighbors[i][1]
+			cost := agent.moveCostTo(x, y) + agent.vacuumCostAt(x, y)
+			if dirtOf(allActionWeights[i]) > 0 && (bestAction == nil || betterValue(dirtOf(allActionWeights[i]), cost, bestActionWeight, bestActionCost)) {
 				bestAction = action
-				bestActionWeight = allActionWeights[i]
+				bestActionWeight = dirtOf(allActionWeights[i])
+				bestActionCost = cost
 			}
 		}
 
@@ -42,7 +75,7 @@ func FindAndTraverseGreedyPath(initialState InitialState) {
 			for i := 0; i < 100; i++ {
 				j := i
 				// to prevent going on loop, pick a random move 30% of the time
-				if rand.Float32() < 0.3 {
+				if random.Float32() < 0.3 {
 					j += 1
 				}
 
@@ -55,8 +88,8 @@ func FindAndTraverseGreedyPath(initialState InitialState) {
 		}
 
 		if bestAction == nil {
-			fmt.Println("No moves left")
-			return
+			agent.logs = append(agent.logs, "No moves left")
+			return agent.result(), nil
 		}
 
 		bestAction()
@@ -69,82 +102,79 @@ func FindAndTraverseGreedyPath(initialState InitialState) {
 	}
 
 	if !agent.allTilesCleaned() {
-		agent.logs = append(agent.logs, fmt.Sprintf("Battery depleted"))
+		agent.logs = append(agent.logs, fmt.Sprintf("Stopped: %s", agent.termination()))
 	}
 
-	if PRINT_MOVES {
-		for _, log := range agent.logs {
-			fmt.Println(log)
-		}
-	}
-
-	agent.printStatistics()
+	return agent.result(), nil
 }
 
-// BFS to find the nearest non-zero value (target).
-// Uses breadth-first search as described in
-// https://en.wikipedia.org/wiki/Breadth-first_search with some additions (e.g. distance tracking and path reconstruction).
-// This could be optimized code-wise.
-func findNearestValuable(agent *Agent) []func(*Agent) {
-	queue := Queue{}
-	visited := make(map[[2]int]bool)
-	distance := make(map[[2]int]int)
-	predecessor := make(map[[2]int][2]int)
+// Dijkstra search to find the most valuable non-zero tile (target) within battery range.
+// Uses Dijkstra's algorithm as described in
+// https://en.wikipedia.org/wiki/Dijkstra%27s_algorithm over the battery cost of entering tiles,
+// with uniform terrain it visits tiles in the same order as a breadth-first search.
+// The search stops at the battery and at the first tile with the most dirt left on the map, no tile after it wins.
+// Its arrays are kept with the agent for the next call. A non-nil decision gets the target and the tie-break.
+func findNearestValuable(agent *Agent, decision *Decision) []func(*Agent) {
+	if agent.nearest == nil || len(agent.nearest.cost) != agent.gridWidth()*len(agent.tiles) {
+		route := newTravelCosts(agent.gridWidth(), len(agent.tiles))
+		agent.nearest = &route
+	}
+	route := agent.nearest
+	route.reset()
 
-	start := [2]int{agent.posY, agent.posX}
-	queue.Enqueue(start)
-	visited[start] = true
-	distance[start] = 0
+	start := route.index(agent.posX, agent.posY)
+	dirtiest := func(x int, y int) bool {
+		return (x != agent.posX || y != agent.posY) && dirtOf(agent.tiles[y][x]) == agent.dirt.most
+	}
+	route.search(agent, [][2]int{{agent.posX, agent.posY}}, false, agent.battery, dirtiest)
 
-	var bestPos *[2]int
+	best := -1
 	bestValue := -1
 
-	// BFS traversal
-	for !queue.IsEmpty() {
-		curr, _ := queue.Dequeue()
-		y, x := curr[0], curr[1]
-		currDist := distance[curr]
-
-		if currDist > agent.battery {
-			continue
-		}
+	for _, curr := range route.order {
+		x, y := route.position(curr)
 
 		// Check if this cell has a non-zero value (excluding the start cell)
-		tileValue := agent.getTileValue(x, y)
-		if (y != start[0] || x != start[1]) && tileValue > 0 {
-			// Prioritize the highest value; if equal, prefer the closest
-			if tileValue > bestValue || (tileValue == bestValue && (bestPos == nil || currDist < distance[*bestPos])) {
+		tileValue := dirtOf(agent.getTileValue(x, y))
+		if curr != start && tileValue > 0 {
+			// Prioritize the highest value; if equal, prefer the cheapest
+			if tileValue > bestValue || (tileValue == bestValue && (best < 0 || route.cost[curr] < route.cost[best])) {
 				bestValue = tileValue
-				bestPos = &curr
-			}
-		}
-
-		// explore neighbors
-		directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
-		for _, dir := range directions {
-			ny, nx := y+dir[0], x+dir[1]
-			next := [2]int{ny, nx}
-
-			if agent.getTileValue(nx, ny) != WALL_VALUE && !visited[next] {
-				visited[next] = true
-				queue.Enqueue(next)
-				predecessor[next] = curr
-				distance[next] = currDist + agent.movementCost
+				best = curr
 			}
 		}
 	}
 
 	// no valid target was found, return nil
-	if bestPos == nil {
+	if best < 0 {
 		return nil
 	}
 
+	if decision != nil {
+		decision.TargetX, decision.TargetY = route.position(best)
+		decision.TargetDirt, decision.Cost = bestValue, route.cost[best]+agent.vacuumCostAt(decision.TargetX, decision.TargetY)
+		decision.TieBreak = "most dirt within battery"
+		if bestValue == agent.dirt.most {
+			decision.TieBreak = "most dirt on the map"
+		}
+		for _, curr := range route.order {
+			x, y := route.position(curr)
+			if curr == start || curr == best || dirtOf(agent.getTileValue(x, y)) != bestValue {
+				continue
+			}
+			if route.cost[curr] == route.cost[best] {
+				decision.TieBreak = "same dirt and battery, first reached"
+				break
+			}
+			decision.TieBreak = "same dirt, cheapest"
+		}
+	}
+
 	path := []func(*Agent){}
-	current := *bestPos
-	for current != start {
-		direction := [2]int{current[0] - predecessor[current][0], current[1] - predecessor[current][1]}
-		path = append(path, directionArrayToAction(direction))
-		current = predecessor[current]
+	for current := best; current != start; current = route.previous[current] {
+		x, y := route.position(current)
+		px, py := route.position(route.previous[current])
+		path = append(path, directionArrayToAction([2]int{y - py, x - px}))
 	}
 
 	// reverse
@@ -155,49 +185,94 @@ func findNearestValuable(agent *Agent) []func(*Agent) {
 	return path
 }
 
+// Whether dirt for cost battery beats bestDirt for bestCost: more dirt per battery, then more dirt
+func betterValue(dirt int, cost int, bestDirt int, bestCost int) bool {
+	return dirt*bestCost > bestDirt*cost || (dirt*bestCost == bestDirt*cost && dirt > bestDirt)
+}
+
 // FindAndTraverseOptimalPath finds the optimal path to clean all tiles by assuming task goals:
 // Primary Goal: Clean as much dirt as possible.
 // Secondary Goal: Clear (visit and clean) as many squares as possible.
 // It combines BFS to find the nearest valuable cell and greedy actions to clean the dirt around the agent.
-func FindAndTraverseOptimalPath(initialState InitialState) {
-	agent, err := CreateAgent(initialState)
+func FindAndTraverseOptimalPath(initialState InitialState, options RunOptions) (PlanResult, error) {
+	agent, err := CreateAgent(initialState, options)
 	if err != nil {
-		log.Fatal(err)
+		return PlanResult{}, err
 	}
+	if err := agent.rejectDocks("optimal"); err != nil {
+		return PlanResult{}, err
+	}
+	return traverseOptimalPath(&agent), nil
+}
 
+// FindAndTraverseOptimalPath on a map that may have docks, which it leaves unused
+func traverseOptimalPath(agent *Agent) PlanResult {
 	agent.vacuumIfDirty()
+	stall := newStallDetector(agent)
 
-	for agent.battery > 0 {
+	for agent.battery > 0 && !agent.outOfTime() && !stall.stalled(agent) {
 		var bestNext *[2]int
-		bestVal := -1
+		bestVal, bestCost := -1, 0
 		var bestAction func(*Agent)
+		decision := agent.newDecision()
 
-		// check adjacent cells acting greedy
+		// check adjacent cells acting greedy, the most dirt per battery among affordable moves
 		directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
 		for _, dir := range directions {
 			ny, nx := agent.posY+dir[0], agent.posX+dir[1]
 			tileValue := agent.getTileValue(nx, ny)
-			if tileValue != WALL_VALUE && tileValue > bestVal {
-				bestVal = tileValue
+			if decision != nil {
+				candidate := DecisionCandidate{Action: moveAction(dir[1], dir[0]), X: nx, Y: ny}
+				if tileValue == WALL_VALUE {
+					candidate.Skipped = "wall"
+				} else if agent.moveCostTo(nx, ny) > agent.battery {
+					candidate.Skipped = "battery"
+				} else {
+					candidate.Dirt, candidate.Cost = dirtOf(tileValue), agent.moveCostTo(nx, ny)+agent.vacuumCostAt(nx, ny)
+				}
+				decision.Candidates = append(decision.Candidates, candidate)
+			}
+			if tileValue == WALL_VALUE || agent.moveCostTo(nx, ny) > agent.battery {
+				continue
+			}
+
+			cost := agent.moveCostTo(nx, ny) + agent.vacuumCostAt(nx, ny)
+			if bestNext == nil || betterValue(dirtOf(tileValue), cost, bestVal, bestCost) {
+				bestVal, bestCost = dirtOf(tileValue), cost
 				bestNext = &[2]int{ny, nx}
 				bestAction = directionArrayToAction(dir)
 			}
 		}
 
 		if bestNext != nil && bestVal > 0 {
+			if decision != nil {
+				decision.Branch, decision.TargetX, decision.TargetY = DECISION_NEIGHBOR, bestNext[1], bestNext[0]
+				decision.TargetDirt, decision.Distance, decision.Cost = bestVal, 1, bestCost
+				decision.TieBreak = neighborTieBreak(decision.Candidates, moveAction(bestNext[1]-agent.posX, bestNext[0]-agent.posY))
+				agent.decide(decision)
+			}
+
 			// move to the best adjacent cell
-			bestAction(&agent)
+			bestAction(agent)
 			agent.vacuumIfDirty()
 
 		} else {
-			// if no good adjacent cell, use BFS to find the nearest valuable cell
-			pathToNode := findNearestValuable(&agent)
+			// if no good adjacent cell, search for the most valuable cell within battery range
+			pathToNode := findNearestValuable(agent, decision)
 			if pathToNode == nil {
+				if decision != nil {
+					decision.Branch = DECISION_STOP
+					agent.decide(decision)
+				}
 				break // no reachable non-zero tile, end
 			}
+			if decision != nil {
+				decision.Branch, decision.Distance = DECISION_SEARCH, len(pathToNode)
+				agent.decide(decision)
+			}
 
 			for _, pos := range pathToNode {
-				pos(&agent)
+				pos(agent)
 				agent.vacuumIfDirty()
 
 				if agent.battery <= 0 {
@@ -207,11 +282,322 @@ func FindAndTraverseOptimalPath(initialState InitialState) {
 		}
 	}
 
-	if PRINT_MOVES {
-		for _, log := range agent.logs {
-			fmt.Println(log)
+	return agent.result()
+}
+
+// BFS step distances from (x, y) to every tile of the grid, -1 for unreachable tiles.
+// Terrain is ignored, it is meant for reachability; see dijkstra for battery costs.
+func bfsDistances(agent *Agent, x int, y int) [][]int {
+	return bfsDistancesFrom(agent, [][2]int{{x, y}})
+}
+
+// BFS step distances to the closest of several (x, y) sources, e.g. all docks.
+// The rows share one flat array indexed by y*width+x.
+func bfsDistancesFrom(agent *Agent, sources [][2]int) [][]int {
+	width := agent.gridWidth()
+	flat := make([]int, width*len(agent.tiles))
+	for i := range flat {
+		flat[i] = -1
+	}
+
+	queue := Queue{}
+	for _, source := range sources {
+		x, y := source[0], source[1]
+		if agent.getTileValue(x, y) != WALL_VALUE && flat[y*width+x] == -1 {
+			queue.Enqueue(y*width + x)
+			flat[y*width+x] = 0
+		}
+	}
+
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for !queue.IsEmpty() {
+		curr, _ := queue.Dequeue()
+		y, x := curr/width, curr%width
+		for _, dir := range directions {
+			ny, nx := y+dir[0], x+dir[1]
+			if agent.getTileValue(nx, ny) != WALL_VALUE && flat[ny*width+nx] == -1 {
+				flat[ny*width+nx] = flat[curr] + 1
+				queue.Enqueue(ny*width + nx)
+			}
 		}
 	}
 
-	agent.printStatistics()
+	dist := make([][]int, len(agent.tiles))
+	for y := range dist {
+		dist[y] = flat[y*width : (y+1)*width]
+	}
+	return dist
+}
+
+// Cheapest paths between every tile and a set of sources, -1 for unreachable tiles.
+// Ties in battery cost are broken by the number of moves. Tiles are flat indexes y*width+x.
+type travelCosts struct {
+	width    int
+	cost     []int // battery
+	steps    []int // moves
+	previous []int // tile before this one on the path from a source
+	order    []int // reachable tiles in the order the search settled them
+	reached  []int // tiles the search gave a cost, settled or not
+}
+
+func (route travelCosts) index(x int, y int) int {
+	return y*route.width + x
+}
+
+// Position (x, y) of a flat index
+func (route travelCosts) position(i int) (int, int) {
+	return i % route.width, i / route.width
+}
+
+// Battery cost of the path between (x, y) and the sources, -1 when unreachable or off the grid
+func (route travelCosts) costAt(x int, y int) int {
+	if x < 0 || y < 0 || x >= route.width || route.index(x, y) >= len(route.cost) {
+		return -1
+	}
+	return route.cost[route.index(x, y)]
+}
+
+// Moves of the path between (x, y) and the sources, -1 off the grid
+func (route travelCosts) stepsAt(x int, y int) int {
+	if x < 0 || y < 0 || x >= route.width || route.index(x, y) >= len(route.steps) {
+		return -1
+	}
+	return route.steps[route.index(x, y)]
+}
+
+// Dijkstra's algorithm over the battery cost of entering tiles, from several (x, y) sources.
+// Costs depend on the direction of travel, with towards set they are of the paths from every tile
+// to the closest source (used to walk to a target), otherwise of the paths from the sources.
+func dijkstra(agent *Agent, sources [][2]int, towards bool) travelCosts {
+	return dijkstraWithin(agent, sources, towards, -1, nil)
+}
+
+// dijkstra that only reaches tiles costing at most limit battery (-1 for no limit), the others stay unreachable.
+// With stop set the search ends once it settled a tile (x, y) that stop is true for, the last one of route.order,
+// tiles not settled by then keep the costs found so far.
+func dijkstraWithin(agent *Agent, sources [][2]int, towards bool, limit int, stop func(x int, y int) bool) travelCosts {
+	route := newTravelCosts(agent.gridWidth(), len(agent.tiles))
+	route.search(agent, sources, towards, limit, stop)
+	return route
+}
+
+// Route over a width x height grid on which every tile is unreachable
+func newTravelCosts(width int, height int) travelCosts {
+	route := travelCosts{
+		width:    width,
+		cost:     make([]int, width*height),
+		steps:    make([]int, width*height),
+		previous: make([]int, width*height),
+	}
+	for i := range route.cost {
+		route.cost[i] = -1
+	}
+	return route
+}
+
+// Makes the tiles the last search reached unreachable again, so the arrays serve the next search
+// without clearing the whole grid
+func (route *travelCosts) reset() {
+	for _, i := range route.reached {
+		route.cost[i], route.steps[i], route.previous[i] = -1, 0, 0
+	}
+	route.reached, route.order = route.reached[:0], route.order[:0]
+}
+
+// Runs the search of dijkstraWithin on a route on which every tile is unreachable
+func (route *travelCosts) search(agent *Agent, sources [][2]int, towards bool, limit int, stop func(x int, y int) bool) {
+	// without terrain every move costs the same, so a breadth-first search settles the tiles in the
+	// order Dijkstra's algorithm does with its ties broken first in first out, in linear time
+	if agent.terrain == nil {
+		route.breadthFirst(agent, sources, limit, stop)
+		return
+	}
+
+	queue := PriorityQueue{}
+	seq := 0
+	for _, source := range sources {
+		i := route.index(source[0], source[1])
+		if agent.getTileValue(source[0], source[1]) != WALL_VALUE && route.cost[i] == -1 {
+			route.cost[i] = 0
+			route.previous[i] = i
+			route.reached = append(route.reached, i)
+			heap.Push(&queue, costItem{tile: i, seq: seq})
+			seq++
+		}
+	}
+
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for queue.Len() > 0 {
+		curr := heap.Pop(&queue).(costItem)
+		if curr.cost != route.cost[curr.tile] || curr.steps != route.steps[curr.tile] {
+			continue // a cheaper path was found after this one was queued
+		}
+		route.order = append(route.order, curr.tile)
+
+		x, y := route.position(curr.tile)
+		if stop != nil && stop(x, y) {
+			break
+		}
+		for _, dir := range directions {
+			ny, nx := y+dir[0], x+dir[1]
+			if agent.getTileValue(nx, ny) == WALL_VALUE {
+				continue
+			}
+
+			cost := curr.cost + agent.moveCostTo(nx, ny)
+			if towards {
+				cost = curr.cost + agent.moveCostTo(x, y)
+			}
+			if limit >= 0 && cost > limit {
+				continue
+			}
+			steps := curr.steps + 1
+			next := route.index(nx, ny)
+			known := route.cost[next]
+			if known != -1 && (known < cost || (known == cost && route.steps[next] <= steps)) {
+				continue
+			}
+
+			if known == -1 {
+				route.reached = append(route.reached, next)
+			}
+			route.cost[next], route.steps[next], route.previous[next] = cost, steps, curr.tile
+			heap.Push(&queue, costItem{tile: next, cost: cost, steps: steps, seq: seq})
+			seq++
+		}
+	}
+}
+
+// Fills the route with a breadth-first search, for grids where every move costs movementCost
+func (route *travelCosts) breadthFirst(agent *Agent, sources [][2]int, limit int, stop func(x int, y int) bool) {
+	queue := Queue{}
+	for _, source := range sources {
+		i := route.index(source[0], source[1])
+		if agent.getTileValue(source[0], source[1]) != WALL_VALUE && route.cost[i] == -1 {
+			route.cost[i] = 0
+			route.previous[i] = i
+			route.reached = append(route.reached, i)
+			queue.Enqueue(i)
+		}
+	}
+
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for !queue.IsEmpty() {
+		curr, _ := queue.Dequeue()
+		route.order = append(route.order, curr)
+
+		x, y := route.position(curr)
+		if stop != nil && stop(x, y) {
+			return
+		}
+		cost := route.cost[curr] + agent.movementCost
+		if limit >= 0 && cost > limit {
+			continue
+		}
+		for _, dir := range directions {
+			ny, nx := y+dir[0], x+dir[1]
+			if agent.getTileValue(nx, ny) == WALL_VALUE || route.cost[route.index(nx, ny)] != -1 {
+				continue
+			}
+
+			next := route.index(nx, ny)
+			route.cost[next], route.steps[next], route.previous[next] = cost, route.steps[curr]+1, curr
+			route.reached = append(route.reached, next)
+			queue.Enqueue(next)
+		}
+	}
+}
+
+// Dirty tile that planners working on a travel cost matrix can choose to vacuum
+type dirtyTile struct {
+	x          int
+	y          int
+	dirt       int
+	vacuumCost int
+}
+
+// Lists dirty tiles reachable from the agent position, in row order
+func reachableDirtyTiles(agent *Agent) []dirtyTile {
+	fromStart := bfsDistances(agent, agent.posX, agent.posY)
+
+	tiles := []dirtyTile{}
+	for y, row := range agent.tiles {
+		for x, tile := range row {
+			if tile > 0 && tile < WALL_VALUE && fromStart[y][x] >= 0 {
+				tiles = append(tiles, dirtyTile{x: x, y: y, dirt: tile, vacuumCost: agent.vacuumCostAt(x, y)})
+			}
+		}
+	}
+
+	return tiles
+}
+
+// All-pairs battery costs of moving between the given tiles (dist[i][j] from i to j), one search per tile
+func tileTravelCosts(agent *Agent, tiles []dirtyTile) [][]int {
+	dist, _ := tileTravelCostsUntil(agent, tiles, time.Time{})
+	return dist
+}
+
+// tileTravelCosts that gives up at the deadline (the zero time for none), false when it did
+func tileTravelCostsUntil(agent *Agent, tiles []dirtyTile, deadline time.Time) ([][]int, bool) {
+	dist := make([][]int, len(tiles))
+	for i := range tiles {
+		if i%1024 == 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, false // the table alone takes long on large maps
+		}
+		dist[i] = make([]int, len(tiles))
+	}
+
+	for j, to := range tiles {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, false
+		}
+		toTile := dijkstra(agent, [][2]int{{to.x, to.y}}, true)
+		for i, from := range tiles {
+			dist[i][j] = toTile.costAt(from.x, from.y)
+		}
+	}
+
+	return dist, true
+}
+
+// Cheapest paths from every tile to (x, y)
+func routeTo(agent *Agent, x int, y int) travelCosts {
+	return dijkstra(agent, [][2]int{{x, y}}, true)
+}
+
+// Direction [dy, dx] of the first move of a cheapest path along route (a dijkstra result computed
+// towards the target tiles), false when the agent is already there or cannot get there
+func nextStep(agent *Agent, route travelCosts) ([2]int, bool) {
+	x, y := agent.posX, agent.posY
+	if route.costAt(x, y) < 0 || route.stepsAt(x, y) == 0 {
+		return [2]int{}, false
+	}
+
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for _, dir := range directions {
+		ny, nx := y+dir[0], x+dir[1]
+		if agent.getTileValue(nx, ny) != WALL_VALUE && route.stepsAt(nx, ny) == route.stepsAt(x, y)-1 &&
+			route.costAt(nx, ny)+agent.moveCostTo(nx, ny) == route.costAt(x, y) {
+			return dir, true
+		}
+	}
+	return [2]int{}, false
+}
+
+// Walks the agent along a cheapest path to the target without vacuuming on the way.
+// route must be a dijkstra result computed towards the target tile(s).
+func walkTo(agent *Agent, route travelCosts) {
+	for {
+		dir, ok := nextStep(agent, route)
+		if !ok {
+			return
+		}
+
+		before := len(agent.steps)
+		directionArrayToAction(dir)(agent)
+		if len(agent.steps) == before {
+			return // not enough battery to move
+		}
+	}
 }
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+func init() {
+	registerCommand("analyze", "[--output=text|json] [--format=csv|json|ascii] [--limit=N] <input map>",
+		"Reports connected components, unreachable dirt, distances to dirty tiles, dead ends and bottleneck tiles",
+		runAnalyze)
+}
+
+// Connected area of floor, tiles that reach each other without crossing walls
+type MapComponent struct {
+	Tiles      int  `json:"tiles"`
+	DirtyTiles int  `json:"dirtyTiles"`
+	Dirt       int  `json:"dirt"`
+	Docks      int  `json:"docks"`
+	Start      bool `json:"start"` // the component the agent starts in
+}
+
+// Dirty tile and how far it is from the start, Steps and Battery are -1 when it cannot be reached
+type TileDistance struct {
+	X             int  `json:"x"`
+	Y             int  `json:"y"`
+	Dirt          int  `json:"dirt"`
+	Steps         int  `json:"steps"`
+	Battery       int  `json:"battery"` // cheapest battery cost of getting there
+	WithinBattery bool `json:"withinBattery"`
+}
+
+// Corridor that ends in a dead end, a way in that is also the only way out
+type DeadEnd struct {
+	X      int `json:"x"` // the dead end
+	Y      int `json:"y"`
+	MouthX int `json:"mouthX"` // the tile where the corridor joins the rest of the map, -1 when it does not
+	MouthY int `json:"mouthY"`
+	Length int `json:"length"` // tiles of the corridor, the mouth not counted
+	Dirt   int `json:"dirt"`
+}
+
+// Bottleneck tile, an articulation point: without it some tiles cannot be reached from the start
+type Bottleneck struct {
+	X        int  `json:"x"`
+	Y        int  `json:"y"`
+	CutTiles int  `json:"cutTiles"` // tiles only reached through it
+	CutDirt  int  `json:"cutDirt"`
+	Start    bool `json:"start"` // the start itself, it splits the map into parts the agent has to come back through
+}
+
+type MapAnalysis struct {
+	Width             int            `json:"width"`
+	Height            int            `json:"height"`
+	StartX            int            `json:"startX"`
+	StartY            int            `json:"startY"`
+	Battery           int            `json:"battery"`
+	Components        []MapComponent `json:"components"` // the start's component first, then by tiles
+	TotalDirt         int            `json:"totalDirt"`
+	ReachableDirt     int            `json:"reachableDirt"`
+	DirtWithinBattery int            `json:"dirtWithinBattery"` // reachable with the travel and vacuuming cost paid from the starting battery
+	DirtyTiles        []TileDistance `json:"dirtyTiles"`        // reachable ones by battery cost, then the unreachable ones
+	DeadEnds          []DeadEnd      `json:"deadEnds"`
+	Bottlenecks       []Bottleneck   `json:"bottlenecks"`
+}
+
+// AnalyzeMap reports which dirt can be reached at all and from the starting battery, and the shape of the
+// start's component: dead-end corridors and articulation points (found with Tarjan's algorithm,
+// https://en.wikipedia.org/wiki/Biconnected_component, rooted at the start so every bottleneck knows
+// the tiles and dirt behind it). Docks are not taken into account for the battery.
+func AnalyzeMap(initialState InitialState) (MapAnalysis, error) {
+	initialState.SensorRadius = 0
+	agent, err := CreateAgent(initialState, RunOptions{})
+	if err != nil {
+		return MapAnalysis{}, err
+	}
+
+	width := agent.gridWidth()
+	analysis := MapAnalysis{Width: width, Height: len(agent.tiles), StartX: agent.posX, StartY: agent.posY, Battery: agent.battery,
+		Components: []MapComponent{}, DirtyTiles: []TileDistance{}, DeadEnds: []DeadEnd{}, Bottlenecks: []Bottleneck{}}
+
+	walkable := func(x int, y int) bool {
+		return y >= 0 && y < len(agent.tiles) && x >= 0 && x < len(agent.tiles[y]) && agent.tiles[y][x] != WALL_VALUE
+	}
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+
+	// flood fill every component, the start's gets index 0
+	component := make([]int, width*len(agent.tiles))
+	for i := range component {
+		component[i] = -1
+	}
+	fill := func(x int, y int, start bool) {
+		area := MapComponent{Start: start}
+		label := len(analysis.Components)
+		queue := &Queue{}
+		queue.Enqueue(y*width + x)
+		component[y*width+x] = label
+		for !queue.IsEmpty() {
+			tile, _ := queue.Dequeue()
+			tx, ty := tile%width, tile/width
+			area.Tiles++
+			if dirt := dirtOf(agent.tiles[ty][tx]); dirt > 0 {
+				area.DirtyTiles++
+				area.Dirt += dirt
+			}
+			if agent.tiles[ty][tx] == DOCK_VALUE {
+				area.Docks++
+			}
+			for _, dir := range directions {
+				ny, nx := ty+dir[0], tx+dir[1]
+				if walkable(nx, ny) && component[ny*width+nx] < 0 {
+					component[ny*width+nx] = label
+					queue.Enqueue(ny*width + nx)
+				}
+			}
+		}
+		analysis.Components = append(analysis.Components, area)
+	}
+	fill(agent.posX, agent.posY, true)
+	for y, row := range agent.tiles {
+		for x := range row {
+			if walkable(x, y) && component[y*width+x] < 0 {
+				fill(x, y, false)
+			}
+		}
+	}
+	sort.SliceStable(analysis.Components[1:], func(a, b int) bool {
+		return analysis.Components[1+a].Tiles > analysis.Components[1+b].Tiles
+	})
+
+	steps := bfsDistances(&agent, agent.posX, agent.posY)
+	route := dijkstra(&agent, [][2]int{{agent.posX, agent.posY}}, false)
+	unreachable := []TileDistance{}
+	for y, row := range agent.tiles {
+		for x, tile := range row {
+			dirt := dirtOf(tile)
+			if dirt == 0 {
+				continue
+			}
+			analysis.TotalDirt += dirt
+			if steps[y][x] < 0 {
+				unreachable = append(unreachable, TileDistance{X: x, Y: y, Dirt: dirt, Steps: -1, Battery: -1})
+				continue
+			}
+
+			distance := TileDistance{X: x, Y: y, Dirt: dirt, Steps: steps[y][x], Battery: route.costAt(x, y)}
+			distance.WithinBattery = distance.Battery+agent.vacuumCostAt(x, y) <= agent.battery
+			analysis.ReachableDirt += dirt
+			if distance.WithinBattery {
+				analysis.DirtWithinBattery += dirt
+			}
+			analysis.DirtyTiles = append(analysis.DirtyTiles, distance)
+		}
+	}
+	sort.SliceStable(analysis.DirtyTiles, func(a, b int) bool {
+		if analysis.DirtyTiles[a].Battery != analysis.DirtyTiles[b].Battery {
+			return analysis.DirtyTiles[a].Battery < analysis.DirtyTiles[b].Battery
+		}
+		return analysis.DirtyTiles[a].Steps < analysis.DirtyTiles[b].Steps
+	})
+	analysis.DirtyTiles = append(analysis.DirtyTiles, unreachable...)
+
+	// dead ends of the start's component, followed along tiles with two neighbors up to a junction
+	degree := func(x int, y int) int {
+		count := 0
+		for _, dir := range directions {
+			if walkable(x+dir[1], y+dir[0]) {
+				count++
+			}
+		}
+		return count
+	}
+	for y, row := range agent.tiles {
+		for x := range row {
+			if !walkable(x, y) || component[y*width+x] != 0 || degree(x, y) != 1 {
+				continue
+			}
+
+			corridor := DeadEnd{X: x, Y: y, MouthX: -1, MouthY: -1}
+			px, py, cx, cy := -1, -1, x, y
+			for {
+				corridor.Length++
+				corridor.Dirt += dirtOf(agent.tiles[cy][cx])
+				nx, ny := -1, -1
+				for _, dir := range directions {
+					if ax, ay := cx+dir[1], cy+dir[0]; walkable(ax, ay) && (ax != px || ay != py) {
+						nx, ny = ax, ay
+					}
+				}
+				if nx < 0 {
+					break // a corridor with dead ends on both sides, the whole component
+				}
+				if degree(nx, ny) > 2 {
+					corridor.MouthX, corridor.MouthY = nx, ny
+					break
+				}
+				px, py, cx, cy = cx, cy, nx, ny
+			}
+			// a corridor with two dead ends is reported once, from the end that comes first
+			if corridor.MouthX >= 0 || cy*width+cx > y*width+x {
+				analysis.DeadEnds = append(analysis.DeadEnds, corridor)
+			}
+		}
+	}
+	sort.SliceStable(analysis.DeadEnds, func(a, b int) bool {
+		return analysis.DeadEnds[a].Dirt > analysis.DeadEnds[b].Dirt
+	})
+
+	analysis.Bottlenecks = articulationPoints(&agent, walkable, directions)
+	return analysis, nil
+}
+
+// Articulation points of the start's component with an iterative depth-first search, as maps can be too large
+// for recursion. A tile is one when a child's subtree has no edge back above it, that subtree is then cut off.
+func articulationPoints(agent *Agent, walkable func(x int, y int) bool, directions [][2]int) []Bottleneck {
+	width := agent.gridWidth()
+	size := width * len(agent.tiles)
+	discovered, low, parent := make([]int, size), make([]int, size), make([]int, size)
+	subtreeTiles, subtreeDirt := make([]int, size), make([]int, size)
+	cutTiles, cutDirt := map[int]int{}, map[int]int{}
+
+	type frame struct {
+		tile int
+		next int // index into directions of the next neighbor to look at
+	}
+	root := agent.posY*width + agent.posX
+	rootChildren := 0
+	order := 1
+	discovered[root], low[root], parent[root] = order, order, -1
+	subtreeTiles[root], subtreeDirt[root] = 1, dirtOf(agent.tiles[agent.posY][agent.posX])
+	stack := []frame{{tile: root}}
+
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		tile := top.tile
+		if top.next < len(directions) {
+			dir := directions[top.next]
+			top.next++
+			nx, ny := tile%width+dir[1], tile/width+dir[0]
+			if !walkable(nx, ny) {
+				continue
+			}
+			neighbor := ny*width + nx
+			if discovered[neighbor] == 0 {
+				order++
+				discovered[neighbor], low[neighbor], parent[neighbor] = order, order, tile
+				subtreeTiles[neighbor], subtreeDirt[neighbor] = 1, dirtOf(agent.tiles[ny][nx])
+				stack = append(stack, frame{tile: neighbor})
+			} else if neighbor != parent[tile] && discovered[neighbor] < low[tile] {
+				low[tile] = discovered[neighbor]
+			}
+			continue
+		}
+
+		stack = stack[:len(stack)-1]
+		above := parent[tile]
+		if above < 0 {
+			continue
+		}
+		if low[tile] < low[above] {
+			low[above] = low[tile]
+		}
+		subtreeTiles[above] += subtreeTiles[tile]
+		subtreeDirt[above] += subtreeDirt[tile]
+		if above == root {
+			rootChildren++
+		} else if low[tile] >= discovered[above] {
+			cutTiles[above] += subtreeTiles[tile]
+			cutDirt[above] += subtreeDirt[tile]
+		}
+	}
+
+	bottlenecks := []Bottleneck{}
+	if rootChildren > 1 {
+		bottlenecks = append(bottlenecks, Bottleneck{X: agent.posX, Y: agent.posY, Start: true})
+	}
+	for tile, tiles := range cutTiles {
+		bottlenecks = append(bottlenecks, Bottleneck{X: tile % width, Y: tile / width, CutTiles: tiles, CutDirt: cutDirt[tile]})
+	}
+	sort.Slice(bottlenecks, func(a, b int) bool {
+		if bottlenecks[a].Start != bottlenecks[b].Start {
+			return bottlenecks[a].Start
+		}
+		if bottlenecks[a].CutDirt != bottlenecks[b].CutDirt {
+			return bottlenecks[a].CutDirt > bottlenecks[b].CutDirt
+		}
+		if bottlenecks[a].CutTiles != bottlenecks[b].CutTiles {
+			return bottlenecks[a].CutTiles > bottlenecks[b].CutTiles
+		}
+		return bottlenecks[a].Y*width+bottlenecks[a].X < bottlenecks[b].Y*width+bottlenecks[b].X
+	})
+	return bottlenecks
+}
+
+// Text report, lists cut to limit entries when limit is positive
+func writeAnalysisText(w io.Writer, analysis MapAnalysis, limit int) {
+	shown := func(count int) int {
+		if limit > 0 && count > limit {
+			return limit
+		}
+		return count
+	}
+	more := func(count int) {
+		if shown(count) < count {
+			fmt.Fprintf(w, "  ... %d more\n", count-shown(count))
+		}
+	}
+
+	fmt.Fprintf(w, "Map: %dx%d, start (%d, %d), battery %d\n",
+		analysis.Width, analysis.Height, analysis.StartX, analysis.StartY, analysis.Battery)
+
+	fmt.Fprintf(w, "Components: %d\n", len(analysis.Components))
+	for i, area := range analysis.Components[:shown(len(analysis.Components))] {
+		where := "unreachable"
+		if area.Start {
+			where = "start"
+		}
+		fmt.Fprintf(w, "  #%d (%s): %d tiles, %d dirty with %d dirt, %d docks\n", i+1, where, area.Tiles, area.DirtyTiles, area.Dirt, area.Docks)
+	}
+	more(len(analysis.Components))
+
+	fmt.Fprintf(w, "Dirt: %d in total, %d reachable, %d within battery, %d unreachable\n", analysis.TotalDirt,
+		analysis.ReachableDirt, analysis.DirtWithinBattery, analysis.TotalDirt-analysis.ReachableDirt)
+
+	fmt.Fprintf(w, "Dirty tiles: %d\n", len(analysis.DirtyTiles))
+	for _, tile := range analysis.DirtyTiles[:shown(len(analysis.DirtyTiles))] {
+		switch {
+		case tile.Steps < 0:
+			fmt.Fprintf(w, "  (%d, %d): %d dirt, unreachable\n", tile.X, tile.Y, tile.Dirt)
+		case tile.WithinBattery:
+			fmt.Fprintf(w, "  (%d, %d): %d dirt, %d steps, %d battery\n", tile.X, tile.Y, tile.Dirt, tile.Steps, tile.Battery)
+		default:
+			fmt.Fprintf(w, "  (%d, %d): %d dirt, %d steps, %d battery (out of battery)\n", tile.X, tile.Y, tile.Dirt, tile.Steps, tile.Battery)
+		}
+	}
+	more(len(analysis.DirtyTiles))
+
+	fmt.Fprintf(w, "Dead ends: %d\n", len(analysis.DeadEnds))
+	for _, corridor := range analysis.DeadEnds[:shown(len(analysis.DeadEnds))] {
+		if corridor.MouthX < 0 {
+			fmt.Fprintf(w, "  (%d, %d): %d tiles with %d dirt, the whole component\n", corridor.X, corridor.Y, corridor.Length, corridor.Dirt)
+			continue
+		}
+		fmt.Fprintf(w, "  (%d, %d): %d tiles with %d dirt, joins at (%d, %d)\n",
+			corridor.X, corridor.Y, corridor.Length, corridor.Dirt, corridor.MouthX, corridor.MouthY)
+	}
+	more(len(analysis.DeadEnds))
+
+	fmt.Fprintf(w, "Bottlenecks: %d\n", len(analysis.Bottlenecks))
+	for _, bottleneck := range analysis.Bottlenecks[:shown(len(analysis.Bottlenecks))] {
+		if bottleneck.Start {
+			fmt.Fprintf(w, "  (%d, %d): the start, splits its component\n", bottleneck.X, bottleneck.Y)
+			continue
+		}
+		fmt.Fprintf(w, "  (%d, %d): cuts off %d tiles with %d dirt\n", bottleneck.X, bottleneck.Y, bottleneck.CutTiles, bottleneck.CutDirt)
+	}
+	more(len(analysis.Bottlenecks))
+}
+
+func runAnalyze(args []string) error {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	output := flags.String("output", "text", "Output format: text or json")
+	format := flags.String("format", "", "Input map format: csv, json or ascii (default from the extension)")
+	limit := flags.Int("limit", 0, "Most entries per list in the text report, 0 for all")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return commandUsage("analyze")
+	}
+
+	initialState, err := ReadValidInitialState(flags.Arg(0), *format)
+	if err != nil {
+		return err
+	}
+	analysis, err := AnalyzeMap(initialState)
+	if err != nil {
+		return err
+	}
+
+	switch *output {
+	case "text":
+		writeAnalysisText(os.Stdout, analysis, *limit)
+		return nil
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(analysis)
+	}
+	return fmt.Errorf("Unknown output format %s, expected text or json", *output)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeComponents(t *testing.T) {
+	tests := []struct {
+		name       string
+		state      InitialState
+		components []MapComponent
+		reachable  int
+		within     int
+	}{
+		{"open floor", testState(10, 1, 1, "0,4", "2,0"),
+			[]MapComponent{{Tiles: 4, DirtyTiles: 2, Dirt: 6, Start: true}}, 6, 6},
+		{"walled off dirt", testState(10, 1, 1, "0,9001,7", "3,9001,9002"),
+			[]MapComponent{{Tiles: 2, DirtyTiles: 1, Dirt: 3, Start: true}, {Tiles: 2, DirtyTiles: 1, Dirt: 7, Docks: 1}}, 3, 3},
+		{"bigger area first", testState(10, 1, 1, "0,9001,1,9001,0", "9001,9001,9001,9001,0", "5,9001,0,9001,0"),
+			[]MapComponent{{Tiles: 1, Start: true}, {Tiles: 3}, {Tiles: 1, DirtyTiles: 1, Dirt: 1},
+				{Tiles: 1, DirtyTiles: 1, Dirt: 5}, {Tiles: 1}}, 0, 0},
+		{"out of battery", testState(3, 1, 1, "0,0,0,8", "6,0,0,0"),
+			[]MapComponent{{Tiles: 8, DirtyTiles: 2, Dirt: 14, Start: true}}, 14, 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			analysis, err := AnalyzeMap(test.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(analysis.Components, test.components) {
+				t.Errorf("components %+v, want %+v", analysis.Components, test.components)
+			}
+			if analysis.ReachableDirt != test.reachable || analysis.DirtWithinBattery != test.within {
+				t.Errorf("reachable dirt %d and %d within the battery, want %d and %d",
+					analysis.ReachableDirt, analysis.DirtWithinBattery, test.reachable, test.within)
+			}
+		})
+	}
+}
+
+func TestAnalyzeDeadEnds(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    InitialState
+		deadEnds []DeadEnd
+	}{
+		{"corridor off a room", testState(10, 1, 1, "0,0,9001,9001", "0,0,3,4"),
+			[]DeadEnd{{X: 3, Y: 1, MouthX: 1, MouthY: 1, Length: 2, Dirt: 7}}},
+		{"whole component is a corridor", testState(10, 1, 1, "0,5,0"),
+			[]DeadEnd{{X: 0, Y: 0, MouthX: -1, MouthY: -1, Length: 3, Dirt: 5}}},
+		{"loop", testState(10, 1, 1, "0,0", "0,0"), []DeadEnd{}},
+		{"other component left out", testState(10, 1, 1, "0,0", "0,0", "9001,9001", "1,0"), []DeadEnd{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			analysis, err := AnalyzeMap(test.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(analysis.DeadEnds, test.deadEnds) {
+				t.Errorf("dead ends %+v, want %+v", analysis.DeadEnds, test.deadEnds)
+			}
+		})
+	}
+}
+
+func TestAnalyzeBottlenecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       InitialState
+		bottlenecks []Bottleneck
+	}{
+		{"two rooms and a door", testState(10, 1, 1, "0,0,9001,0,0", "0,0,0,0,5", "0,0,9001,0,0"),
+			[]Bottleneck{{X: 1, Y: 1, CutTiles: 7, CutDirt: 5}, {X: 2, Y: 1, CutTiles: 6, CutDirt: 5}, {X: 3, Y: 1, CutTiles: 5, CutDirt: 5}}},
+		{"start in the middle of a corridor", InitialState{X0: 1, Battery: 10, MovementCost: 1, VacuumingCost: 1,
+			Tiles: [][]string{{"3", "0", "4"}}}, []Bottleneck{{X: 1, Y: 0, Start: true}}},
+		{"loop", testState(10, 1, 1, "0,1", "2,0"), []Bottleneck{}},
+		{"chain", testState(10, 1, 1, "0,1,2,3"),
+			[]Bottleneck{{X: 1, Y: 0, CutTiles: 2, CutDirt: 5}, {X: 2, Y: 0, CutTiles: 1, CutDirt: 3}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			analysis, err := AnalyzeMap(test.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(analysis.Bottlenecks, test.bottlenecks) {
+				t.Errorf("bottlenecks %+v, want %+v", analysis.Bottlenecks, test.bottlenecks)
+			}
+		})
+	}
+}
+
+// Tiles and dirt reached from the start without stepping on the blocked tile (x, y), and the number of
+// separate areas the start's neighbors fall into without it
+func bruteForceReach(agent *Agent, blockX int, blockY int) (int, int, int) {
+	seen := map[[2]int]bool{{blockX, blockY}: true}
+	fill := func(x int, y int) (int, int) {
+		tiles, dirt := 0, 0
+		stack := [][2]int{{x, y}}
+		seen[[2]int{x, y}] = true
+		for len(stack) > 0 {
+			tile := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			tiles++
+			dirt += dirtOf(agent.tiles[tile[1]][tile[0]])
+			for _, dir := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				next := [2]int{tile[0] + dir[1], tile[1] + dir[0]}
+				if agent.getTileValue(next[0], next[1]) != WALL_VALUE && !seen[next] {
+					seen[next] = true
+					stack = append(stack, next)
+				}
+			}
+		}
+		return tiles, dirt
+	}
+
+	if blockX != agent.posX || blockY != agent.posY {
+		tiles, dirt := fill(agent.posX, agent.posY)
+		return tiles, dirt, 1
+	}
+	areas := 0
+	for _, dir := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		x, y := agent.posX+dir[1], agent.posY+dir[0]
+		if agent.getTileValue(x, y) != WALL_VALUE && !seen[[2]int{x, y}] {
+			fill(x, y)
+			areas++
+		}
+	}
+	return 0, 0, areas
+}
+
+// Every tile is blocked in turn: it is a bottleneck when fewer tiles are reached without it
+func TestBottlenecksMatchBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	for i := 0; i < 100; i++ {
+		width, height := 1+random.Intn(6), 1+random.Intn(6)
+		rows := []string{}
+		for y := 0; y < height; y++ {
+			row := []string{}
+			for x := 0; x < width; x++ {
+				switch roll := random.Intn(6); {
+				case x == 0 && y == 0, roll < 2:
+					row = append(row, "0")
+				case roll < 4:
+					row = append(row, "9001")
+				default:
+					row = append(row, fmt.Sprint(1+random.Intn(9)))
+				}
+			}
+			rows = append(rows, strings.Join(row, ","))
+		}
+		state := testState(10, 1, 1, rows...)
+
+		t.Run(fmt.Sprintf("random %d", i), func(t *testing.T) {
+			analysis, err := AnalyzeMap(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			agent, _ := CreateAgent(state, RunOptions{})
+			found := map[[2]int]Bottleneck{}
+			for _, bottleneck := range analysis.Bottlenecks {
+				found[[2]int{bottleneck.X, bottleneck.Y}] = bottleneck
+			}
+
+			allTiles, allDirt, _ := bruteForceReach(&agent, -1, -1)
+			for y, row := range agent.tiles {
+				for x, tile := range row {
+					if tile == WALL_VALUE {
+						continue
+					}
+					want := Bottleneck{X: x, Y: y}
+					tiles, dirt, areas := bruteForceReach(&agent, x, y)
+					if x == agent.posX && y == agent.posY {
+						want.Start = areas > 1
+					} else if tiles < allTiles {
+						want.CutTiles, want.CutDirt = allTiles-tiles-1, allDirt-dirt-dirtOf(tile)
+					}
+
+					got, ok := found[[2]int{x, y}]
+					if ok != (want.Start || want.CutTiles > 0) || ok && got != want {
+						t.Errorf("tile (%d, %d) reported as %+v (%v), want %+v", x, y, got, ok, want)
+					}
+				}
+			}
+		})
+	}
+}
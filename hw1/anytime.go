@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const ANYTIME_DEFAULT_BUDGET = time.Second // Time budget of the anytime planner when none is given
+const ANYTIME_KICK_SHARE = 0.2             // Share of the tour a perturbation drops, at least one tile
+
+func init() {
+	RegisterPlanner(NewPlannerFunc("anytime",
+		"Starts from the optimal algorithm's result and improves it with iterated local search until the --time-budget is spent",
+		FindAndTraverseAnytimePath))
+}
+
+// Score of the best plan found so far, reported to show how the plan improved over time
+type ProgressPoint struct {
+	Millis      int64  `json:"ms"`
+	DirtCleaned int    `json:"dirtCleaned"`
+	Found       string `json:"found"` // what found it, e.g. "greedy" or "perturbation 3, trip 1"
+}
+
+// Iterated local search over orienteering tours of one battery charge, shared by the trips of a run
+type anytimeSearch struct {
+	agent    *Agent
+	start    time.Time
+	deadline time.Time
+	random   *rand.Rand
+	progress []ProgressPoint
+	first    [][2]int // (x, y) of the tiles the greedy plan vacuumed in order, the first trip starts from them
+	trips    int
+	kicks    int
+}
+
+func (search *anytimeSearch) found(dirt int, how string) {
+	search.progress = append(search.progress,
+		ProgressPoint{Millis: time.Since(search.start).Milliseconds(), DirtCleaned: dirt, Found: how})
+}
+
+// FindAndTraverseAnytimePath returns the best plan it finds within the time budget (options.TimeBudget).
+// The first plan is the one of the optimal (greedy/BFS) algorithm, its vacuumed tiles in order become an
+// orienteering tour that local search improves (see orienteering.go). The rest of the budget goes to
+// iterated local search: a seeded random part of the best tour is dropped, the rest searched again, and
+// the result kept when it cleans more. On maps with docks every dock-to-dock trip is searched this way and
+// gets half of the time left. The budget holds: driving a tour takes a shortest path search per tile, as
+// long as one of the distance searches, so the search stops early enough for the drive, and when the
+// distances alone do not fit the greedy plan is returned as it is.
+func FindAndTraverseAnytimePath(initialState InitialState, options RunOptions) (PlanResult, error) {
+	budget := options.TimeBudget
+	if budget <= 0 {
+		budget = ANYTIME_DEFAULT_BUDGET
+	}
+
+	agent, err := CreateAgent(initialState, options)
+	if err != nil {
+		return PlanResult{}, err
+	}
+	search := anytimeSearch{agent: &agent, start: time.Now(), random: rand.New(rand.NewSource(options.Seed))}
+	search.deadline = search.start.Add(budget)
+	if !agent.deadline.IsZero() && agent.deadline.Before(search.deadline) {
+		search.deadline = agent.deadline
+	}
+
+	agent.logs = append(agent.logs, fmt.Sprintf("Initial position: (%d, %d)", agent.posX, agent.posY))
+
+	// the greedy plan does not know about docks, it is no start when the run has to end on one
+	var greedy *PlanResult
+	if !agent.mustEndOnDock {
+		baseline, err := CreateAgent(initialState, options)
+		if err != nil {
+			return PlanResult{}, err
+		}
+		result := traverseOptimalPath(&baseline)
+		greedy = &result
+		search.found(result.DirtCleaned, "greedy")
+		for _, step := range result.Steps {
+			if step.DirtVacuumed > 0 {
+				search.first = append(search.first, [2]int{step.X, step.Y})
+			}
+		}
+	}
+
+	complete := true
+	var toDock *travelCosts
+	if docks := agent.docks(); len(docks) > 0 {
+		route := dijkstra(&agent, docks, true)
+		toDock = &route
+		for complete {
+			for agent.onDock() && agent.charge() > 0 {
+			}
+
+			var cleaned int
+			if cleaned, complete = search.trip(toDock, greedy != nil); cleaned == 0 {
+				break
+			}
+		}
+	}
+	if complete && !agent.mustEndOnDock {
+		_, complete = search.trip(nil, greedy != nil)
+	} else if complete {
+		walkTo(&agent, *toDock)
+	}
+
+	if greedy != nil && (!complete || agent.dirtCleaned <= greedy.DirtCleaned) {
+		if !complete {
+			greedy.Logs = append(greedy.Logs, "Time budget spent on distances")
+		}
+		greedy.Logs = append(greedy.Logs, fmt.Sprintf("Searched %d trips with %d perturbations, keeping the greedy plan",
+			search.trips, search.kicks))
+		greedy.Progress = search.progress
+		return *greedy, nil
+	}
+
+	agent.logs = append(agent.logs, fmt.Sprintf("Searched %d trips with %d perturbations", search.trips, search.kicks))
+	result := agent.result()
+	result.Progress = search.progress
+	return result, nil
+}
+
+// Searches and drives one tour of the current battery, with toDock set it keeps enough battery to reach
+// a dock afterwards. Returns the dirt cleaned, and false when the distances did not fit into the budget
+// and there is a greedy plan to fall back to.
+func (search *anytimeSearch) trip(toDock *travelCosts, fallback bool) (int, bool) {
+	agent := search.agent
+	search.trips++
+
+	// a trip with docks gets half of the time left, later trips are shorter as there is less dirt left
+	deadline := search.deadline
+	if toDock != nil {
+		deadline = time.Now().Add(time.Until(search.deadline) / 2)
+	}
+
+	tiles := reachableDirtyTiles(agent)
+	nodes := append(append([]dirtyTile{}, tiles...), dirtyTile{x: agent.posX, y: agent.posY})
+	distancesStart := time.Now()
+	dist, ok := tileTravelCostsUntil(agent, nodes, deadline)
+	if !ok && (fallback || agent.pastDeadline()) {
+		return 0, false
+	}
+	if !ok {
+		dist = tileTravelCosts(agent, nodes) // nothing to fall back to, finish late rather than not at all
+	}
+	perTile := time.Since(distancesStart) / time.Duration(len(nodes))
+	driving := func(tour []int) time.Time {
+		return deadline.Add(-perTile * time.Duration(len(tour)+1))
+	}
+
+	problem := orienteering{tiles: tiles, dist: dist, start: len(tiles), battery: agent.battery}
+	if toDock != nil {
+		problem.returnCost = make([]int, len(nodes))
+		for i, node := range nodes {
+			problem.returnCost[i] = toDock.costAt(node.x, node.y)
+			if problem.returnCost[i] < 0 {
+				problem.returnCost[i] = agent.capacity + 1 // no way back, never worth ending there
+			}
+		}
+		if problem.returnCost[problem.start] > agent.battery {
+			return 0, true
+		}
+	}
+
+	tour := []int{}
+	if search.trips == 1 {
+		index := map[[2]int]int{}
+		for i, tile := range tiles {
+			index[[2]int{tile.x, tile.y}] = i
+		}
+		seen := map[int]bool{}
+		for _, pos := range search.first {
+			if i, ok := index[pos]; ok && !seen[i] {
+				tour, seen[i] = append(tour, i), true
+			}
+		}
+		if problem.tourCost(tour) > problem.battery {
+			tour = []int{} // dirt that came back during the greedy run, or no dock in reach at the end
+		}
+	}
+
+	// the first tour is built in full, there is nothing to return before it. Filling up the greedy tour
+	// keeps its order, inserting from scratch often finds a tighter one, the search starts from the better.
+	best := problem.greedyInsertion(tour)
+	if fresh := problem.greedyInsertion([]int{}); problem.tourDirt(fresh) > problem.tourDirt(best) {
+		best = fresh
+	}
+	problem.deadline = driving(best)
+	best = problem.search(best)
+	bestDirt := problem.tourDirt(best)
+	search.found(agent.dirtCleaned+bestDirt, fmt.Sprintf("local search, trip %d", search.trips))
+
+	for !problem.expired() && len(best) > 0 {
+		search.kicks++
+		// the dropped tiles stay out of the first insertion so the search goes somewhere else
+		kept, dropped := problem.perturb(best, search.random)
+		problem.banned = dropped
+		candidate := problem.greedyInsertion(kept)
+		problem.banned = nil
+		candidate = problem.search(candidate)
+		if dirt := problem.tourDirt(candidate); dirt > bestDirt ||
+			(dirt == bestDirt && problem.tourCost(candidate) < problem.tourCost(best)) {
+			if dirt > bestDirt {
+				search.found(agent.dirtCleaned+dirt, fmt.Sprintf("perturbation %d, trip %d", search.kicks, search.trips))
+			}
+			best, bestDirt = candidate, dirt
+			problem.deadline = driving(best)
+		}
+	}
+
+	cleanedBefore := agent.dirtCleaned
+	for _, tile := range best {
+		walkTo(agent, routeTo(agent, tiles[tile].x, tiles[tile].y))
+		agent.vacuumIfDirty()
+	}
+	if toDock != nil {
+		walkTo(agent, *toDock)
+	}
+	return agent.dirtCleaned - cleanedBefore, true
+}
+
+// Dirt of the tiles on the tour
+func (problem *orienteering) tourDirt(tour []int) int {
+	dirt := 0
+	for _, tile := range tour {
+		dirt += problem.tiles[tile].dirt
+	}
+	return dirt
+}
+
+// Copy of the tour without a random ANYTIME_KICK_SHARE of its tiles, and the tiles dropped
+func (problem *orienteering) perturb(tour []int, random *rand.Rand) ([]int, map[int]bool) {
+	drop := int(float64(len(tour)) * ANYTIME_KICK_SHARE)
+	if drop < 1 {
+		drop = 1
+	}
+
+	dropped := map[int]bool{}
+	for _, i := range random.Perm(len(tour))[:drop] {
+		dropped[tour[i]] = true
+	}
+	kept := []int{}
+	for _, tile := range tour {
+		if !dropped[tile] {
+			kept = append(kept, tile)
+		}
+	}
+	return kept, dropped
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// With a budget the local search gets out of tours that leave no room for the far away dirt
+func TestAnytimeReachesExact(t *testing.T) {
+	maps, err := filepath.Glob("inputs/*.csv")
+	if err != nil || len(maps) == 0 {
+		t.Fatalf("no sample maps: %v", err)
+	}
+
+	for _, path := range maps {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			state, err := LoadInitialState(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			exact := testResult(t, "exact", state, RunOptions{})
+
+			options := RunOptions{TimeBudget: 200 * time.Millisecond}
+			for _, algorithm := range []string{"anytime", "orienteering"} {
+				if result := testResult(t, algorithm, state, options); result.DirtCleaned != exact.DirtCleaned {
+					t.Errorf("%s cleaned %d dirt, exact %d", algorithm, result.DirtCleaned, exact.DirtCleaned)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const BENCH_STOP_GRACE = 5 * time.Second // How long a run waits past the timeout for the planner to stop
+
+func init() {
+	registerCommand("bench", "[--algorithms=a,b] [--workers=N] [--timeout=30s] [--format=markdown|csv] <input directory>",
+		"Runs planners over every input map in a directory in parallel and prints a comparison table",
+		runBench)
+}
+
+// One planner run on one map
+type BenchResult struct {
+	Map           string
+	Algorithm     string
+	DirtCleaned   int
+	TilesMoved    int
+	TilesVisited  int // distinct tiles, the start included
+	BatteryLeft   int
+	Duration      time.Duration
+	ReachableDirt int // all dirt reachable from the start, ignoring the battery
+	Error         string
+}
+
+// Share of the reachable dirt that was cleaned, in percent
+func (result BenchResult) CleanedPercent() float64 {
+	if result.ReachableDirt == 0 {
+		return 100
+	}
+	return 100 * float64(result.DirtCleaned) / float64(result.ReachableDirt)
+}
+
+// Counts distinct tiles the agent stood on
+func tilesVisited(result PlanResult) int {
+	visited := map[[2]int]bool{{result.StartX, result.StartY}: true}
+	for _, step := range result.Steps {
+		visited[[2]int{step.X, step.Y}] = true
+	}
+	return len(visited)
+}
+
+// Dirt on all tiles reachable from the start
+func reachableDirt(initialState InitialState) (int, error) {
+	agent, err := CreateAgent(initialState, RunOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	dirt := 0
+	for _, tile := range reachableDirtyTiles(&agent) {
+		dirt += tile.dirt
+	}
+	return dirt, nil
+}
+
+// Runs a planner, giving up on it after the timeout. The planner gets the timeout as its deadline and
+// stops soon after it, the run waits for that so the planner does not slow down the runs after it.
+// One that still does not return within BENCH_STOP_GRACE keeps its goroutine, which is fine for a
+// command that exits afterwards.
+func benchRun(planner Planner, initialState InitialState, timeout time.Duration) (PlanResult, time.Duration, error) {
+	type outcome struct {
+		result PlanResult
+		err    error
+	}
+
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		result, err := planner.Plan(initialState, RunOptions{Deadline: start.Add(timeout)})
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case out := <-done:
+		if time.Since(start) < timeout {
+			return out.result, time.Since(start), out.err
+		}
+	case <-time.After(timeout):
+		select {
+		case <-done:
+		case <-time.After(BENCH_STOP_GRACE):
+		}
+	}
+	return PlanResult{}, timeout, fmt.Errorf("timeout after %v", timeout)
+}
+
+// RunBench runs every planner on every map, using the given number of goroutines.
+// Results are sorted by map and then algorithm name.
+func RunBench(mapFiles []string, plannerNames []string, workers int, timeout time.Duration) ([]BenchResult, error) {
+	type job struct {
+		mapFile      string
+		initialState InitialState
+		reachable    int
+		planner      Planner
+	}
+
+	jobs := []job{}
+	for _, mapFile := range mapFiles {
+		initialState, err := ReadValidInitialState(mapFile, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(initialState.Robots) > 0 {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %d robots, plan it with: %s fleet %s\n", mapFile, len(initialState.Robots)+1, programName(), mapFile)
+			continue
+		}
+		reachable, err := reachableDirt(initialState)
+		if err != nil {
+			return nil, fmt.Errorf("Error in %s: %v", mapFile, err)
+		}
+
+		for _, name := range plannerNames {
+			planner, err := GetPlanner(name)
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, job{mapFile, initialState, reachable, planner})
+		}
+	}
+
+	results := make([]BenchResult, len(jobs))
+	next := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				j := jobs[i]
+				result, duration, err := benchRun(j.planner, j.initialState, timeout)
+				results[i] = BenchResult{
+					Map:           filepath.Base(j.mapFile),
+					Algorithm:     j.planner.Name(),
+					DirtCleaned:   result.DirtCleaned,
+					TilesMoved:    result.TilesMoved,
+					TilesVisited:  tilesVisited(result),
+					BatteryLeft:   result.BatteryLeft,
+					Duration:      duration,
+					ReachableDirt: j.reachable,
+				}
+				if err != nil {
+					results[i].Error = err.Error()
+				}
+			}
+		}()
+	}
+
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Map != results[j].Map {
+			return results[i].Map < results[j].Map
+		}
+		return results[i].Algorithm < results[j].Algorithm
+	})
+
+	return results, nil
+}
+
+func writeBenchMarkdown(w io.Writer, results []BenchResult) {
+	fmt.Fprintln(w, "| Map | Algorithm | Dirt cleaned | Tiles moved | Tiles visited | Battery left | Time (ms) | Reachable dirt cleaned | Error |")
+	fmt.Fprintln(w, "|-|-|-:|-:|-:|-:|-:|-:|-|")
+	for _, result := range results {
+		fmt.Fprintf(w, "| %s | %s | %d | %d | %d | %d | %.2f | %.1f%% | %s |\n",
+			result.Map, result.Algorithm, result.DirtCleaned, result.TilesMoved, result.TilesVisited,
+			result.BatteryLeft, float64(result.Duration.Microseconds())/1000, result.CleanedPercent(), result.Error)
+	}
+}
+
+func writeBenchCSV(w io.Writer, results []BenchResult) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"map", "algorithm", "dirt_cleaned", "tiles_moved", "tiles_visited", "battery_left",
+		"time_ms", "reachable_dirt", "reachable_dirt_cleaned_percent", "error"})
+	for _, result := range results {
+		writer.Write([]string{result.Map, result.Algorithm, strconv.Itoa(result.DirtCleaned),
+			strconv.Itoa(result.TilesMoved), strconv.Itoa(result.TilesVisited), strconv.Itoa(result.BatteryLeft),
+			strconv.FormatFloat(float64(result.Duration.Microseconds())/1000, 'f', 3, 64),
+			strconv.Itoa(result.ReachableDirt), strconv.FormatFloat(result.CleanedPercent(), 'f', 2, 64), result.Error})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func runBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	algorithms := flags.String("algorithms", "", "Comma separated algorithms to run (default all)")
+	workers := flags.Int("workers", runtime.NumCPU(), "Number of parallel runs")
+	timeout := flags.Duration("timeout", 30*time.Second, "Time limit for a single run")
+	format := flags.String("format", "markdown", "Table format: markdown or csv")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return commandUsage("bench")
+	}
+	if *format != "markdown" && *format != "csv" {
+		return fmt.Errorf("Unknown table format %s, expected markdown or csv", *format)
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+
+	entries, err := os.ReadDir(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	mapFiles := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && isMapFile(entry.Name()) {
+			mapFiles = append(mapFiles, filepath.Join(flags.Arg(0), entry.Name()))
+		}
+	}
+	if len(mapFiles) == 0 {
+		return fmt.Errorf("No input maps found in %s", flags.Arg(0))
+	}
+	sort.Strings(mapFiles)
+
+	plannerNames := []string{}
+	for _, name := range PlannerNames() {
+		if name != MANUAL_PLANNER {
+			plannerNames = append(plannerNames, name)
+		}
+	}
+	if *algorithms != "" {
+		plannerNames = strings.Split(*algorithms, ",")
+	}
+
+	results, err := RunBench(mapFiles, plannerNames, *workers, *timeout)
+	if err != nil {
+		return err
+	}
+
+	if *format == "csv" {
+		return writeBenchCSV(os.Stdout, results)
+	}
+	writeBenchMarkdown(os.Stdout, results)
+	return nil
+}
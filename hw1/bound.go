@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sort"
+)
+
+const BOUND_MAX_KNAPSACK_CELLS = 20_000_000 // (dirty tile, battery) cells the exact knapsack fills, the LP bound beyond
+
+// How the upper bound was found
+const (
+	BOUND_KNAPSACK  = "knapsack"  // 0/1 knapsack over the battery, exact for the relaxation
+	BOUND_LP        = "lp"        // fractional knapsack, the LP relaxation of the knapsack
+	BOUND_REACHABLE = "reachable" // docks refill the battery, only the flood fill limits the dirt
+)
+
+// Upper bound on the dirt a run can clean, no planner does better
+type DirtBound struct {
+	Reachable int    `json:"reachable"` // dirt a flood fill from the start gets to
+	Bound     int    `json:"bound"`
+	Method    string `json:"method"` // one of the BOUND_ methods
+}
+
+// ComputeDirtBound bounds the dirt cleaned on a map, nil when dirt comes back as there is no fixed dirt to bound.
+// Every vacuumed tile other than the start costs at least stepping onto it once plus vacuuming it, and those
+// costs of different tiles never overlap, so the battery is a knapsack over the reachable dirty tiles and any
+// relaxation of it bounds every plan. Tiles the battery cannot get to from the start are left out, and the
+// cheapest way to the first dirty tile is paid before the knapsack. On small
+// instances the 0/1 knapsack is solved by dynamic programming over the battery, on larger ones the fractional
+// knapsack (its LP relaxation) takes the tiles with the most dirt per battery first. With docks the battery
+// comes back and the bound is all reachable dirt.
+func ComputeDirtBound(initialState InitialState) (*DirtBound, error) {
+	agent, err := CreateAgent(initialState, RunOptions{StepLimit: -1})
+	if err != nil {
+		return nil, err
+	}
+	if agent.env != nil {
+		return nil, nil
+	}
+
+	tiles := reachableDirtyTiles(&agent)
+	bound := DirtBound{Method: BOUND_REACHABLE}
+	for _, tile := range tiles {
+		bound.Reachable += tile.dirt
+	}
+	bound.Bound = bound.Reachable
+	if len(agent.docks()) > 0 {
+		return &bound, nil
+	}
+
+	// the way to the first dirty tile the agent steps on passes no other one, what it costs beyond
+	// stepping onto that tile comes off the battery whenever more than the start tile is cleaned
+	route := dijkstraWithin(&agent, [][2]int{{agent.posX, agent.posY}}, false, agent.battery, nil)
+	dirt, costs := []int{}, []int{}
+	startOnly, approach := 0, -1
+	for _, tile := range tiles {
+		if tile.x == agent.posX && tile.y == agent.posY {
+			if tile.vacuumCost <= agent.battery {
+				startOnly = tile.dirt
+				dirt, costs = append(dirt, tile.dirt), append(costs, tile.vacuumCost)
+			}
+			continue
+		}
+		travel := route.costAt(tile.x, tile.y)
+		if travel < 0 || travel+tile.vacuumCost > agent.battery {
+			continue
+		}
+		entry := agent.moveCostTo(tile.x, tile.y)
+		if approach < 0 || travel-entry < approach {
+			approach = travel - entry
+		}
+		dirt, costs = append(dirt, tile.dirt), append(costs, entry+tile.vacuumCost)
+	}
+	if approach < 0 {
+		bound.Bound, bound.Method = startOnly, BOUND_KNAPSACK
+		return &bound, nil
+	}
+
+	budget := agent.battery - approach
+	if float64(len(dirt))*float64(budget+1) <= BOUND_MAX_KNAPSACK_CELLS {
+		bound.Bound, bound.Method = knapsack(dirt, costs, budget), BOUND_KNAPSACK
+	} else {
+		bound.Bound, bound.Method = fractionalKnapsack(dirt, costs, budget), BOUND_LP
+	}
+	if startOnly > bound.Bound {
+		bound.Bound = startOnly
+	}
+	return &bound, nil
+}
+
+// Share of the upper bound the run cleaned, 1 when there is nothing to clean
+func (result PlanResult) BoundRatio() float64 {
+	if result.Bound == nil || result.Bound.Bound == 0 {
+		return 1
+	}
+	return float64(result.DirtCleaned) / float64(result.Bound.Bound)
+}
+
+// Most value of items with the given weights that fit into the capacity, each item taken at most once
+func knapsack(values []int, weights []int, capacity int) int {
+	best := make([]int, capacity+1) // best[c] is the most value within weight c
+	for i, value := range values {
+		for c := capacity; c >= weights[i]; c-- {
+			if best[c-weights[i]]+value > best[c] {
+				best[c] = best[c-weights[i]] + value
+			}
+		}
+	}
+	return best[capacity]
+}
+
+// Most value of the knapsack when items may be taken in part, rounded down as dirt comes in whole units.
+// Integer arithmetic keeps the rounding from ever going below the real bound.
+func fractionalKnapsack(values []int, weights []int, capacity int) int {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return values[order[a]]*weights[order[b]] > values[order[b]]*weights[order[a]]
+	})
+
+	total, left := 0, capacity
+	for _, i := range order {
+		if weights[i] <= left {
+			total += values[i]
+			left -= weights[i]
+			continue
+		}
+		total += values[i] * left / weights[i]
+		break
+	}
+	return total
+}
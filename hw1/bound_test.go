@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestKnapsackBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []int
+		weights    []int
+		capacity   int
+		knapsack   int
+		fractional int
+	}{
+		{"empty", nil, nil, 10, 0, 0},
+		{"no capacity", []int{5, 7}, []int{1, 2}, 0, 0, 0},
+		{"everything fits", []int{5, 7}, []int{1, 2}, 3, 12, 12},
+		{"best pair over the densest item", []int{60, 100, 120}, []int{10, 20, 30}, 50, 220, 240},
+		{"fraction rounds down", []int{10, 10}, []int{3, 3}, 4, 10, 13},
+		{"heavier than the capacity", []int{50}, []int{11}, 10, 0, 45},
+		{"free items", []int{3, 4}, []int{0, 5}, 2, 3, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := knapsack(test.values, test.weights, test.capacity); got != test.knapsack {
+				t.Errorf("knapsack = %d, want %d", got, test.knapsack)
+			}
+			fractional := fractionalKnapsack(test.values, test.weights, test.capacity)
+			if fractional != test.fractional {
+				t.Errorf("fractionalKnapsack = %d, want %d", fractional, test.fractional)
+			}
+			if fractional < test.knapsack {
+				t.Errorf("the LP bound %d is below the knapsack %d", fractional, test.knapsack)
+			}
+		})
+	}
+}
+
+// The bound is never below what the exact planner cleans
+func TestDirtBoundAboveExact(t *testing.T) {
+	tests := []struct {
+		name  string
+		state InitialState
+	}{
+		{"corridor", testState(4, 1, 1, "0,3,0,9")},
+		{"start is dirty", testState(3, 1, 1, "8,0,4")},
+		{"detour", testState(6, 1, 1, "0,1,9001", "0,9001,9001", "0,0,50")},
+		{"expensive vacuum", testState(9, 1, 4, "0,10,0", "20,0,30")},
+		{"walled off", testState(20, 1, 1, "0,9001,40", "5,9001,0")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bound, err := ComputeDirtBound(test.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := FindAndTraverseExactPath(test.state, RunOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bound.Bound < result.DirtCleaned || bound.Bound > bound.Reachable {
+				t.Errorf("bound %d (reachable %d), exact cleaned %d", bound.Bound, bound.Reachable, result.DirtCleaned)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+func init() {
+	RegisterPlanner(NewPlannerFunc("online",
+		"Replans every turn on a map where dirt comes back, heads for the most dirt per battery and waits for regrowth",
+		FindAndTraverseOnlinePath))
+}
+
+// Dirt that comes back while the agent cleans, advanced by one turn for every action taken
+type environment struct {
+	regrowth     []growingTile // tiles dirt comes back on, in row order
+	events       []DirtEvent   // sorted by step
+	nextEvent    int
+	turn         int
+	horizon      int // 0 for no limit
+	appeared     int
+	dirt         *dirtLedger // dirt on the map, kept up to date as dirt appears
+	dirtOverTime int         // dirt on the map summed over the turns, initial map included
+}
+
+// Tile with regrowth, dirt is added every period turns
+type growingTile struct {
+	x      int
+	y      int
+	dirt   int
+	period int
+}
+
+func newEnvironment(initialState InitialState) (*environment, error) {
+	env := environment{horizon: initialState.Horizon}
+
+	if initialState.Regrowth != nil {
+		for y, row := range initialState.Regrowth {
+			for x, cell := range row {
+				growth, err := parseRegrowth(cell)
+				if err != nil {
+					return nil, fmt.Errorf("Error parsing regrowth at (%d, %d): %v", x, y, err)
+				}
+				if growth[0] > 0 {
+					env.regrowth = append(env.regrowth, growingTile{x: x, y: y, dirt: growth[0], period: growth[1]})
+				}
+			}
+		}
+	}
+
+	env.events = append(env.events, initialState.Events...)
+	sort.SliceStable(env.events, func(i, j int) bool { return env.events[i].Step < env.events[j].Step })
+
+	return &env, nil
+}
+
+// Moves the environment one turn forward, returns log lines for the scripted events
+func (env *environment) advance(tiles [][]int) []string {
+	var logs []string
+	env.turn++
+
+	for _, tile := range env.regrowth {
+		if env.turn%tile.period == 0 {
+			env.grow(tiles, tile.x, tile.y, tile.dirt)
+		}
+	}
+
+	for env.nextEvent < len(env.events) && env.events[env.nextEvent].Step <= env.turn {
+		event := env.events[env.nextEvent]
+		if event.Step == env.turn {
+			added := env.grow(tiles, event.X, event.Y, event.Dirt)
+			logs = append(logs, fmt.Sprintf("Event: (%d) dirt appeared at (%d, %d)", added, event.X, event.Y))
+		}
+		env.nextEvent++
+	}
+
+	env.dirtOverTime += env.dirt.total
+	return logs
+}
+
+// Adds dirt to a floor tile, walls and docks stay as they are, returns the dirt actually added
+func (env *environment) grow(tiles [][]int, x int, y int, dirt int) int {
+	if y < 0 || y >= len(tiles) || x < 0 || x >= len(tiles[y]) || tiles[y][x] >= WALL_VALUE {
+		return 0
+	}
+
+	before := tiles[y][x]
+	tiles[y][x] += dirt
+	if tiles[y][x] > WALL_VALUE-1 {
+		tiles[y][x] = WALL_VALUE - 1
+	}
+	env.appeared += tiles[y][x] - before
+	env.dirt.change(before, tiles[y][x])
+	return tiles[y][x] - before
+}
+
+func (env *environment) over() bool {
+	return env.horizon > 0 && env.turn >= env.horizon
+}
+
+// Turns left before the horizon, -1 for no limit
+func (env *environment) turnsLeft() int {
+	if env.horizon <= 0 {
+		return -1
+	}
+	return env.horizon - env.turn
+}
+
+// Whether dirt may still appear, from regrowth or an event yet to come
+func (env *environment) changing() bool {
+	return len(env.regrowth) > 0 || env.nextEvent < len(env.events)
+}
+
+// Dirt on the map averaged over the turns, the cumulative measure of how clean the map was kept
+func (env *environment) averageDirt() float64 {
+	return float64(env.dirtOverTime) / float64(env.turn+1)
+}
+
+// FindAndTraverseOnlinePath cleans a map whose dirt changes over time. Nothing is planned ahead: every turn
+// the agent searches the current map again and takes one step towards the dirty tile with the most dirt per
+// battery that it can still reach and vacuum before the horizon, or vacuums it when standing on it. With no
+// such tile it charges when on a dock, waits while dirt may still appear and it could still vacuum it,
+// and stops otherwise.
+func FindAndTraverseOnlinePath(initialState InitialState, options RunOptions) (PlanResult, error) {
+	agent, err := CreateAgent(initialState, options)
+	if err != nil {
+		return PlanResult{}, err
+	}
+	if err := agent.rejectDocks("online"); err != nil {
+		return PlanResult{}, err
+	}
+
+	agent.logs = append(agent.logs, fmt.Sprintf("Initial position: (%d, %d)", agent.posX, agent.posY))
+
+	for !agent.outOfTime() {
+		route := dijkstraWithin(&agent, [][2]int{{agent.posX, agent.posY}}, false, agent.battery, nil)
+		turnsLeft := -1
+		if agent.env != nil {
+			turnsLeft = agent.env.turnsLeft()
+		}
+
+		target, targetDirt, targetCost := [2]int{-1, -1}, 0, 0
+		for _, i := range route.order {
+			x, y := route.position(i)
+			dirt := dirtOf(agent.tiles[y][x])
+			cost := route.cost[i] + agent.vacuumCostAt(x, y)
+			if dirt == 0 || cost > agent.battery || (turnsLeft >= 0 && route.steps[i]+1 > turnsLeft) {
+				continue
+			}
+			if target[0] < 0 || betterValue(dirt, cost, targetDirt, targetCost) {
+				target, targetDirt, targetCost = [2]int{x, y}, dirt, cost
+			}
+		}
+
+		if target[0] < 0 {
+			if agent.charge() > 0 {
+				continue
+			}
+			if agent.env == nil || agent.env.horizon <= 0 || !agent.env.changing() ||
+				agent.battery < agent.vacuumCostAt(agent.posX, agent.posY) {
+				agent.logs = append(agent.logs, "Nothing left to clean within reach")
+				break
+			}
+			agent.wait()
+			continue
+		}
+
+		if target[0] == agent.posX && target[1] == agent.posY {
+			if agent.vacuumIfDirty() == 0 {
+				break
+			}
+			continue
+		}
+
+		before := len(agent.steps)
+		directionArrayToAction(firstMove(route, target[0], target[1]))(&agent)
+		if len(agent.steps) == before {
+			break // not enough battery to move
+		}
+	}
+
+	return agent.result(), nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Tiles of the agent as comma separated rows, the way testState takes them
+func tileRows(agent *Agent) []string {
+	rows := []string{}
+	for _, row := range agent.tiles {
+		cells := []string{}
+		for _, tile := range row {
+			cells = append(cells, fmt.Sprint(tile))
+		}
+		rows = append(rows, strings.Join(cells, ","))
+	}
+	return rows
+}
+
+func TestParseRegrowth(t *testing.T) {
+	tests := []struct {
+		cell   string
+		growth [2]int
+		ok     bool
+	}{
+		{"3", [2]int{3, 1}, true},
+		{" 2 / 5 ", [2]int{2, 5}, true},
+		{"0", [2]int{0, 1}, true},
+		{"9000/1", [2]int{9000, 1}, true},
+		{"9001", [2]int{}, false},
+		{"-1", [2]int{}, false},
+		{"4/0", [2]int{}, false},
+		{"a/2", [2]int{}, false},
+		{"", [2]int{}, false},
+	}
+	for _, test := range tests {
+		growth, err := parseRegrowth(test.cell)
+		if (err == nil) != test.ok || growth != test.growth {
+			t.Errorf("%q parsed as %v (error %v), want %v", test.cell, growth, err, test.growth)
+		}
+	}
+}
+
+func TestEnvironmentAdvance(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     []string
+		regrowth [][]string
+		events   []DirtEvent
+		turns    int
+		tiles    []string
+		appeared int
+		logs     int
+		average  float64 // dirt on the map averaged over the turns
+	}{
+		{"every turn", []string{"0,1"}, [][]string{{"0", "3"}}, nil, 4, []string{"0,13"}, 12, 0, (1 + 4 + 7 + 10 + 13) / 5.0},
+		{"every third turn", []string{"0,0"}, [][]string{{"2/3", "0"}}, nil, 7, []string{"4,0"}, 4, 0, (0 + 0 + 0 + 2 + 2 + 2 + 4 + 4) / 8.0},
+		{"walls and docks stay", []string{"0,9001,9002"}, [][]string{{"0", "5", "5"}}, nil, 3, []string{"0,9001,9002"}, 0, 0, 0},
+		{"capped below a wall", []string{"0,8998"}, [][]string{{"0", "5"}}, nil, 2, []string{"0,9000"}, 2, 0, (8998 + 9000 + 9000) / 3.0},
+		{"events in step order", []string{"0,0"},
+			nil, []DirtEvent{{Step: 3, X: 1, Y: 0, Dirt: 5}, {Step: 1, X: 1, Y: 0, Dirt: 7}, {Step: 9, X: 0, Y: 0, Dirt: 1}},
+			4, []string{"0,12"}, 12, 2, (0 + 7 + 7 + 12 + 12) / 5.0},
+		{"regrowth and an event", []string{"0,0", "0,0"},
+			[][]string{{"0", "0"}, {"1/2", "0"}}, []DirtEvent{{Step: 2, X: 0, Y: 1, Dirt: 4}},
+			2, []string{"0,0", "5,0"}, 5, 1, (0 + 0 + 5) / 3.0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := testState(10, 1, 1, test.rows...)
+			state.Regrowth, state.Events = test.regrowth, test.events
+			agent, err := CreateAgent(state, RunOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			logs := 0
+			for turn := 0; turn < test.turns; turn++ {
+				logs += len(agent.env.advance(agent.tiles))
+			}
+			if tiles := tileRows(&agent); strings.Join(tiles, ";") != strings.Join(test.tiles, ";") {
+				t.Errorf("tiles %v, want %v", tiles, test.tiles)
+			}
+			if agent.env.appeared != test.appeared || logs != test.logs {
+				t.Errorf("%d dirt appeared in %d events, want %d in %d", agent.env.appeared, logs, test.appeared, test.logs)
+			}
+			if average := agent.env.averageDirt(); average != test.average {
+				t.Errorf("average dirt %.3f, want %.3f", average, test.average)
+			}
+			if total := newDirtLedger(agent.tiles).total; agent.dirt.total != total {
+				t.Errorf("dirt ledger at %d, %d on the tiles", agent.dirt.total, total)
+			}
+		})
+	}
+}
+
+func TestOnlinePlanner(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    InitialState
+		horizon  int
+		regrowth [][]string
+		events   []DirtEvent
+		dirt     int
+		turns    int
+	}{
+		{"waits for an event", testState(10, 1, 1, "0,0"), 5, nil, []DirtEvent{{Step: 3, X: 1, Y: 0, Dirt: 9}}, 9, 5},
+		{"event too late to reach", testState(10, 1, 1, "0,0"), 5, nil, []DirtEvent{{Step: 4, X: 1, Y: 0, Dirt: 9}}, 0, 4},
+		{"keeps up with regrowth until the battery runs out", testState(6, 1, 1, "0,4"), 0, [][]string{{"0", "1"}}, nil, 9, 6},
+		{"horizon ends the run", testState(100, 1, 1, "0,4"), 4, [][]string{{"0", "1"}}, nil, 7, 4},
+		{"stops when nothing can appear", testState(10, 1, 1, "0,3,0"), 20, nil, nil, 3, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := test.state
+			state.Horizon, state.Regrowth, state.Events = test.horizon, test.regrowth, test.events
+			result, err := FindAndTraverseOnlinePath(state, RunOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.DirtCleaned != test.dirt || result.Turns != test.turns {
+				t.Errorf("cleaned %d dirt in %d turns, want %d in %d", result.DirtCleaned, result.Turns, test.dirt, test.turns)
+			}
+			if !result.Dynamic || result.Turns != len(result.Steps) {
+				t.Errorf("dynamic %v with %d turns for %d steps", result.Dynamic, result.Turns, len(result.Steps))
+			}
+		})
+	}
+
+	docked := testState(10, 1, 1, "0,9002")
+	docked.Horizon = 5
+	if _, err := FindAndTraverseOnlinePath(docked, RunOptions{}); err == nil {
+		t.Error("no error for a map with a dock")
+	}
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+func init() {
+	RegisterPlanner(NewPlannerFunc("exact",
+		"Branch and bound over every walk on small grids, provably the most dirt and then the most tiles visited",
+		FindAndTraverseExactPath))
+}
+
+const EXACT_MAX_DIRTY_TILES = 64       // Reachable dirty tiles the exact search accepts (one bit each)
+const EXACT_MAX_TILES = 128            // Tiles within reach of the battery the walk search accepts (one bit each)
+const EXACT_MAX_EXPANSIONS = 5_000_000 // Search nodes expanded before giving up on the instance
+const EXACT_DEADLINE_CHECKS = 1024     // Search nodes expanded between looks at the clock
+const EXACT_MAX_NEEDED = 4             // Dirty tiles the walk cannot do without that its bound orders every way
+
+type exactSearch struct {
+	targets []dirtyTile
+	dist    [][]int   // battery costs of moving between targets, the start is the last row/column
+	legs    [][][]int // tiles of the cheapest path between targets, without the first one
+
+	visits  []int // times the current route passes every tile
+	visited int   // distinct tiles on the current route, the start included
+
+	order       []int
+	bestOrder   []int
+	bestDirt    int
+	bestVisited int
+	bestLeft    int
+	seen        map[[2]uint64]int // (cleaned mask, current node) -> battery left seen there
+	expansions  int
+	deadline    time.Time
+	expired     bool // the search stopped at the deadline
+}
+
+// Branch and bound over the order in which reachable dirty tiles are vacuumed.
+// Moves between chosen tiles always follow cheapest paths, so searching over tile orders is enough
+// to find the most dirt. Of the orders found cleaning that much, the one whose paths visit the most
+// tiles is kept as the plan the walk search has to beat.
+func findExactOrder(agent *Agent) (exactSearch, error) {
+	targets := reachableDirtyTiles(agent)
+
+	if len(targets) > EXACT_MAX_DIRTY_TILES {
+		return exactSearch{}, fmt.Errorf("Instance too big for exact search: %d reachable dirty tiles, at most %d supported",
+			len(targets), EXACT_MAX_DIRTY_TILES)
+	}
+
+	// try the most valuable tiles first so good bounds are found early
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].dirt > targets[j].dirt })
+
+	nodes := append(append([]dirtyTile{}, targets...), dirtyTile{x: agent.posX, y: agent.posY})
+	dist, legs := make([][]int, len(nodes)), make([][][]int, len(nodes))
+	for i, from := range nodes {
+		route := dijkstra(agent, [][2]int{{from.x, from.y}}, false)
+		dist[i], legs[i] = make([]int, len(nodes)), make([][]int, len(nodes))
+		for j, to := range nodes {
+			dist[i][j] = route.costAt(to.x, to.y)
+			legs[i][j] = pathTiles(route, to.x, to.y)
+		}
+	}
+
+	search := exactSearch{
+		targets:     targets,
+		dist:        dist,
+		legs:        legs,
+		visits:      make([]int, agent.gridWidth()*len(agent.tiles)),
+		visited:     1,
+		bestVisited: 1,
+		bestLeft:    agent.battery,
+		seen:        make(map[[2]uint64]int),
+		deadline:    agent.deadline,
+	}
+	search.visits[agent.posY*agent.gridWidth()+agent.posX] = 1
+
+	if !search.visit(len(targets), 0, agent.battery, 0) {
+		if search.expired {
+			return exactSearch{}, fmt.Errorf("Exact search passed the deadline after %d expanded nodes", search.expansions)
+		}
+		return exactSearch{}, fmt.Errorf("Instance too big for exact search: gave up after %d expanded nodes (%d reachable dirty tiles)",
+			search.expansions, len(targets))
+	}
+	return search, nil
+}
+
+// Tiles of the path from the source of a forward dijkstra result to (x, y), the source left out
+func pathTiles(route travelCosts, x int, y int) []int {
+	current := route.index(x, y)
+	if route.cost[current] < 0 {
+		return nil
+	}
+	tiles := make([]int, route.steps[current])
+	for i := len(tiles) - 1; i >= 0; i-- {
+		tiles[i] = current
+		current = route.previous[current]
+	}
+	return tiles
+}
+
+// Depth-first step of the search. Returns false when the expansion limit was hit.
+func (search *exactSearch) visit(current int, mask uint64, battery int, dirt int) bool {
+	search.expansions++
+	if search.expansions > EXACT_MAX_EXPANSIONS {
+		return false
+	}
+	if search.expansions%EXACT_DEADLINE_CHECKS == 0 && !search.deadline.IsZero() && time.Now().After(search.deadline) {
+		search.expired = true
+		return false
+	}
+
+	if exactBetter(dirt, search.visited, battery, search.bestDirt, search.bestVisited, search.bestLeft) {
+		search.bestDirt, search.bestVisited, search.bestLeft = dirt, search.visited, battery
+		search.bestOrder = append([]int{}, search.order...)
+	}
+
+	// reaching the same dirty tiles with less battery can never clean more dirt
+	key := [2]uint64{mask, uint64(current)}
+	if seen, ok := search.seen[key]; ok && seen >= battery {
+		return true
+	}
+	search.seen[key] = battery
+
+	// optimistic bound: every remaining tile affordable on its own is cleaned, the battery only goes down
+	next := []int{}
+	boundDirt := dirt
+	for i := range search.targets {
+		if mask&(1<<uint(i)) != 0 || search.cost(current, i) > battery {
+			continue
+		}
+		next = append(next, i)
+		boundDirt += search.targets[i].dirt
+	}
+	// only the most dirt is proven here, the walk search ranks the tiles visited
+	if boundDirt <= search.bestDirt {
+		return true
+	}
+
+	for _, i := range next {
+		search.order = append(search.order, i)
+		search.walk(search.legs[current][i], 1)
+		ok := search.visit(i, mask|1<<uint(i), battery-search.cost(current, i), dirt+search.targets[i].dirt)
+		search.walk(search.legs[current][i], -1)
+		search.order = search.order[:len(search.order)-1]
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Adds the tiles of a leg to the current route (by 1) or takes them off again (by -1)
+func (search *exactSearch) walk(leg []int, by int) {
+	for _, tile := range leg {
+		if by > 0 && search.visits[tile] == 0 {
+			search.visited++
+		}
+		search.visits[tile] += by
+		if by < 0 && search.visits[tile] == 0 {
+			search.visited--
+		}
+	}
+}
+
+// Whether (dirt, visited, left) beats (bestDirt, bestVisited, bestLeft): more dirt, then more tiles visited,
+// then more battery left
+func exactBetter(dirt int, visited int, left int, bestDirt int, bestVisited int, bestLeft int) bool {
+	if dirt != bestDirt {
+		return dirt > bestDirt
+	}
+	if visited != bestVisited {
+		return visited > bestVisited
+	}
+	return left > bestLeft
+}
+
+// Battery needed to move from node "from" to target "to" and vacuum it
+func (search *exactSearch) cost(from int, to int) int {
+	return search.dist[from][to] + search.targets[to].vacuumCost
+}
+
+// Best plan of the order search as walk steps: grid indexes of the tiles moved onto, -1 for vacuuming
+func (search *exactSearch) bestSteps() []int {
+	steps := []int{}
+	current := len(search.targets)
+	for _, i := range search.bestOrder {
+		steps = append(append(steps, search.legs[current][i]...), -1)
+		current = i
+	}
+	return steps
+}
+
+// Set of tiles within reach, one bit per tile
+type tileSet [EXACT_MAX_TILES / 64]uint64
+
+func (set tileSet) with(i int) tileSet {
+	set[i/64] |= 1 << uint(i%64)
+	return set
+}
+
+func (set tileSet) has(i int) bool {
+	return set[i/64]&(1<<uint(i%64)) != 0
+}
+
+// State of the walk search, the battery aside
+type walkState struct {
+	tile    int
+	visited tileSet
+	cleaned tileSet
+}
+
+// Branch and bound over single moves and vacuums, for the most tiles visited among the plans cleaning
+// the most dirt (then the most battery left). Vacuuming a tile later rather than when first stepping on it
+// costs the same battery and never makes a move affordable that was not, so every tile is vacuumed on
+// arrival or never, and a state is the tile, the tiles visited and those of them vacuumed.
+type walkSearch struct {
+	tiles      [][2]int // tiles within reach of the battery, the start first
+	grid       []int    // tile index of every grid position, -1 out of reach
+	width      int
+	neighbors  [][]int
+	moveCost   []int // battery of moving onto each tile
+	vacuumCost []int
+	dirt       []int
+	dist       [][]int // battery of the cheapest path between tiles
+	cheapest   int     // battery of the cheapest move onto a tile
+	branch     []int   // dead-end branch of every tile, -1 for tiles with a way around (and the start)
+	heights    []int   // tiles on the longest way into every branch
+	counts     []int   // tiles of every branch counted by the current bound
+	byValue    []int   // dirty tiles, the most dirt per vacuuming battery first
+
+	mostDirt    int   // proven by the order search
+	steps       []int // current walk, tile indexes moved onto and -1 for vacuuming
+	bestSteps   []int
+	bestVisited int
+	bestLeft    int
+	seen        map[walkState]int // battery left seen in a state
+	expansions  int
+	deadline    time.Time
+	expired     bool
+}
+
+// Walk cleaning the most dirt that visits the most tiles, as grid indexes of the tiles moved onto and
+// -1 for vacuuming. An error when the instance is too big to prove it.
+func findExactWalk(agent *Agent) ([]int, error) {
+	order, err := findExactOrder(agent)
+	if err != nil {
+		return nil, err
+	}
+
+	within := dijkstraWithin(agent, [][2]int{{agent.posX, agent.posY}}, false, agent.battery, nil)
+	if len(within.order) > EXACT_MAX_TILES {
+		return nil, fmt.Errorf("Instance too big for exact search: %d tiles within reach of the battery, at most %d supported",
+			len(within.order), EXACT_MAX_TILES)
+	}
+
+	search := walkSearch{
+		grid:        make([]int, len(within.cost)),
+		width:       within.width,
+		mostDirt:    order.bestDirt,
+		bestSteps:   order.bestSteps(),
+		bestVisited: order.bestVisited,
+		bestLeft:    order.bestLeft,
+		seen:        make(map[walkState]int),
+		deadline:    agent.deadline,
+		cheapest:    -1,
+	}
+	for i := range search.grid {
+		search.grid[i] = -1
+	}
+	for _, i := range within.order { // the start is settled first
+		x, y := within.position(i)
+		search.grid[i] = len(search.tiles)
+		search.tiles = append(search.tiles, [2]int{x, y})
+		search.moveCost = append(search.moveCost, agent.moveCostTo(x, y))
+		search.vacuumCost = append(search.vacuumCost, agent.vacuumCostAt(x, y))
+		search.dirt = append(search.dirt, dirtOf(agent.tiles[y][x]))
+		if i != within.order[0] && (search.cheapest < 0 || search.moveCost[len(search.moveCost)-1] < search.cheapest) {
+			search.cheapest = search.moveCost[len(search.moveCost)-1]
+		}
+	}
+	for _, tile := range search.tiles {
+		route := dijkstraWithin(agent, [][2]int{tile}, false, agent.battery, nil)
+		dist, neighbors := make([]int, len(search.tiles)), []int{}
+		for j, to := range search.tiles {
+			dist[j] = route.costAt(to[0], to[1])
+			if dx, dy := to[0]-tile[0], to[1]-tile[1]; dx*dx+dy*dy == 1 && agent.getTileValue(to[0], to[1]) != WALL_VALUE {
+				neighbors = append(neighbors, j)
+			}
+		}
+		search.dist = append(search.dist, dist)
+		search.neighbors = append(search.neighbors, neighbors)
+	}
+	search.findBranches()
+	for i, dirt := range search.dirt {
+		if dirt > 0 {
+			search.byValue = append(search.byValue, i)
+		}
+	}
+	sort.SliceStable(search.byValue, func(a, b int) bool {
+		i, j := search.byValue[a], search.byValue[b]
+		return search.dirt[i]*search.vacuumCost[j] > search.dirt[j]*search.vacuumCost[i]
+	})
+
+	start := walkState{visited: tileSet{}.with(0)}
+	ok := true
+	if search.dirt[0] > 0 && search.vacuumCost[0] <= agent.battery {
+		search.steps = append(search.steps, -1)
+		start.cleaned = tileSet{}.with(0)
+		ok = search.visit(start, 1, agent.battery-search.vacuumCost[0], search.dirt[0])
+		search.steps, start.cleaned = search.steps[:0], tileSet{}
+	}
+	if ok {
+		ok = search.visit(start, 1, agent.battery, 0)
+	}
+	if !ok {
+		if search.expired {
+			return nil, fmt.Errorf("Exact search passed the deadline after %d expanded nodes", search.expansions)
+		}
+		return nil, fmt.Errorf("Instance too big for exact search: gave up ranking the tiles visited after %d expanded nodes (%d tiles within reach)",
+			search.expansions, len(search.tiles))
+	}
+	return search.bestSteps, nil
+}
+
+// Depth-first step of the walk search. Returns false when the expansion limit was hit.
+func (search *walkSearch) visit(state walkState, visited int, battery int, dirt int) bool {
+	search.expansions++
+	if search.expansions > EXACT_MAX_EXPANSIONS {
+		return false
+	}
+	if search.expansions%EXACT_DEADLINE_CHECKS == 0 && !search.deadline.IsZero() && time.Now().After(search.deadline) {
+		search.expired = true
+		return false
+	}
+
+	if dirt == search.mostDirt && exactBetter(dirt, visited, battery, search.mostDirt, search.bestVisited, search.bestLeft) {
+		search.bestVisited, search.bestLeft = visited, battery
+		search.bestSteps = search.bestSteps[:0]
+		for _, step := range search.steps {
+			if step >= 0 {
+				step = search.tiles[step][1]*search.width + search.tiles[step][0]
+			}
+			search.bestSteps = append(search.bestSteps, step)
+		}
+	}
+
+	// the same tiles visited and vacuumed with less battery leave no better way on
+	if seen, ok := search.seen[state]; ok && seen >= battery {
+		return true
+	}
+	search.seen[state] = battery
+
+	// optimistic bound: every tile not visited yet that is affordable on its own gets visited and,
+	// when dirty, vacuumed, but no more tiles than the battery pays for (see tileBound)
+	boundDirt, fresh, twice, same := dirt, 0, 0, 0
+	before := battery // battery spent before the first new tile, at least
+	for i := range search.counts {
+		search.counts[i] = 0
+	}
+	for i, dist := range search.dist[state.tile] {
+		if state.visited.has(i) || dist < 0 || dist > battery {
+			continue
+		}
+		fresh++
+		if search.color(i) == search.color(state.tile) {
+			same++
+		}
+		if branch := search.branch[i]; branch >= 0 && branch != search.branch[state.tile] {
+			search.counts[branch]++
+			twice++
+		}
+		if dist-search.moveCost[i] < before {
+			before = dist - search.moveCost[i]
+		}
+		if search.dirt[i] > 0 && dist+search.vacuumCost[i] <= battery {
+			boundDirt += search.dirt[i]
+		}
+	}
+	boundVisited := visited + fresh
+	if search.cheapest > 0 && battery >= before {
+		spend := battery - search.vacuumNeeded(state, battery, search.mostDirt-dirt)
+		boundVisited = visited + search.tileBound(spend/search.cheapest, (spend-before)/search.cheapest, fresh, twice, same)
+	}
+	if boundDirt < search.mostDirt || boundVisited <= search.bestVisited {
+		return true
+	}
+
+	// the dirty tiles the lacking dirt cannot do without have to be reached one after the other
+	needed, count := [EXACT_MAX_NEEDED]int{}, 0
+	for _, i := range search.byValue {
+		if count < len(needed) && !state.visited.has(i) && search.dist[state.tile][i] >= 0 &&
+			search.dist[state.tile][i]+search.vacuumCost[i] <= battery && boundDirt-search.dirt[i] < search.mostDirt {
+			needed[count] = i
+			count++
+		}
+	}
+	neighbors := search.neighbors[state.tile]
+	if count > 0 {
+		cost, first := search.tourCost(state.tile, needed[:count])
+		if cost > battery {
+			return true
+		}
+		// heading for the first of them finds walks cleaning the most dirt early
+		neighbors = append([]int{}, neighbors...)
+		sort.SliceStable(neighbors, func(a, b int) bool {
+			return search.dist[neighbors[a]][first] < search.dist[neighbors[b]][first]
+		})
+	}
+
+	// new tiles first
+	for _, fresh := range []bool{true, false} {
+		for _, next := range neighbors {
+			cost := search.moveCost[next]
+			if state.visited.has(next) == fresh || cost > battery {
+				continue
+			}
+
+			child := walkState{tile: next, visited: state.visited.with(next), cleaned: state.cleaned}
+			search.steps = append(search.steps, next)
+			if !fresh {
+				if !search.visit(child, visited, battery-cost, dirt) {
+					return false
+				}
+				search.steps = search.steps[:len(search.steps)-1]
+				continue
+			}
+
+			ok := true
+			if search.dirt[next] > 0 && cost+search.vacuumCost[next] <= battery {
+				search.steps = append(search.steps, -1)
+				vacuumed := child
+				vacuumed.cleaned = state.cleaned.with(next)
+				ok = search.visit(vacuumed, visited+1, battery-cost-search.vacuumCost[next], dirt+search.dirt[next])
+				search.steps = search.steps[:len(search.steps)-1]
+			}
+			if !ok || !search.visit(child, visited+1, battery-cost, dirt) {
+				return false
+			}
+			search.steps = search.steps[:len(search.steps)-1]
+		}
+	}
+
+	return true
+}
+
+// Battery of the cheapest way from a tile through all the given tiles, vacuuming each, and the tile it goes to first
+func (search *walkSearch) tourCost(from int, tiles []int) (int, int) {
+	best, first := -1, -1
+	for i, to := range tiles {
+		cost := search.dist[from][to] + search.vacuumCost[to]
+		if len(tiles) > 1 {
+			rest := append(append([]int{}, tiles[:i]...), tiles[i+1:]...)
+			more, _ := search.tourCost(to, rest)
+			cost += more
+		}
+		if best < 0 || cost < best {
+			best, first = cost, to
+		}
+	}
+	return best, first
+}
+
+// Splits off the dead-end branches: trees of tiles hanging off the rest of the tiles (or off the start)
+// by a single tile. Leaving a branch goes back over every branch tile visited, only the branch the walk
+// ends in and the one it is in can be left without.
+func (search *walkSearch) findBranches() {
+	degrees, leaves := make([]int, len(search.tiles)), []int{}
+	for i, neighbors := range search.neighbors {
+		degrees[i] = len(neighbors)
+		if i > 0 && degrees[i] <= 1 {
+			leaves = append(leaves, i)
+		}
+	}
+
+	// peeling leaves until only tiles on cycles, and the ways between them and the start, are left
+	peeled := make([]bool, len(search.tiles))
+	for len(leaves) > 0 {
+		leaf := leaves[len(leaves)-1]
+		leaves = leaves[:len(leaves)-1]
+		peeled[leaf] = true
+		for _, next := range search.neighbors[leaf] {
+			if degrees[next]--; next > 0 && !peeled[next] && degrees[next] == 1 {
+				leaves = append(leaves, next)
+			}
+		}
+	}
+
+	// a branch starts at every peeled tile next to one that is left, its tiles are reached through that one
+	search.branch = make([]int, len(search.tiles))
+	depths, queue := make([]int, len(search.tiles)), []int{}
+	for i := range search.tiles {
+		search.branch[i] = -1
+		if !peeled[i] {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range search.neighbors[current] {
+			if !peeled[next] || search.branch[next] >= 0 {
+				continue
+			}
+			search.branch[next], depths[next] = search.branch[current], depths[current]+1
+			if !peeled[current] {
+				search.branch[next] = len(search.heights)
+				search.heights = append(search.heights, 0)
+			}
+			if depths[next] > search.heights[search.branch[next]] {
+				search.heights[search.branch[next]] = depths[next]
+			}
+			queue = append(queue, next)
+		}
+	}
+	search.counts = make([]int, len(search.heights))
+}
+
+// Most new tiles a walk gets to in the given moves, of which the last ones are made from the first move
+// onto a new tile on, out of fresh ones within reach of which twice are in branches it is not in and same
+// have the color of the current tile on the checkerboard. A new tile takes a move, a branch tile one more to
+// leave the branch again, except on the way into the branch the walk ends in. Every move changes the color,
+// so at most every second one is onto a tile of the current color.
+func (search *walkSearch) tileBound(total int, moves int, fresh int, twice int, same int) int {
+	if moves < 0 {
+		return 0
+	}
+	colors := fresh
+	if same > total/2 {
+		colors -= same - total/2
+	}
+	if other := fresh - same; other > (total+1)/2 {
+		colors -= other - (total+1)/2
+	}
+
+	ending := 0
+	for branch, count := range search.counts {
+		if count > ending && search.heights[branch] > ending {
+			ending = count
+			if search.heights[branch] < ending {
+				ending = search.heights[branch]
+			}
+		}
+	}
+	branches := fresh
+	if once := fresh - twice + ending; moves <= once {
+		branches = moves
+	} else if once+(moves-once)/2 < fresh {
+		branches = once + (moves-once)/2
+	}
+
+	if colors < branches {
+		return colors
+	}
+	return branches
+}
+
+// Battery the walk still has to spend vacuuming to clean the dirt it lacks, at least: the tiles with the
+// most dirt per battery come first and the last one counts only in part
+func (search *walkSearch) vacuumNeeded(state walkState, battery int, lacking int) int {
+	needed := 0
+	for _, i := range search.byValue {
+		if lacking <= 0 {
+			break
+		}
+		if state.visited.has(i) || search.dist[state.tile][i] < 0 || search.dist[state.tile][i]+search.vacuumCost[i] > battery {
+			continue
+		}
+		if search.dirt[i] >= lacking {
+			needed += search.vacuumCost[i] * lacking / search.dirt[i]
+			break
+		}
+		needed += search.vacuumCost[i]
+		lacking -= search.dirt[i]
+	}
+	return needed
+}
+
+// Checkerboard color of a tile, a move always goes to the other one
+func (search *walkSearch) color(i int) int {
+	return (search.tiles[i][0] ^ search.tiles[i][1]) & 1
+}
+
+// FindAndTraverseExactPath finds a path for the task goals on small grids:
+// Primary Goal: Clean as much dirt as possible.
+// Secondary Goal: Clear (visit and clean) as many squares as possible.
+// Unlike FindAndTraverseOptimalPath it searches every order of reachable dirty tiles (with pruning) for the
+// most dirt, then every walk cleaning that much for the most tiles visited, so both are provably the most
+// there is. It is meant as a ground truth for the heuristics rather than for large maps, which get an error.
+func FindAndTraverseExactPath(initialState InitialState, options RunOptions) (PlanResult, error) {
+	agent, err := CreateAgent(initialState, options)
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	if err := agent.rejectDocks("exact"); err != nil {
+		return PlanResult{}, err
+	}
+
+	agent.logs = append(agent.logs, fmt.Sprintf("Initial position: (%d, %d)", agent.posX, agent.posY))
+
+	steps, err := findExactWalk(&agent)
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	width := agent.gridWidth()
+	for _, step := range steps {
+		if step < 0 {
+			agent.vacuumIfDirty()
+		} else if !walkTiles(&agent, []int{step}, width) {
+			walkTo(&agent, routeTo(&agent, step%width, step/width)) // a slip took the agent off the planned path
+		}
+	}
+
+	return agent.result(), nil
+}
+
+// Moves the agent along the tiles, false when a move did not happen or ended elsewhere
+func walkTiles(agent *Agent, tiles []int, width int) bool {
+	for _, tile := range tiles {
+		x, y := tile%width, tile/width
+		before := len(agent.steps)
+		directionArrayToAction([2]int{y - agent.posY, x - agent.posX})(agent)
+		if len(agent.steps) == before || agent.posX != x || agent.posY != y {
+			return false
+		}
+	}
+	return true
+}
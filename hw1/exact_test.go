@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Map starting at (0, 0) with rows of comma separated tile values
+func testState(battery int, movementCost int, vacuumingCost int, rows ...string) InitialState {
+	tiles := [][]string{}
+	for _, row := range rows {
+		tiles = append(tiles, strings.Split(row, ","))
+	}
+	return InitialState{Battery: battery, MovementCost: movementCost, VacuumingCost: vacuumingCost, Tiles: tiles}
+}
+
+// Most dirt any sequence of moves and vacuums cleans and the most tiles visited (the start included) while
+// cleaning that much, by trying them all. Plain floor and a movement cost of at least 1, so every state is
+// visited with less battery than the one before.
+func bruteForceBest(initialState InitialState) (int, int) {
+	agent, _ := CreateAgent(initialState, RunOptions{})
+	width := agent.gridWidth()
+	dirty := map[[2]int]int{}
+	for y, row := range agent.tiles {
+		for x, tile := range row {
+			if dirtOf(tile) > 0 {
+				dirty[[2]int{x, y}] = len(dirty)
+			}
+		}
+	}
+
+	// dirt cleaned and new tiles visited from here on
+	memo := map[[5]int][2]int{}
+	var best func(x int, y int, battery int, cleaned int, visited int) [2]int
+	best = func(x int, y int, battery int, cleaned int, visited int) [2]int {
+		key := [5]int{x, y, battery, cleaned, visited}
+		if most, ok := memo[key]; ok {
+			return most
+		}
+
+		most := [2]int{}
+		if i, ok := dirty[[2]int{x, y}]; ok && cleaned&(1<<i) == 0 && battery >= agent.vacuumingCost {
+			most = best(x, y, battery-agent.vacuumingCost, cleaned|1<<i, visited)
+			most[0] += agent.tiles[y][x]
+		}
+		for _, dir := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			nx, ny := x+dir[1], y+dir[0]
+			if agent.getTileValue(nx, ny) == WALL_VALUE || battery < agent.movementCost {
+				continue
+			}
+			next := best(nx, ny, battery-agent.movementCost, cleaned, visited|1<<(ny*width+nx))
+			if visited&(1<<(ny*width+nx)) == 0 {
+				next[1]++
+			}
+			if next[0] > most[0] || next[0] == most[0] && next[1] > most[1] {
+				most = next
+			}
+		}
+
+		memo[key] = most
+		return most
+	}
+	most := best(agent.posX, agent.posY, agent.battery, 0, 1<<(agent.posY*width+agent.posX))
+	return most[0], most[1] + 1
+}
+
+func TestExactMatchesBruteForce(t *testing.T) {
+	tests := []struct {
+		name  string
+		state InitialState
+	}{
+		{"single tile", testState(5, 1, 1, "7")},
+		{"corridor", testState(4, 1, 1, "0,3,0,9")},
+		{"detour beats the neighbor", testState(6, 1, 1, "0,1,9001", "0,9001,9001", "0,0,50")},
+		{"walled off", testState(20, 1, 1, "0,9001,40", "5,9001,0")},
+		{"free vacuuming", testState(5, 1, 0, "0,2,3", "4,9001,5", "6,7,8")},
+		{"no battery", testState(0, 1, 1, "4,5")},
+		{"expensive vacuum", testState(9, 1, 4, "0,10,0", "20,0,30")},
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 60; i++ {
+		width, height := 1+random.Intn(4), 1+random.Intn(3)
+		rows := []string{}
+		for y := 0; y < height; y++ {
+			row := []string{}
+			for x := 0; x < width; x++ {
+				switch roll := random.Intn(6); {
+				case x == 0 && y == 0, roll < 2:
+					row = append(row, "0")
+				case roll == 2:
+					row = append(row, "9001")
+				default:
+					row = append(row, fmt.Sprint(1+random.Intn(30)))
+				}
+			}
+			rows = append(rows, strings.Join(row, ","))
+		}
+		tests = append(tests, struct {
+			name  string
+			state InitialState
+		}{fmt.Sprintf("random %d", i), testState(random.Intn(12), 1+random.Intn(2), random.Intn(3), rows...)})
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := FindAndTraverseExactPath(test.state, RunOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			visited := map[[2]int]bool{{result.StartX, result.StartY}: true}
+			for _, step := range result.Steps {
+				visited[[2]int{step.X, step.Y}] = true
+			}
+			if dirt, tiles := bruteForceBest(test.state); result.DirtCleaned != dirt || len(visited) != tiles {
+				t.Errorf("exact cleaned %d dirt visiting %d tiles, brute force %d and %d", result.DirtCleaned, len(visited), dirt, tiles)
+			}
+			if result.BatteryLeft < 0 {
+				t.Errorf("battery went negative: %d", result.BatteryLeft)
+			}
+		})
+	}
+}
+
+// The battery left after the most dirt walks to tiles not visited yet
+func TestExactVisitsNewTiles(t *testing.T) {
+	// both dirty tiles take 6 battery, the 3 left walk around the rest of the loop
+	state := testState(9, 1, 1, "0,5,0", "0,9001,0", "0,0,5")
+	result, err := FindAndTraverseExactPath(state, RunOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	visited := map[[2]int]bool{{0, 0}: true}
+	for _, step := range result.Steps {
+		visited[[2]int{step.X, step.Y}] = true
+	}
+	if result.DirtCleaned != 10 || len(visited) != 8 {
+		t.Errorf("cleaned %d dirt visiting %d tiles, want 10 and 8", result.DirtCleaned, len(visited))
+	}
+}
+
+func TestExactRejectsDocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		state InitialState
+	}{
+		{"dock", testState(5, 1, 1, "0,9002,4")},
+		{"end on dock", InitialState{Battery: 5, MovementCost: 1, VacuumingCost: 1, MustEndOnDock: true,
+			Tiles: [][]string{{"0", "9002", "4"}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := FindAndTraverseExactPath(test.state, RunOptions{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+)
+
+const EXPLORE_DEFAULT_SENSOR_RADIUS = 1 // Sensor radius of the explore planner when the map does not limit it
+
+func init() {
+	RegisterPlanner(NewPlannerFunc("explore",
+		"Sees only tiles within the sensor radius, cleans known dirt and explores the nearest frontier otherwise",
+		FindAndTraverseExplorePath))
+}
+
+// First move (direction [dy, dx]) on the path from the search source to (x, y) of a forward dijkstra result
+func firstMove(route travelCosts, x int, y int) [2]int {
+	current := route.index(x, y)
+	for route.steps[route.previous[current]] > 0 {
+		current = route.previous[current]
+	}
+	cx, cy := route.position(current)
+	px, py := route.position(route.previous[current])
+	return [2]int{cy - py, cx - px}
+}
+
+// FindAndTraverseExplorePath cleans a map it can only partly see. The agent keeps a belief map of the
+// tiles it has seen (within the sensor radius of every tile it stood on) and plans on it alone, unseen
+// tiles count as walls. Every turn it either heads for the known dirty tile with the most dirt per battery,
+// or when no known dirt is within reach, for the cheapest frontier: a known floor tile next to unseen ones.
+// It replans after every move since each move may reveal walls and dirt.
+func FindAndTraverseExplorePath(initialState InitialState, options RunOptions) (PlanResult, error) {
+	if initialState.SensorRadius == 0 {
+		initialState.SensorRadius = EXPLORE_DEFAULT_SENSOR_RADIUS
+	}
+
+	agent, err := CreateAgent(initialState, options)
+	if err != nil {
+		return PlanResult{}, err
+	}
+	if err := agent.rejectDocks("explore"); err != nil {
+		return PlanResult{}, err
+	}
+
+	agent.logs = append(agent.logs, fmt.Sprintf("Initial position: (%d, %d)", agent.posX, agent.posY))
+
+	for {
+		view := agent.beliefView()
+		route := dijkstraWithin(&view, [][2]int{{agent.posX, agent.posY}}, false, agent.battery, nil)
+
+		// best known dirt per battery, travel and vacuuming included
+		target, targetDirt, targetCost := [2]int{-1, -1}, 0, 0
+		for _, i := range route.order {
+			x, y := route.position(i)
+			dirt := dirtOf(view.tiles[y][x])
+			cost := route.cost[i] + agent.vacuumCostAt(x, y)
+			if dirt == 0 || cost > agent.battery {
+				continue
+			}
+			if target[0] < 0 || betterValue(dirt, cost, targetDirt, targetCost) {
+				target, targetDirt, targetCost = [2]int{x, y}, dirt, cost
+			}
+		}
+
+		if target[0] < 0 {
+			// cheapest frontier, settled order breaks ties towards fewer moves
+			for _, i := range route.order {
+				x, y := route.position(i)
+				if route.cost[i] <= agent.battery && route.steps[i] > 0 && agent.isFrontier(x, y) {
+					target = [2]int{x, y}
+					break
+				}
+			}
+		}
+
+		if target[0] < 0 {
+			agent.logs = append(agent.logs, "Nothing known to clean or explore within reach")
+			break
+		}
+
+		if target[0] == agent.posX && target[1] == agent.posY {
+			if agent.vacuumIfDirty() == 0 {
+				break
+			}
+			continue
+		}
+
+		before := len(agent.steps)
+		directionArrayToAction(firstMove(route, target[0], target[1]))(&agent)
+		if len(agent.steps) == before {
+			break // not enough battery to move
+		}
+	}
+
+	return agent.result(), nil
+}
+
+// Whether (x, y) is a known floor tile next to a tile that was not seen yet
+func (agent *Agent) isFrontier(x int, y int) bool {
+	if !agent.knows(x, y) || agent.getTileValue(x, y) == WALL_VALUE {
+		return false
+	}
+
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for _, dir := range directions {
+		ny, nx := y+dir[0], x+dir[1]
+		if ny >= 0 && ny < len(agent.tiles) && nx >= 0 && nx < len(agent.tiles[ny]) && !agent.knows(nx, ny) {
+			return true
+		}
+	}
+	return false
+}
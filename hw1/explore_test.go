@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Known tiles of the agent as rows, 'x' for seen and '.' for not seen
+func knownRows(agent *Agent) []string {
+	rows := []string{}
+	for y, row := range agent.tiles {
+		line := ""
+		for x := range row {
+			if agent.knows(x, y) {
+				line += "x"
+			} else {
+				line += "."
+			}
+		}
+		rows = append(rows, line)
+	}
+	return rows
+}
+
+func TestSense(t *testing.T) {
+	tests := []struct {
+		name   string
+		radius int
+		x, y   int
+		known  []string
+	}{
+		{"radius 1 in the middle", 1, 2, 2, []string{".....", "..x..", ".xxx.", "..x..", "....."}},
+		{"radius 2 is a diamond", 2, 2, 2, []string{"..x..", ".xxx.", "xxxxx", ".xxx.", "..x.."}},
+		{"cut at the corner", 1, 0, 0, []string{"xx...", "x....", ".....", ".....", "....."}},
+		{"walls are seen too", 1, 4, 1, []string{"....x", "...xx", "....x", ".....", "....."}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := testState(10, 1, 1, "0,0,0,0,9001", "0,0,0,0,0", "0,0,0,0,0", "0,0,0,0,0", "0,0,0,0,0")
+			state.X0, state.Y0, state.SensorRadius = test.x, test.y, test.radius
+			agent, err := CreateAgent(state, RunOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if known := knownRows(&agent); strings.Join(known, "\n") != strings.Join(test.known, "\n") {
+				t.Errorf("known\n%s\nwant\n%s", strings.Join(known, "\n"), strings.Join(test.known, "\n"))
+			}
+			if want := strings.Count(strings.Join(test.known, ""), "x"); agent.discovered != want {
+				t.Errorf("%d tiles discovered, want %d", agent.discovered, want)
+			}
+		})
+	}
+}
+
+func TestBeliefAndFrontier(t *testing.T) {
+	state := testState(10, 1, 1, "0,4,9001,7", "0,0,0,0", "9001,0,0,3")
+	state.SensorRadius = 1
+	agent, err := CreateAgent(state, RunOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.moveRight()
+
+	// seen tiles keep their value, the rest are walls until seen
+	view := agent.beliefView()
+	want := [][]int{{0, 4, WALL_VALUE, WALL_VALUE}, {0, 0, WALL_VALUE, WALL_VALUE}, {WALL_VALUE, WALL_VALUE, WALL_VALUE, WALL_VALUE}}
+	for y, row := range want {
+		for x, tile := range row {
+			if view.tiles[y][x] != tile {
+				t.Errorf("belief at (%d, %d) is %d, want %d", x, y, view.tiles[y][x], tile)
+			}
+		}
+	}
+	if agent.tiles[2][3] != 3 {
+		t.Error("the belief view changed the agent's own tiles")
+	}
+
+	tests := []struct {
+		name     string
+		x, y     int
+		frontier bool
+	}{
+		{"seen floor next to unseen floor", 1, 1, true},
+		{"seen dirt next to a seen wall only", 1, 0, false},
+		{"seen floor with every neighbor seen", 0, 0, false},
+		{"seen wall", 2, 0, false},
+		{"unseen floor", 2, 1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := agent.isFrontier(test.x, test.y); got != test.frontier {
+				t.Errorf("frontier %v, want %v", got, test.frontier)
+			}
+		})
+	}
+}
+
+func TestExploreOnlyWalksSeenTiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		state  InitialState
+		radius int
+		dirt   int // cleaned when the battery is enough to explore everything
+	}{
+		{"room", testState(60, 1, 1, "0,0,0,5", "0,9001,0,0", "2,0,0,9"), 1, 16},
+		{"dirt down a corridor", testState(60, 1, 1, "0,9001,0,0,0", "0,9001,0,9001,0", "0,0,0,9001,40"), 1, 40},
+		{"wide sensor", testState(60, 1, 1, "0,0,0,5", "0,9001,0,0", "2,0,0,9"), 3, 16},
+		{"out of battery", testState(3, 1, 1, "0,0,0,0,0,0,50"), 1, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := test.state
+			state.SensorRadius = test.radius
+			result, err := FindAndTraverseExplorePath(state, RunOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.DirtCleaned != test.dirt {
+				t.Errorf("cleaned %d dirt, want %d", result.DirtCleaned, test.dirt)
+			}
+
+			// every move goes onto a tile within the sensor radius of a tile stood on before
+			stood := [][2]int{{result.StartX, result.StartY}}
+			for i, step := range result.Steps {
+				seen := false
+				for _, tile := range stood {
+					dx, dy := step.X-tile[0], step.Y-tile[1]
+					seen = seen || sign(dx)*dx+sign(dy)*dy <= test.radius
+				}
+				if !seen {
+					t.Fatalf("step %d went to (%d, %d) before it was seen", i+1, step.X, step.Y)
+				}
+				stood = append(stood, [2]int{step.X, step.Y})
+			}
+		})
+	}
+}
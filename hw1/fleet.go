@@ -0,0 +1,545 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+)
+
+var FLEET_ASSIGNMENTS = []string{"auction", "kmeans"}
+
+const FLEET_MAX_TICKS = 1_000_000 // Lockstep ticks before a fleet run is stopped
+const FLEET_DETOUR_TICKS = 3      // Ticks a blocked robot keeps walking around the other robots
+
+func init() {
+	registerCommand("fleet", "[--assign=auction|kmeans] [--output=text|json|csv] <input map>",
+		"Plans a map with several robots: splits the dirt between them and runs them in lockstep",
+		runFleet)
+}
+
+// Several agents cleaning the same tiles, advanced in lockstep
+type Fleet struct {
+	agents []*Agent
+	ticks  int
+	logs   []string
+	env    *environment // advanced once per tick rather than per robot action
+}
+
+// FleetResult holds the trajectory of every robot, robot i+1 of the map is Robots[i]
+type FleetResult struct {
+	Robots       []PlanResult
+	DirtCleaned  int
+	Ticks        int
+	Dynamic      bool
+	DirtAppeared int
+	AverageDirt  float64
+	Logs         []string
+}
+
+// CreateFleet makes one agent per robot of the map. The agents share tiles, so dirt vacuumed
+// by one robot is gone for all of them.
+func CreateFleet(initialState InitialState) (Fleet, error) {
+	first, err := CreateAgent(initialState, RunOptions{})
+	if err != nil {
+		return Fleet{}, err
+	}
+
+	fleet := Fleet{agents: []*Agent{&first}, logs: []string{}, env: first.env}
+	first.env = nil
+	for _, robot := range initialState.Robots {
+		agent := first
+		agent.posX, agent.posY, agent.startX, agent.startY = robot.X, robot.Y, robot.X, robot.Y
+		agent.battery, agent.startBattery, agent.capacity = robot.Battery, robot.Battery, robot.Battery
+		agent.steps, agent.logs = []Step{}, []string{}
+		if agent.known != nil {
+			agent.known, agent.discovered = make([][]bool, len(agent.tiles)), 0
+			for y := range agent.tiles {
+				agent.known[y] = make([]bool, len(agent.tiles[y]))
+			}
+			agent.sense()
+		}
+		fleet.agents = append(fleet.agents, &agent)
+	}
+
+	if first.random != nil {
+		// the robots share one random source, lockstep order keeps the runs reproducible
+		occupied := func(x int, y int) bool { return fleet.robotAt(x, y) >= 0 }
+		for _, agent := range fleet.agents {
+			agent.occupied = occupied
+		}
+	}
+
+	return fleet, nil
+}
+
+// Index of the robot standing on (x, y), -1 for none
+func (fleet *Fleet) robotAt(x int, y int) int {
+	for i, agent := range fleet.agents {
+		if agent.posX == x && agent.posY == y {
+			return i
+		}
+	}
+	return -1
+}
+
+// Step advances the fleet by one tick, actions[i] is what robot i does ("" for a robot that is done).
+// Robots act in index order. A move onto a tile another robot stands on is refused and the robot waits,
+// so robots never share a tile and a dirty tile two robots head for goes to the one that enters it first,
+// the lower index when both arrive in the same tick. Returns which actions took effect.
+func (fleet *Fleet) Step(actions []Action) []bool {
+	performed := make([]bool, len(fleet.agents))
+	for i, agent := range fleet.agents {
+		if actions[i] == "" {
+			continue
+		}
+
+		dx, dy := actionDelta(actions[i])
+		if (dx != 0 || dy != 0) && fleet.robotAt(agent.posX+dx, agent.posY+dy) >= 0 {
+			agent.wait()
+			continue
+		}
+
+		before := len(agent.steps)
+		agent.perform(actions[i])
+		performed[i] = len(agent.steps) > before && actions[i] != ActionWait
+		if len(agent.steps) == before {
+			agent.wait()
+		}
+	}
+
+	fleet.ticks++
+	if fleet.env != nil {
+		fleet.logs = append(fleet.logs, fleet.env.advance(fleet.agents[0].tiles)...)
+	}
+	return performed
+}
+
+func (fleet *Fleet) result() FleetResult {
+	result := FleetResult{Ticks: fleet.ticks, Logs: fleet.logs}
+	for _, agent := range fleet.agents {
+		robot := agent.result()
+		result.Robots = append(result.Robots, robot)
+		result.DirtCleaned += robot.DirtCleaned
+	}
+	if fleet.env != nil {
+		result.Dynamic, result.DirtAppeared, result.AverageDirt = true, fleet.env.appeared, fleet.env.averageDirt()
+	}
+	return result
+}
+
+// Dirty tiles reachable by at least one robot, in row order
+func fleetDirtyTiles(fleet *Fleet) []dirtyTile {
+	reached := map[[2]int]dirtyTile{}
+	for _, agent := range fleet.agents {
+		for _, tile := range reachableDirtyTiles(agent) {
+			reached[[2]int{tile.x, tile.y}] = tile
+		}
+	}
+
+	tiles := []dirtyTile{}
+	for y, row := range fleet.agents[0].tiles {
+		for x := range row {
+			if tile, ok := reached[[2]int{x, y}]; ok {
+				tiles = append(tiles, tile)
+			}
+		}
+	}
+	return tiles
+}
+
+// Sequential single-item auction: every round each robot bids for every unassigned tile with the dirt
+// per extra battery of inserting it into its own tour, the best bid wins. Tours are shortened with the
+// orienteering local search between rounds, which can make room for more tiles.
+func auctionTours(fleet *Fleet, tiles []dirtyTile) [][]dirtyTile {
+	nodes := append([]dirtyTile{}, tiles...)
+	for _, agent := range fleet.agents {
+		nodes = append(nodes, dirtyTile{x: agent.posX, y: agent.posY})
+	}
+	dist := tileTravelCosts(fleet.agents[0], nodes)
+
+	problems := make([]orienteering, len(fleet.agents))
+	tours := make([][]int, len(fleet.agents))
+	for r, agent := range fleet.agents {
+		problems[r] = orienteering{tiles: tiles, dist: dist, start: len(tiles) + r, battery: agent.battery}
+		tours[r] = []int{}
+	}
+
+	assigned := make([]bool, len(tiles))
+	for {
+		for {
+			bestRobot, bestTile, bestPos, bestExtra := -1, -1, -1, 0
+			for r := range problems {
+				problem := &problems[r]
+				cost := problem.tourCost(tours[r])
+				for tile := range tiles {
+					if assigned[tile] || dist[problem.start][tile] < 0 {
+						continue
+					}
+
+					for pos := 0; pos <= len(tours[r]); pos++ {
+						extra := problem.insertionCost(tours[r], tile, pos)
+						if cost+extra > problem.battery {
+							continue
+						}
+
+						// same bid comparison as greedyInsertion, dirt per extra battery without dividing
+						dirt, bestDirt := tiles[tile].dirt, 0
+						if bestTile != -1 {
+							bestDirt = tiles[bestTile].dirt
+						}
+						if bestTile == -1 || dirt*bestExtra > bestDirt*extra ||
+							(dirt*bestExtra == bestDirt*extra && (extra < bestExtra || (extra == bestExtra && dirt > bestDirt))) {
+							bestRobot, bestTile, bestPos, bestExtra = r, tile, pos, extra
+						}
+					}
+				}
+			}
+
+			if bestTile == -1 {
+				break
+			}
+			tours[bestRobot] = append(tours[bestRobot][:bestPos], append([]int{bestTile}, tours[bestRobot][bestPos:]...)...)
+			assigned[bestTile] = true
+		}
+
+		improved := false
+		for r := range problems {
+			improved = problems[r].improve(tours[r]) || improved
+		}
+		if !improved {
+			break
+		}
+	}
+
+	result := make([][]dirtyTile, len(tours))
+	for r, tour := range tours {
+		for _, tile := range tour {
+			result[r] = append(result[r], tiles[tile])
+		}
+	}
+	return result
+}
+
+// k-means over tile positions weighted by dirt with one cluster per robot, seeded at the robot starts.
+// A tile only joins clusters of robots that can reach it. Every robot then solves the orienteering
+// problem over its own cluster.
+func kmeansTours(fleet *Fleet, tiles []dirtyTile) [][]dirtyTile {
+	reach := make([][][]int, len(fleet.agents))
+	centers := make([][2]float64, len(fleet.agents))
+	for r, agent := range fleet.agents {
+		reach[r] = bfsDistances(agent, agent.posX, agent.posY)
+		centers[r] = [2]float64{float64(agent.posX), float64(agent.posY)}
+	}
+
+	cluster := make([]int, len(tiles))
+	for i := range cluster {
+		cluster[i] = -1
+	}
+
+	for iteration := 0; iteration < 100; iteration++ {
+		changed := false
+		for i, tile := range tiles {
+			best, bestDistance := -1, math.Inf(1)
+			for r := range fleet.agents {
+				if reach[r][tile.y][tile.x] < 0 {
+					continue
+				}
+				dx, dy := float64(tile.x)-centers[r][0], float64(tile.y)-centers[r][1]
+				if distance := dx*dx + dy*dy; distance < bestDistance {
+					best, bestDistance = r, distance
+				}
+			}
+			if cluster[i] != best {
+				cluster[i], changed = best, true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([][3]float64, len(fleet.agents))
+		for i, tile := range tiles {
+			weight := float64(tile.dirt)
+			sums[cluster[i]][0] += weight * float64(tile.x)
+			sums[cluster[i]][1] += weight * float64(tile.y)
+			sums[cluster[i]][2] += weight
+		}
+		for r, sum := range sums {
+			if sum[2] > 0 {
+				centers[r] = [2]float64{sum[0] / sum[2], sum[1] / sum[2]}
+			}
+		}
+	}
+
+	result := make([][]dirtyTile, len(fleet.agents))
+	for r, agent := range fleet.agents {
+		own := []dirtyTile{}
+		for i, tile := range tiles {
+			if cluster[i] == r {
+				own = append(own, tile)
+			}
+		}
+
+		problem := orienteering{
+			tiles:   own,
+			dist:    tileTravelCosts(agent, append(append([]dirtyTile{}, own...), dirtyTile{x: agent.posX, y: agent.posY})),
+			start:   len(own),
+			battery: agent.battery,
+		}
+		for _, tile := range problem.solve() {
+			result[r] = append(result[r], own[tile])
+		}
+	}
+	return result
+}
+
+// Drives every robot through its tour in lockstep: vacuum when on the next tile of the tour, otherwise one
+// move along the cheapest path to it. A robot that was blocked walks around the other robots for a few
+// ticks, a robot that runs out of battery or cannot reach its next tile is done. The run stops when no
+// robot has anything left to do, or when no robot got anything done for more ticks than there are robots.
+func (fleet *Fleet) follow(tours [][]dirtyTile) {
+	next := make([]int, len(fleet.agents))
+	detour := make([]int, len(fleet.agents))
+	routes := map[[2]int]travelCosts{}
+	idle := 0
+
+	for fleet.ticks < FLEET_MAX_TICKS {
+		if fleet.env != nil && fleet.env.over() {
+			fleet.logs = append(fleet.logs, fmt.Sprintf("Horizon of %d ticks reached", fleet.env.horizon))
+			return
+		}
+
+		actions := make([]Action, len(fleet.agents))
+		active := false
+		for i, agent := range fleet.agents {
+			// tiles vacuumed in the meantime are skipped
+			for next[i] < len(tours[i]) && dirtOf(agent.getTileValue(tours[i][next[i]].x, tours[i][next[i]].y)) == 0 {
+				next[i]++
+			}
+			if next[i] == len(tours[i]) {
+				continue
+			}
+
+			target := tours[i][next[i]]
+			if agent.posX == target.x && agent.posY == target.y {
+				if agent.battery >= agent.vacuumCostAt(target.x, target.y) {
+					actions[i], active = ActionVacuum, true
+				} else {
+					next[i] = len(tours[i])
+				}
+				continue
+			}
+
+			route, ok := routes[[2]int{target.x, target.y}]
+			if !ok {
+				route = routeTo(agent, target.x, target.y)
+				routes[[2]int{target.x, target.y}] = route
+			}
+			if detour[i] > 0 {
+				route = fleet.routeAround(i, target)
+			}
+
+			dir, ok := nextStep(agent, route)
+			if !ok && detour[i] > 0 {
+				actions[i], active = ActionWait, true // walled in by robots, maybe they move away
+				continue
+			}
+			if !ok || agent.moveCostTo(agent.posX+dir[1], agent.posY+dir[0]) > agent.battery {
+				next[i] = len(tours[i])
+				continue
+			}
+			actions[i], active = moveAction(dir[1], dir[0]), true
+		}
+
+		if !active {
+			return
+		}
+
+		performed := fleet.Step(actions)
+		progress := false
+		for i, action := range actions {
+			if action == "" {
+				continue
+			}
+			if performed[i] {
+				progress = true
+			}
+			if detour[i] > 0 {
+				detour[i]--
+			}
+			if !performed[i] && action != ActionWait {
+				detour[i] = FLEET_DETOUR_TICKS
+			}
+		}
+
+		if progress {
+			idle = 0
+		} else if idle++; idle > len(fleet.agents) {
+			fleet.logs = append(fleet.logs, "Robots block each other, stopping")
+			return
+		}
+	}
+
+	fleet.logs = append(fleet.logs, fmt.Sprintf("Stopped after %d ticks", fleet.ticks))
+}
+
+// Cheapest paths to the target treating the other robots as walls
+func (fleet *Fleet) routeAround(robot int, target dirtyTile) travelCosts {
+	view := *fleet.agents[robot]
+	view.tiles = make([][]int, len(view.tiles))
+	for y, row := range fleet.agents[robot].tiles {
+		view.tiles[y] = append([]int{}, row...)
+	}
+	for i, other := range fleet.agents {
+		if i != robot {
+			view.tiles[other.posY][other.posX] = WALL_VALUE
+		}
+	}
+
+	return routeTo(&view, target.x, target.y)
+}
+
+// PlanFleet splits the reachable dirt between the robots of the map with the given assignment
+// (one of FLEET_ASSIGNMENTS) and runs their tours in lockstep
+func PlanFleet(initialState InitialState, assignment string) (FleetResult, error) {
+	fleet, err := CreateFleet(initialState)
+	if err != nil {
+		return FleetResult{}, err
+	}
+	if err := fleet.agents[0].rejectDocks("fleet"); err != nil {
+		return FleetResult{}, err
+	}
+
+	tiles := fleetDirtyTiles(&fleet)
+	var tours [][]dirtyTile
+	switch assignment {
+	case "auction":
+		tours = auctionTours(&fleet, tiles)
+	case "kmeans":
+		tours = kmeansTours(&fleet, tiles)
+	default:
+		return FleetResult{}, fmt.Errorf("Unknown assignment %s, expected one of %v", assignment, FLEET_ASSIGNMENTS)
+	}
+
+	for r, tour := range tours {
+		fleet.logs = append(fleet.logs, fmt.Sprintf("Robot %d is assigned %d dirty tiles", r+1, len(tour)))
+	}
+	fleet.follow(tours)
+
+	return fleet.result(), nil
+}
+
+// Writes the fleet run in one of OUTPUT_FORMATS, robots are numbered from 1 like in the map
+func writeFleetResult(w io.Writer, format string, algorithm string, input string, result FleetResult) error {
+	switch format {
+	case "text":
+		for r, robot := range result.Robots {
+			fmt.Fprintf(w, "Robot %d:\n", r+1)
+			if err := writeText(w, robot); err != nil {
+				return err
+			}
+		}
+		if PRINT_MOVES {
+			for _, log := range result.Logs {
+				fmt.Fprintln(w, log)
+			}
+		}
+		_, err := fmt.Fprintf(w, "Total dirt cleaned: %d\nTicks: %d\n", result.DirtCleaned, result.Ticks)
+		if err == nil && result.Dynamic {
+			_, err = fmt.Fprintf(w, "Dirt appeared: %d\nAverage dirt on the map: %.2f\n", result.DirtAppeared, result.AverageDirt)
+		}
+		return err
+
+	case "json":
+		type jsonFleet struct {
+			Algorithm  string           `json:"algorithm"`
+			Input      string           `json:"input"`
+			Robots     []jsonTrajectory `json:"robots"`
+			Statistics struct {
+				DirtCleaned  int      `json:"dirtCleaned"`
+				Ticks        int      `json:"ticks"`
+				DirtAppeared *int     `json:"dirtAppeared,omitempty"`
+				AverageDirt  *float64 `json:"averageDirt,omitempty"`
+			} `json:"statistics"`
+		}
+
+		document := jsonFleet{Algorithm: algorithm, Input: input, Robots: []jsonTrajectory{}}
+		for _, robot := range result.Robots {
+			document.Robots = append(document.Robots, newJSONTrajectory("", "", robot))
+		}
+		document.Statistics.DirtCleaned, document.Statistics.Ticks = result.DirtCleaned, result.Ticks
+		if result.Dynamic {
+			document.Statistics.DirtAppeared, document.Statistics.AverageDirt = &result.DirtAppeared, &result.AverageDirt
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(document)
+
+	case "csv":
+		// the single robot CSV with a robot column in front, step i of every robot happens in tick i
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"robot", "step", "action", "x", "y", "battery_before", "battery_after", "dirt_vacuumed"})
+		for r, robot := range result.Robots {
+			id := strconv.Itoa(r + 1)
+			writer.Write([]string{id, "0", "start", strconv.Itoa(robot.StartX), strconv.Itoa(robot.StartY),
+				strconv.Itoa(robot.Battery), strconv.Itoa(robot.Battery), "0"})
+			for i, step := range robot.Steps {
+				writer.Write([]string{id, strconv.Itoa(i + 1), string(step.Action), strconv.Itoa(step.X), strconv.Itoa(step.Y),
+					strconv.Itoa(step.BatteryBefore), strconv.Itoa(step.BatteryAfter), strconv.Itoa(step.DirtVacuumed)})
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+
+		for r, robot := range result.Robots {
+			fmt.Fprintf(w, "# Robot %d dirt cleaned: %d, tiles moved: %d, battery remaining: %d\n",
+				r+1, robot.DirtCleaned, robot.TilesMoved, robot.BatteryLeft)
+		}
+		_, err := fmt.Fprintf(w, "# Dirt cleaned: %d\n# Ticks: %d\n", result.DirtCleaned, result.Ticks)
+		if err == nil && result.Dynamic {
+			_, err = fmt.Fprintf(w, "# Dirt appeared: %d\n# Average dirt on the map: %.2f\n", result.DirtAppeared, result.AverageDirt)
+		}
+		return err
+	}
+
+	return fmt.Errorf("Unknown output format %s, expected one of %v", format, OUTPUT_FORMATS)
+}
+
+func runFleet(args []string) error {
+	flags := flag.NewFlagSet("fleet", flag.ExitOnError)
+	assignment := flags.String("assign", "auction", "How dirty tiles are split between robots: auction or kmeans")
+	output := flags.String("output", "text", "Output format: text, json or csv")
+	format := flags.String("format", "", "Input map format: csv, json or ascii (default from the extension)")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return commandUsage("fleet")
+	}
+	if !isOutputFormat(*output) {
+		return fmt.Errorf("Unknown output format %s, expected one of %v", *output, OUTPUT_FORMATS)
+	}
+
+	initialState, err := ReadValidInitialState(flags.Arg(0), *format)
+	if err != nil {
+		return err
+	}
+	if len(initialState.Robots) == 0 {
+		log.Printf("%s has a single robot, other algorithms may do better on it", flags.Arg(0))
+	}
+
+	result, err := PlanFleet(initialState, *assignment)
+	if err != nil {
+		return err
+	}
+
+	return writeFleetResult(os.Stdout, *output, "fleet/"+*assignment, flags.Arg(0), result)
+}
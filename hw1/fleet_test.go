@@ -0,0 +1,170 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Robots of the map after the first, as x, y, battery
+func testFleet(state InitialState, robots ...[3]int) InitialState {
+	for _, robot := range robots {
+		state.Robots = append(state.Robots, RobotStart{X: robot[0], Y: robot[1], Battery: robot[2]})
+	}
+	return state
+}
+
+func TestFleetRejectsDocks(t *testing.T) {
+	endOnDock := testFleet(testState(5, 1, 1, "0,0,9002,4"), [3]int{1, 0, 5})
+	endOnDock.MustEndOnDock = true
+
+	tests := []struct {
+		name  string
+		state InitialState
+	}{
+		{"dock", testFleet(testState(5, 1, 1, "0,0,9002,4"), [3]int{1, 0, 5})},
+		{"end on dock", endOnDock},
+	}
+	for _, test := range tests {
+		for _, assignment := range FLEET_ASSIGNMENTS {
+			t.Run(test.name+" "+assignment, func(t *testing.T) {
+				if _, err := PlanFleet(test.state, assignment); err == nil {
+					t.Error("expected an error")
+				}
+			})
+		}
+	}
+}
+
+func TestFleetStep(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     InitialState
+		actions   []Action
+		positions [][2]int
+		performed []bool
+	}{
+		{"both move", testFleet(testState(5, 1, 1, "0,0,0", "0,0,0"), [3]int{2, 1, 5}),
+			[]Action{ActionRight, ActionLeft}, [][2]int{{1, 0}, {1, 1}}, []bool{true, true}},
+		{"same tile, lower index first", testFleet(testState(5, 1, 1, "0,0,0"), [3]int{2, 0, 5}),
+			[]Action{ActionRight, ActionLeft}, [][2]int{{1, 0}, {2, 0}}, []bool{true, false}},
+		{"onto a robot that moves away later", testFleet(testState(5, 1, 1, "0,0,0"), [3]int{1, 0, 5}),
+			[]Action{ActionRight, ActionRight}, [][2]int{{0, 0}, {2, 0}}, []bool{false, true}},
+		{"onto a robot that moved away first", testFleet(testState(5, 1, 1, "0,0", "0,0"), [3]int{1, 0, 5}),
+			[]Action{ActionDown, ActionLeft}, [][2]int{{0, 1}, {0, 0}}, []bool{true, true}},
+		{"swap", testFleet(testState(5, 1, 1, "0,0"), [3]int{1, 0, 5}),
+			[]Action{ActionRight, ActionLeft}, [][2]int{{0, 0}, {1, 0}}, []bool{false, false}},
+		{"done robot stays", testFleet(testState(5, 1, 1, "0,0,0"), [3]int{1, 0, 5}),
+			[]Action{ActionRight, ""}, [][2]int{{0, 0}, {1, 0}}, []bool{false, false}},
+		{"wall", testFleet(testState(5, 1, 1, "0,9001", "0,0"), [3]int{1, 1, 5}),
+			[]Action{ActionRight, ActionUp}, [][2]int{{0, 0}, {1, 1}}, []bool{false, false}},
+		{"vacuum and wait", testFleet(testState(5, 1, 1, "3,0"), [3]int{1, 0, 5}),
+			[]Action{ActionVacuum, ActionWait}, [][2]int{{0, 0}, {1, 0}}, []bool{true, false}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fleet, err := CreateFleet(test.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			performed := fleet.Step(test.actions)
+			positions := [][2]int{}
+			for i, agent := range fleet.agents {
+				positions = append(positions, [2]int{agent.posX, agent.posY})
+				// every robot with an action records a step for the tick, a wait when it was refused
+				if steps := len(agent.steps); test.actions[i] != "" && steps != 1 || test.actions[i] == "" && steps != 0 {
+					t.Errorf("robot %d recorded %d steps", i, steps)
+				}
+			}
+			if !reflect.DeepEqual(positions, test.positions) || !reflect.DeepEqual(performed, test.performed) {
+				t.Errorf("robots at %v performing %v, want %v and %v", positions, performed, test.positions, test.performed)
+			}
+			if fleet.ticks != 1 {
+				t.Errorf("%d ticks, want 1", fleet.ticks)
+			}
+			// the robots share the tiles, dirt one vacuums is gone for all
+			for _, agent := range fleet.agents[1:] {
+				if !reflect.DeepEqual(agent.tiles, fleet.agents[0].tiles) {
+					t.Errorf("robots see different tiles: %v and %v", agent.tiles, fleet.agents[0].tiles)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanFleet(t *testing.T) {
+	maze := testGeneratorConfig(t, "--width=8 --height=8 --walls=0.3 --robots=3 --battery=40 --seed=5")
+	generated, err := GenerateInitialState(maze)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		state InitialState
+	}{
+		{"open room", testFleet(testState(30, 1, 1, "0,3,0,0,8", "5,0,0,9001,0", "0,0,2,0,4", "7,0,0,0,1"),
+			[3]int{4, 3, 30}, [3]int{2, 1, 20})},
+		{"face to face in a corridor", testFleet(testState(20, 1, 1, "0,4,0,0,6,0"), [3]int{5, 0, 20})},
+		{"one robot out of battery", testFleet(testState(10, 1, 1, "0,2,0", "5,0,9"), [3]int{2, 0, 0})},
+		{"generated maze", generated},
+	}
+
+	for _, test := range tests {
+		for _, assignment := range FLEET_ASSIGNMENTS {
+			t.Run(test.name+" "+assignment, func(t *testing.T) {
+				result, err := PlanFleet(test.state, assignment)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// where every robot stands after each tick, a robot that is done stays where it stopped
+				at := func(robot PlanResult, tick int) [2]int {
+					if len(robot.Steps) == 0 {
+						return [2]int{robot.StartX, robot.StartY}
+					}
+					if tick > len(robot.Steps) {
+						tick = len(robot.Steps)
+					}
+					return [2]int{robot.Steps[tick-1].X, robot.Steps[tick-1].Y}
+				}
+				for tick := 1; tick <= result.Ticks; tick++ {
+					taken := map[[2]int]int{}
+					for r, robot := range result.Robots {
+						if other, ok := taken[at(robot, tick)]; ok {
+							t.Fatalf("robots %d and %d share %v after tick %d", other+1, r+1, at(robot, tick), tick)
+						}
+						taken[at(robot, tick)] = r
+					}
+				}
+
+				vacuumed, dirt := map[[2]int]bool{}, 0
+				for r, robot := range result.Robots {
+					if len(robot.Steps) > result.Ticks || robot.BatteryLeft < 0 {
+						t.Errorf("robot %d took %d steps in %d ticks with %d battery left", r+1, len(robot.Steps), result.Ticks, robot.BatteryLeft)
+					}
+					for _, step := range robot.Steps {
+						if step.DirtVacuumed == 0 {
+							continue
+						}
+						if vacuumed[[2]int{step.X, step.Y}] {
+							t.Errorf("(%d, %d) vacuumed twice", step.X, step.Y)
+						}
+						vacuumed[[2]int{step.X, step.Y}] = true
+						dirt += step.DirtVacuumed
+					}
+				}
+				if dirt != result.DirtCleaned || dirt == 0 {
+					t.Errorf("robots vacuumed %d dirt, the fleet cleaned %d", dirt, result.DirtCleaned)
+				}
+			})
+		}
+	}
+}
+
+func TestFleetRejectsUnknownAssignment(t *testing.T) {
+	state := testFleet(testState(5, 1, 1, "0,3"), [3]int{1, 0, 5})
+	if _, err := PlanFleet(state, "random"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,732 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var MAP_FORMATS = []string{"csv", "json", "ascii"}
+
+func init() {
+	registerCommand("convert", "[--from=csv|json|ascii] [--to=csv|json|ascii] <input map> [output map]",
+		"Converts a map between the CSV, JSON and ASCII-art formats",
+		runConvert)
+}
+
+// Map format from the file extension: .json, .txt/.map (ASCII-art), anything else is CSV
+func detectMapFormat(filePath string) string {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		return "json"
+	case ".txt", ".map":
+		return "ascii"
+	}
+	return "csv"
+}
+
+func isMapFile(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv", ".json", ".txt", ".map":
+		return true
+	}
+	return false
+}
+
+// LoadInitialState reads a map in the given format, "" detects it from the extension
+func LoadInitialState(filePath string, format string) (InitialState, error) {
+	if format == "" {
+		format = detectMapFormat(filePath)
+	}
+
+	switch format {
+	case "csv":
+		return ReadInitialState(filePath)
+	case "json":
+		return ReadInitialStateJSON(filePath)
+	case "ascii":
+		return ReadInitialStateASCII(filePath)
+	}
+	return InitialState{}, fmt.Errorf("Unknown map format %s, expected one of %v", format, MAP_FORMATS)
+}
+
+// SaveInitialState writes a map in the given format
+func SaveInitialState(w io.Writer, initialState InitialState, format string) error {
+	switch format {
+	case "csv":
+		return WriteInitialState(w, initialState)
+	case "json":
+		return WriteInitialStateJSON(w, initialState)
+	case "ascii":
+		return WriteInitialStateASCII(w, initialState)
+	}
+	return fmt.Errorf("Unknown map format %s, expected one of %v", format, MAP_FORMATS)
+}
+
+type jsonMap struct {
+	Start         jsonPosition    `json:"start"`
+	Battery       int             `json:"battery"`
+	MovementCost  int             `json:"movementCost"`
+	VacuumingCost int             `json:"vacuumingCost"`
+	ChargeRate    int             `json:"chargeRate,omitempty"`
+	MustEndOnDock bool            `json:"mustEndOnDock,omitempty"`
+	SensorRadius  int             `json:"sensorRadius,omitempty"`
+	Horizon       int             `json:"horizon,omitempty"`
+	MoveSuccess   float64         `json:"moveSuccess,omitempty"`
+	Robots        []RobotStart    `json:"robots,omitempty"`
+	Events        []DirtEvent     `json:"events,omitempty"`
+	Tiles         [][]json.Number `json:"tiles"`
+	Terrain       [][]jsonCell    `json:"terrain,omitempty"`
+	Regrowth      [][]jsonCell    `json:"regrowth,omitempty"`
+}
+
+// Layer cell of a JSON map, a number (2) or a string: "movement:vacuuming" terrain ("2:3"), "dirt/steps" regrowth ("1/5")
+type jsonCell string
+
+func (cell *jsonCell) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*cell = jsonCell(text)
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("layer cell must be a number or a string, got %s", data)
+	}
+	*cell = jsonCell(number.String())
+	return nil
+}
+
+func (cell jsonCell) MarshalJSON() ([]byte, error) {
+	if _, err := strconv.Atoi(string(cell)); err == nil {
+		return []byte(cell), nil
+	}
+	return json.Marshal(string(cell))
+}
+
+// Parse the initial state from a JSON document:
+// {"start": {"x": 0, "y": 0}, "battery": 50, "movementCost": 1, "vacuumingCost": 5, "tiles": [[0, 10], [9001, 20]]}
+// with optional "terrain" and "regrowth" grids of the same shape, e.g. [[1, "2:3"], [1, 1]] and [[0, "1/5"], [0, 2]],
+// a "horizon", "moveSuccess" for a slippery floor and "events" such as [{"step": 20, "x": 1, "y": 0, "dirt": 50}].
+func ReadInitialStateJSON(filePath string) (InitialState, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return InitialState{}, fmt.Errorf("Error opening file %s: %v", filePath, err)
+	}
+
+	document := jsonMap{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return InitialState{}, fmt.Errorf("Error parsing JSON map %s: %v", filePath, err)
+	}
+
+	initialState := InitialState{
+		X0:            document.Start.X,
+		Y0:            document.Start.Y,
+		Battery:       document.Battery,
+		MovementCost:  document.MovementCost,
+		VacuumingCost: document.VacuumingCost,
+		ChargeRate:    document.ChargeRate,
+		MustEndOnDock: document.MustEndOnDock,
+		SensorRadius:  document.SensorRadius,
+		Horizon:       document.Horizon,
+		MoveSuccess:   document.MoveSuccess,
+		Robots:        document.Robots,
+		Events:        document.Events,
+		Tiles:         make([][]string, len(document.Tiles)),
+		source:        filePath,
+	}
+	for y, row := range document.Tiles {
+		for _, tile := range row {
+			initialState.Tiles[y] = append(initialState.Tiles[y], tile.String())
+		}
+	}
+	initialState.Terrain = jsonLayer(document.Terrain)
+	initialState.Regrowth = jsonLayer(document.Regrowth)
+
+	return initialState, nil
+}
+
+func jsonLayer(cells [][]jsonCell) [][]string {
+	var layer [][]string
+	for y, row := range cells {
+		layer = append(layer, []string{})
+		for _, cell := range row {
+			layer[y] = append(layer[y], string(cell))
+		}
+	}
+	return layer
+}
+
+func WriteInitialStateJSON(w io.Writer, initialState InitialState) error {
+	document := jsonMap{
+		Start:         jsonPosition{X: initialState.X0, Y: initialState.Y0},
+		Battery:       initialState.Battery,
+		MovementCost:  initialState.MovementCost,
+		VacuumingCost: initialState.VacuumingCost,
+		Tiles:         make([][]json.Number, len(initialState.Tiles)),
+	}
+	for y, row := range initialState.Tiles {
+		document.Tiles[y] = []json.Number{}
+		for x, tile := range row {
+			if _, err := strconv.Atoi(strings.TrimSpace(tile)); err != nil {
+				return fmt.Errorf("Tile at (%d, %d) is not an integer: %q", x, y, tile)
+			}
+			document.Tiles[y] = append(document.Tiles[y], json.Number(strings.TrimSpace(tile)))
+		}
+	}
+
+	// one row of tiles per line keeps the grid readable, written once it is complete
+	out := bytes.Buffer{}
+	fmt.Fprintf(&out, "{\n  \"start\": {\"x\": %d, \"y\": %d},\n  \"battery\": %d,\n  \"movementCost\": %d,\n  \"vacuumingCost\": %d,\n",
+		document.Start.X, document.Start.Y, document.Battery, document.MovementCost, document.VacuumingCost)
+	if initialState.ChargeRate != 0 {
+		fmt.Fprintf(&out, "  \"chargeRate\": %d,\n", initialState.ChargeRate)
+	}
+	if initialState.MustEndOnDock {
+		fmt.Fprintln(&out, "  \"mustEndOnDock\": true,")
+	}
+	if initialState.SensorRadius != 0 {
+		fmt.Fprintf(&out, "  \"sensorRadius\": %d,\n", initialState.SensorRadius)
+	}
+	if initialState.Horizon != 0 {
+		fmt.Fprintf(&out, "  \"horizon\": %d,\n", initialState.Horizon)
+	}
+	if initialState.MoveSuccess != 0 {
+		fmt.Fprintf(&out, "  \"moveSuccess\": %s,\n", strconv.FormatFloat(initialState.MoveSuccess, 'g', -1, 64))
+	}
+	if len(initialState.Robots) > 0 {
+		encoded, err := json.Marshal(initialState.Robots)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&out, "  \"robots\": %s,\n", strings.NewReplacer(",", ", ", ":", ": ").Replace(string(encoded)))
+	}
+	if len(initialState.Events) > 0 {
+		encoded, err := json.Marshal(initialState.Events)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&out, "  \"events\": %s,\n", strings.NewReplacer(",", ", ", ":", ": ").Replace(string(encoded)))
+	}
+	rows := []any{}
+	for _, row := range document.Tiles {
+		rows = append(rows, row)
+	}
+	if err := writeJSONGrid(&out, "tiles", rows); err != nil {
+		return err
+	}
+
+	for _, layer := range []struct {
+		key   string
+		cells [][]string
+	}{{"terrain", initialState.Terrain}, {"regrowth", initialState.Regrowth}} {
+		if layer.cells == nil {
+			continue
+		}
+		rows = []any{}
+		for _, row := range layer.cells {
+			cells := []jsonCell{}
+			for _, cell := range row {
+				cells = append(cells, jsonCell(strings.TrimSpace(cell)))
+			}
+			rows = append(rows, cells)
+		}
+		fmt.Fprintln(&out, ",")
+		if err := writeJSONGrid(&out, layer.key, rows); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(&out, "\n}")
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// Writes "key": [...] with one row per line, without the trailing newline
+func writeJSONGrid(w io.Writer, key string, rows []any) error {
+	if _, err := fmt.Fprintf(w, "  %q: [\n", key); err != nil {
+		return err
+	}
+	for y, row := range rows {
+		encoded, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		separator := ","
+		if y == len(rows)-1 {
+			separator = ""
+		}
+		if _, err := fmt.Fprintf(w, "    %s%s\n", strings.ReplaceAll(string(encoded), ",", ", "), separator); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprint(w, "  ]")
+	return err
+}
+
+// Parse the initial state from the ASCII-art format. A header block of "key: value" lines
+// (battery, movement cost, vacuuming cost, optional start) and legend lines ("a = 120") is followed
+// by an empty line and the grid:
+//
+//	'#' wall, '.' or '0' clean, '1'-'9' that much dirt, letters dirt as given in the legend, 'S' clean start,
+//	'+' charging dock.
+//
+// Optional "charge rate" and "end on dock" header lines set up docks, "robot: x, y, battery" lines add robots,
+// "sensor radius" limits what exploring planners see, "horizon" limits the steps, "event: step, x, y, dirt"
+// lines script dirt and "move success" makes the floor slippery. A "terrain" line after the grid starts a second grid of the same shape with the terrain:
+// '1'-'9' (or '0') multiply the movement and vacuuming costs, letters take "movement:vacuuming" multipliers
+// from the legend ("c = 3:2"), anything else is plain floor. A "regrowth" line starts a grid of dirt regrowth:
+// '1'-'9' add that much dirt every step, letters take "dirt/steps" from the legend ("r = 2/5"), anything else
+// has none. Lines starting with ';' are comments.
+func ReadInitialStateASCII(filePath string) (InitialState, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return InitialState{}, fmt.Errorf("Error opening file %s: %v", filePath, err)
+	}
+	defer f.Close()
+
+	initialState := InitialState{X0: -1, Y0: -1, source: filePath, Tiles: [][]string{}}
+	legend := map[rune]string{}
+	terrainLegend := map[rune]string{}
+	regrowthLegend := map[rune]string{}
+	settings := map[string]bool{}
+	inHeader, layer := true, "" // layer is "terrain" or "regrowth" after the grid
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(strings.TrimSpace(text), ";") {
+			continue
+		}
+
+		if inHeader {
+			if strings.TrimSpace(text) == "" {
+				inHeader = len(settings) == 0 // allow empty lines before the header
+				continue
+			}
+			if err := parseASCIIHeader(&initialState, legend, terrainLegend, regrowthLegend, settings, text, line); err != nil {
+				return initialState, fmt.Errorf("Error in %s:%d: %v", filePath, line, err)
+			}
+			continue
+		}
+
+		if text == "" {
+			continue
+		}
+
+		switch name := strings.ToLower(strings.TrimSpace(text)); {
+		case name == "terrain" && initialState.Terrain == nil:
+			layer, initialState.Terrain = name, [][]string{}
+			continue
+		case name == "regrowth" && initialState.Regrowth == nil:
+			layer, initialState.Regrowth = name, [][]string{}
+			continue
+		}
+
+		if layer != "" {
+			cellLegend, plain := terrainLegend, "1"
+			if layer == "regrowth" {
+				cellLegend, plain = regrowthLegend, "0"
+			}
+
+			row, positions := []string{}, [][2]int{}
+			for column, char := range []rune(text) {
+				cell := plain
+				if char >= '0' && char <= '9' {
+					cell = string(char)
+				} else if isLegendRune(char) {
+					value, ok := cellLegend[char]
+					if !ok {
+						return initialState, fmt.Errorf("Error in %s:%d:%d: %q is not a %s in the legend",
+							filePath, line, column+1, char, layer)
+					}
+					cell = value
+				}
+				row = append(row, cell)
+				positions = append(positions, [2]int{line, column + 1})
+			}
+
+			if layer == "terrain" {
+				initialState.Terrain = append(initialState.Terrain, row)
+				initialState.terrainPositions = append(initialState.terrainPositions, positions)
+			} else {
+				initialState.Regrowth = append(initialState.Regrowth, row)
+				initialState.regrowthPositions = append(initialState.regrowthPositions, positions)
+			}
+			continue
+		}
+
+		row, positions := []string{}, [][2]int{}
+		for column, char := range []rune(text) {
+			tile := ""
+			switch {
+			case char == '#':
+				tile = strconv.Itoa(WALL_VALUE)
+			case char == '.':
+				tile = "0"
+			case char == '+':
+				tile = strconv.Itoa(DOCK_VALUE)
+			case char == 'S':
+				tile = "0"
+				if settings["start"] || initialState.X0 >= 0 {
+					return initialState, fmt.Errorf("Error in %s:%d:%d: start given more than once", filePath, line, column+1)
+				}
+				initialState.X0, initialState.Y0 = column, len(initialState.Tiles)
+			case char >= '0' && char <= '9':
+				tile = string(char)
+			default:
+				value, ok := legend[char]
+				if !ok {
+					return initialState, fmt.Errorf("Error in %s:%d:%d: %q is not a tile and not in the legend",
+						filePath, line, column+1, char)
+				}
+				tile = value
+			}
+			row = append(row, tile)
+			positions = append(positions, [2]int{line, column + 1})
+		}
+
+		initialState.Tiles = append(initialState.Tiles, row)
+		initialState.tilePositions = append(initialState.tilePositions, positions)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return initialState, fmt.Errorf("Error reading file %s: %v", filePath, err)
+	}
+	for _, required := range []string{"battery", "movement cost", "vacuuming cost"} {
+		if !settings[required] {
+			return initialState, fmt.Errorf("Error in %s: missing %q in the header", filePath, required)
+		}
+	}
+	if initialState.X0 < 0 {
+		return initialState, fmt.Errorf("Error in %s: no start, add an 'S' tile or a \"start: x, y\" header", filePath)
+	}
+
+	return initialState, nil
+}
+
+func parseASCIIHeader(initialState *InitialState, legend map[rune]string, terrainLegend map[rune]string,
+	regrowthLegend map[rune]string, settings map[string]bool, text string, line int) error {
+	if key, value, ok := strings.Cut(text, "="); ok {
+		key = strings.TrimSpace(key)
+		if len([]rune(key)) != 1 || !isLegendRune([]rune(key)[0]) {
+			return fmt.Errorf("legend key %q must be a single letter other than 'S'", key)
+		}
+		if strings.Contains(value, ":") {
+			if _, err := parseTerrain(value); err != nil {
+				return err
+			}
+			terrainLegend[[]rune(key)[0]] = strings.TrimSpace(value)
+			return nil
+		}
+		if strings.Contains(value, "/") {
+			if _, err := parseRegrowth(value); err != nil {
+				return err
+			}
+			regrowthLegend[[]rune(key)[0]] = strings.TrimSpace(value)
+			return nil
+		}
+		if _, err := strconv.Atoi(strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("legend value %q is not an integer", strings.TrimSpace(value))
+		}
+		legend[[]rune(key)[0]] = strings.TrimSpace(value)
+		return nil
+	}
+
+	key, value, ok := strings.Cut(text, ":")
+	if !ok {
+		return fmt.Errorf("expected \"key: value\" or \"letter = dirt\", got %q (is the empty line before the grid missing?)", text)
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	value = strings.TrimSpace(value)
+
+	if key == "start" {
+		x, y, ok := strings.Cut(value, ",")
+		startX, errX := strconv.Atoi(strings.TrimSpace(x))
+		startY, errY := strconv.Atoi(strings.TrimSpace(y))
+		if !ok || errX != nil || errY != nil {
+			return fmt.Errorf("start must be \"x, y\", got %q", value)
+		}
+		initialState.X0, initialState.Y0 = startX, startY
+		initialState.settingLines[0], initialState.settingLines[1] = line, line
+		settings[key] = true
+		return nil
+	}
+
+	switch key {
+	case "charge rate", "end on dock", "robot", "sensor radius", "horizon", "event", "move success":
+		settings[key] = true
+		return parseCommentSetting(initialState, key, value, line)
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s must be an integer, got %q", key, value)
+	}
+	switch key {
+	case "battery":
+		initialState.Battery = number
+		initialState.settingLines[2] = line
+	case "movement cost":
+		initialState.MovementCost = number
+		initialState.settingLines[3] = line
+	case "vacuuming cost":
+		initialState.VacuumingCost = number
+		initialState.settingLines[4] = line
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	settings[key] = true
+	return nil
+}
+
+func isLegendRune(char rune) bool {
+	return ((char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')) && char != 'S'
+}
+
+// Writes the ASCII-art format. Dirt 1-9 uses digits, other values get legend letters.
+func WriteInitialStateASCII(w io.Writer, initialState InitialState) error {
+	letters := []rune{}
+	for char := 'a'; char <= 'z'; char++ {
+		letters = append(letters, char)
+	}
+	for char := 'A'; char <= 'Z'; char++ {
+		if char != 'S' {
+			letters = append(letters, char)
+		}
+	}
+
+	values := map[int]bool{}
+	for y, row := range initialState.Tiles {
+		for x, tile := range row {
+			value, err := strconv.Atoi(strings.TrimSpace(tile))
+			if err != nil {
+				return fmt.Errorf("Tile at (%d, %d) is not an integer: %q", x, y, tile)
+			}
+			if value > 9 && value != WALL_VALUE && value != DOCK_VALUE {
+				values[value] = true
+			}
+		}
+	}
+	if len(values) > len(letters) {
+		return fmt.Errorf("%d distinct dirt values above 9, the ASCII format has only %d legend letters",
+			len(values), len(letters))
+	}
+
+	sorted := []int{}
+	for value := range values {
+		sorted = append(sorted, value)
+	}
+	sort.Ints(sorted)
+	legend := map[int]rune{}
+	for i, value := range sorted {
+		legend[value] = letters[i]
+	}
+
+	terrainLegend, terrains, err := terrainLegendASCII(initialState.Terrain, letters[len(sorted):])
+	if err != nil {
+		return err
+	}
+	regrowthLegend, regrowths, err := regrowthLegendASCII(initialState.Regrowth, letters[len(sorted)+len(terrains):])
+	if err != nil {
+		return err
+	}
+
+	startOnDirt := initialState.Y0 >= 0 && initialState.Y0 < len(initialState.Tiles) &&
+		initialState.X0 >= 0 && initialState.X0 < len(initialState.Tiles[initialState.Y0]) &&
+		strings.TrimSpace(initialState.Tiles[initialState.Y0][initialState.X0]) != "0"
+
+	out := bytes.Buffer{}
+	fmt.Fprintf(&out, "battery: %d\nmovement cost: %d\nvacuuming cost: %d\n",
+		initialState.Battery, initialState.MovementCost, initialState.VacuumingCost)
+	if initialState.ChargeRate != 0 {
+		fmt.Fprintf(&out, "charge rate: %d\n", initialState.ChargeRate)
+	}
+	if initialState.MustEndOnDock {
+		fmt.Fprintln(&out, "end on dock: yes")
+	}
+	if initialState.SensorRadius != 0 {
+		fmt.Fprintf(&out, "sensor radius: %d\n", initialState.SensorRadius)
+	}
+	if initialState.Horizon != 0 {
+		fmt.Fprintf(&out, "horizon: %d\n", initialState.Horizon)
+	}
+	if initialState.MoveSuccess != 0 {
+		fmt.Fprintf(&out, "move success: %s\n", strconv.FormatFloat(initialState.MoveSuccess, 'g', -1, 64))
+	}
+	for _, robot := range initialState.Robots {
+		fmt.Fprintf(&out, "robot: %d, %d, %d\n", robot.X, robot.Y, robot.Battery)
+	}
+	for _, event := range initialState.Events {
+		fmt.Fprintf(&out, "event: %d, %d, %d, %d\n", event.Step, event.X, event.Y, event.Dirt)
+	}
+	if startOnDirt {
+		// 'S' would lose the dirt (or dock) under the start
+		fmt.Fprintf(&out, "start: %d, %d\n", initialState.X0, initialState.Y0)
+	}
+	for _, value := range sorted {
+		fmt.Fprintf(&out, "%c = %d\n", legend[value], value)
+	}
+	for _, multipliers := range terrains {
+		fmt.Fprintf(&out, "%c = %d:%d\n", terrainLegend[multipliers], multipliers[0], multipliers[1])
+	}
+	for _, growth := range regrowths {
+		fmt.Fprintf(&out, "%c = %d/%d\n", regrowthLegend[growth], growth[0], growth[1])
+	}
+	fmt.Fprintln(&out)
+
+	for y, row := range initialState.Tiles {
+		line := []rune{}
+		for x, tile := range row {
+			value, _ := strconv.Atoi(strings.TrimSpace(tile))
+			switch {
+			case x == initialState.X0 && y == initialState.Y0 && !startOnDirt:
+				line = append(line, 'S')
+			case value == WALL_VALUE:
+				line = append(line, '#')
+			case value == DOCK_VALUE:
+				line = append(line, '+')
+			case value == 0:
+				line = append(line, '.')
+			case value <= 9:
+				line = append(line, rune('0'+value))
+			default:
+				line = append(line, legend[value])
+			}
+		}
+		fmt.Fprintln(&out, string(line))
+	}
+
+	if initialState.Terrain != nil {
+		fmt.Fprintln(&out)
+		fmt.Fprintln(&out, "terrain")
+		for _, row := range initialState.Terrain {
+			line := []rune{}
+			for _, cell := range row {
+				multipliers, _ := parseTerrain(cell)
+				if letter, ok := terrainLegend[multipliers]; ok {
+					line = append(line, letter)
+				} else {
+					line = append(line, rune('0'+multipliers[0]))
+				}
+			}
+			fmt.Fprintln(&out, string(line))
+		}
+	}
+
+	if initialState.Regrowth != nil {
+		fmt.Fprintln(&out)
+		fmt.Fprintln(&out, "regrowth")
+		for _, row := range initialState.Regrowth {
+			line := []rune{}
+			for _, cell := range row {
+				growth, _ := parseRegrowth(cell)
+				if letter, ok := regrowthLegend[growth]; ok {
+					line = append(line, letter)
+				} else if growth[0] == 0 {
+					line = append(line, '.')
+				} else {
+					line = append(line, rune('0'+growth[0]))
+				}
+			}
+			fmt.Fprintln(&out, string(line))
+		}
+	}
+
+	_, err = out.WriteTo(w)
+	return err
+}
+
+// Legend letters of the terrains that are not a single digit (different multipliers or above 9), in order of appearance
+func terrainLegendASCII(terrain [][]string, letters []rune) (map[[2]int]rune, [][2]int, error) {
+	legend := map[[2]int]rune{}
+	sorted := [][2]int{}
+	for y, row := range terrain {
+		for x, cell := range row {
+			multipliers, err := parseTerrain(cell)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Terrain at (%d, %d): %v", x, y, err)
+			}
+			if _, ok := legend[multipliers]; !ok && (multipliers[0] != multipliers[1] || multipliers[0] > 9) {
+				if len(sorted) == len(letters) {
+					return nil, nil, fmt.Errorf("More than %d distinct terrains, the ASCII format has only %d legend letters",
+						len(letters), len(letters))
+				}
+				legend[multipliers] = letters[len(sorted)]
+				sorted = append(sorted, multipliers)
+			}
+		}
+	}
+
+	return legend, sorted, nil
+}
+
+// Legend letters of the regrowths that are not a single digit every step, in order of appearance
+func regrowthLegendASCII(regrowth [][]string, letters []rune) (map[[2]int]rune, [][2]int, error) {
+	legend := map[[2]int]rune{}
+	sorted := [][2]int{}
+	for y, row := range regrowth {
+		for x, cell := range row {
+			growth, err := parseRegrowth(cell)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Regrowth at (%d, %d): %v", x, y, err)
+			}
+			if _, ok := legend[growth]; !ok && growth[0] > 0 && (growth[1] != 1 || growth[0] > 9) {
+				if len(sorted) == len(letters) {
+					return nil, nil, fmt.Errorf("Too many distinct regrowths, the ASCII format has only %d legend letters left",
+						len(letters))
+				}
+				legend[growth] = letters[len(sorted)]
+				sorted = append(sorted, growth)
+			}
+		}
+	}
+
+	return legend, sorted, nil
+}
+
+func runConvert(args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := flags.String("from", "", "Input format: csv, json or ascii (default from the extension)")
+	to := flags.String("to", "", "Output format: csv, json or ascii (default from the output extension)")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return commandUsage("convert")
+	}
+
+	initialState, err := LoadInitialState(flags.Arg(0), *from)
+	if err != nil {
+		return err
+	}
+
+	out := flags.Arg(1)
+	if *to == "" {
+		if out == "" {
+			return errors.New("Either an output file or --to is needed")
+		}
+		*to = detectMapFormat(out)
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return SaveInitialState(w, initialState, *to)
+}
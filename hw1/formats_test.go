@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The map without where its values came from, with every cell written one way: "0, 5" and "0,5" read as the
+// same tiles and terrain "2" is the same as "2:2", regrowth "3" the same as "3/1"
+func mapContent(initialState InitialState) InitialState {
+	initialState.Tiles = rewriteCells(initialState.Tiles, strings.TrimSpace)
+	initialState.Terrain = rewriteCells(initialState.Terrain, func(cell string) string {
+		multipliers, _ := parseTerrain(cell)
+		return fmt.Sprintf("%d:%d", multipliers[0], multipliers[1])
+	})
+	initialState.Regrowth = rewriteCells(initialState.Regrowth, func(cell string) string {
+		growth, _ := parseRegrowth(cell)
+		return fmt.Sprintf("%d/%d", growth[0], growth[1])
+	})
+
+	initialState.source = ""
+	initialState.settingLines = [10]int{}
+	initialState.tilePositions = nil
+	initialState.terrainPositions = nil
+	initialState.regrowthPositions = nil
+	initialState.robotLines = nil
+	initialState.eventLines = nil
+	return initialState
+}
+
+func rewriteCells(layer [][]string, rewrite func(string) string) [][]string {
+	if layer == nil {
+		return nil
+	}
+	rewritten := [][]string{}
+	for _, row := range layer {
+		cells := []string{}
+		for _, cell := range row {
+			cells = append(cells, rewrite(cell))
+		}
+		rewritten = append(rewritten, cells)
+	}
+	return rewritten
+}
+
+// Writes the content to a file of the given name in a temporary directory and reads it back as a map
+func testLoad(t *testing.T, name string, content string) (InitialState, error) {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadInitialState(path, "")
+}
+
+func TestReadMapFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    InitialState
+	}{
+		{"csv", "map.csv", "1\n0\n20\n1\n2\n0, 5, 9001\n12, 0, 0\n",
+			InitialState{X0: 1, Battery: 20, MovementCost: 1, VacuumingCost: 2, Tiles: [][]string{{"0", "5", "9001"}, {"12", "0", "0"}}}},
+		{"json", "map.json",
+			`{"start": {"x": 1, "y": 0}, "battery": 20, "movementCost": 1, "vacuumingCost": 2, "tiles": [[0, 5, 9001], [12, 0, 0]]}`,
+			InitialState{X0: 1, Battery: 20, MovementCost: 1, VacuumingCost: 2, Tiles: [][]string{{"0", "5", "9001"}, {"12", "0", "0"}}}},
+		{"ascii", "map.txt", "battery: 20\nmovement cost: 1\nvacuuming cost: 2\nd = 12\n\n.5#\ndS.\n",
+			InitialState{X0: 1, Y0: 1, Battery: 20, MovementCost: 1, VacuumingCost: 2,
+				Tiles: [][]string{{"0", "5", "9001"}, {"12", "0", "0"}}}},
+		{"ascii start header", "map.map", "start: 2, 1\nbattery: 20\nmovement cost: 1\nvacuuming cost: 2\n\n.5#\n0..\n",
+			InitialState{X0: 2, Y0: 1, Battery: 20, MovementCost: 1, VacuumingCost: 2,
+				Tiles: [][]string{{"0", "5", "9001"}, {"0", "0", "0"}}}},
+		{"json layers", "map.json", `{"start": {"x": 0, "y": 0}, "battery": 9, "movementCost": 1, "vacuumingCost": 1,
+			"chargeRate": 3, "mustEndOnDock": true, "horizon": 40, "moveSuccess": 0.8,
+			"robots": [{"x": 1, "y": 0, "battery": 7}], "events": [{"step": 5, "x": 1, "y": 0, "dirt": 50}],
+			"tiles": [[0, 4], [9002, 0]], "terrain": [[1, "2:3"], [1, 2]], "regrowth": [[0, "1/5"], [0, 2]]}`,
+			InitialState{Battery: 9, MovementCost: 1, VacuumingCost: 1, ChargeRate: 3, MustEndOnDock: true,
+				Horizon: 40, MoveSuccess: 0.8, Robots: []RobotStart{{X: 1, Y: 0, Battery: 7}},
+				Events: []DirtEvent{{Step: 5, X: 1, Y: 0, Dirt: 50}}, Tiles: [][]string{{"0", "4"}, {"9002", "0"}},
+				Terrain: [][]string{{"1", "2:3"}, {"1", "2"}}, Regrowth: [][]string{{"0", "1/5"}, {"0", "2"}}}},
+		{"ascii layers", "map.txt", "; a comment\ncharge rate: 3\nend on dock: yes\nhorizon: 40\nmove success: 0.8\n" +
+			"robot: 1, 0, 7\nevent: 5, 1, 0, 50\nbattery: 9\nmovement cost: 1\nvacuuming cost: 1\nc = 2:3\nr = 1/5\n\n" +
+			"S4\n+.\n\nterrain\n1c\n.2\n\nregrowth\n0r\n.2\n",
+			InitialState{Battery: 9, MovementCost: 1, VacuumingCost: 1, ChargeRate: 3, MustEndOnDock: true,
+				Horizon: 40, MoveSuccess: 0.8, Robots: []RobotStart{{X: 1, Y: 0, Battery: 7}},
+				Events: []DirtEvent{{Step: 5, X: 1, Y: 0, Dirt: 50}}, Tiles: [][]string{{"0", "4"}, {"9002", "0"}},
+				Terrain: [][]string{{"1", "2:3"}, {"1", "2"}}, Regrowth: [][]string{{"0", "1/5"}, {"0", "2"}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			initialState, err := testLoad(t, test.file, test.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := mapContent(initialState), mapContent(test.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("read %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadMapErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"json syntax", "map.json", `{"battery": 20,`, "Error parsing JSON map"},
+		{"json layer cell", "map.json", `{"tiles": [[0]], "terrain": [[true]]}`, "number or a string"},
+		{"ascii missing battery", "map.txt", "movement cost: 1\nvacuuming cost: 2\n\nS.\n", `missing "battery"`},
+		{"ascii no start", "map.txt", "battery: 5\nmovement cost: 1\nvacuuming cost: 2\n\n..\n", "no start"},
+		{"ascii two starts", "map.txt", "battery: 5\nmovement cost: 1\nvacuuming cost: 2\n\nSS\n", ":5:2: start given more than once"},
+		{"ascii unknown tile", "map.txt", "battery: 5\nmovement cost: 1\nvacuuming cost: 2\n\nSx\n", ":5:2: 'x' is not a tile"},
+		{"ascii unknown terrain", "map.txt", "battery: 5\nmovement cost: 1\nvacuuming cost: 2\n\nS.\n\nterrain\n1q\n", ":8:2: 'q' is not a terrain"},
+		{"ascii bad legend key", "map.txt", "S = 4\n", "single letter other than 'S'"},
+		{"ascii bad legend value", "map.txt", "d = lots\n", "not an integer"},
+		{"ascii bad setting", "map.txt", "battery: full\n", "battery must be an integer"},
+		{"ascii unknown setting", "map.txt", "speed: 3\n", `unknown setting "speed"`},
+		{"ascii missing empty line", "map.txt", "battery: 5\nS.\n", "empty line before the grid"},
+		{"missing file", "missing.csv", "", "Error opening file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			if test.content != "" {
+				if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := LoadInitialState(path, "")
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one about %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	layered := testState(30, 2, 3, "0,4,9001,12", "9002,0,7,250")
+	layered.X0, layered.Y0 = 1, 1
+	layered.ChargeRate, layered.MustEndOnDock, layered.Horizon, layered.MoveSuccess = 4, true, 60, 0.75
+	layered.Robots = []RobotStart{{X: 3, Y: 0, Battery: 11}}
+	layered.Events = []DirtEvent{{Step: 9, X: 0, Y: 0, Dirt: 30}}
+	layered.Terrain = [][]string{{"1", "2", "1", "3:1"}, {"1", "12", "1", "1"}}
+	layered.Regrowth = [][]string{{"0", "1/5", "0", "3"}, {"0", "0", "20", "0"}}
+
+	maps := []struct {
+		name  string
+		state InitialState
+	}{
+		{"plain", testState(12, 1, 1, "0,5,0,9001", "3,9001,7,0", "0,0,0,8")},
+		{"layers", layered},
+	}
+	files := map[string]string{"csv": "map.csv", "json": "map.json", "ascii": "map.txt"}
+
+	for _, original := range maps {
+		for _, from := range MAP_FORMATS {
+			for _, to := range MAP_FORMATS {
+				t.Run(original.name+" "+from+" to "+to, func(t *testing.T) {
+					first := bytes.Buffer{}
+					if err := SaveInitialState(&first, original.state, from); err != nil {
+						t.Fatal(err)
+					}
+					read, err := testLoad(t, files[from], first.String())
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					second := bytes.Buffer{}
+					if err := SaveInitialState(&second, read, to); err != nil {
+						t.Fatal(err)
+					}
+					converted, err := testLoad(t, files[to], second.String())
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got, want := mapContent(converted), mapContent(original.state); !reflect.DeepEqual(got, want) {
+						t.Errorf("converted to %+v, want %+v", got, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestDetectMapFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"maps/a.json", "json"},
+		{"a.JSON", "json"},
+		{"a.txt", "ascii"},
+		{"a.map", "ascii"},
+		{"a.csv", "csv"},
+		{"a", "csv"},
+	}
+	for _, test := range tests {
+		if got := detectMapFormat(test.path); got != test.want {
+			t.Errorf("%s detected as %s, want %s", test.path, got, test.want)
+		}
+	}
+}
+
+func TestRunConvert(t *testing.T) {
+	state := testState(12, 1, 1, "0,5,0,9001", "3,9001,7,0", "0,0,0,8")
+	csvMap := bytes.Buffer{}
+	if err := WriteInitialState(&csvMap, state); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	in := filepath.Join(dir, "map.csv")
+	if err := os.WriteFile(in, csvMap.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, out := range []string{"map.json", "map.txt", "copy.csv"} {
+		t.Run(out, func(t *testing.T) {
+			if err := runConvert([]string{in, filepath.Join(dir, out)}); err != nil {
+				t.Fatal(err)
+			}
+			converted, err := LoadInitialState(filepath.Join(dir, out), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := mapContent(converted), mapContent(state); !reflect.DeepEqual(got, want) {
+				t.Errorf("converted to %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if err := runConvert([]string{in}); err == nil {
+		t.Error("no error without an output file or --to")
+	}
+}
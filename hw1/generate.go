@@ -0,0 +1,414 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var DIRT_DISTRIBUTIONS = []string{"uniform", "clustered", "jackpot"}
+
+func init() {
+	registerCommand("generate", "[flags] (see generate -h)",
+		"Writes random maps in the input CSV format, reproducible from a seed",
+		runGenerate)
+}
+
+// Knobs of the random map generator
+type GeneratorConfig struct {
+	Seed          int64
+	Width         int
+	Height        int
+	StartX        int // -1 picks a random tile, lower is invalid
+	StartY        int // -1 picks a random tile, lower is invalid
+	WallDensity   float64
+	Distribution  string // one of DIRT_DISTRIBUTIONS
+	DirtDensity   float64
+	MaxDirt       int
+	Jackpots      int // rare very dirty tiles for the "jackpot" distribution
+	Battery       int
+	MovementCost  int
+	VacuumingCost int
+	Reachable     bool // carve through walls until every dirty tile and dock is reachable from the start
+	Docks         int  // charging docks placed on random free tiles
+	ChargeRate    int
+	MustEndOnDock bool
+	Carpets       float64 // share of the grid covered by rectangular carpets
+	CarpetTerrain string  // terrain of carpet tiles, "m" or "m:v" multipliers
+	Robots        int     // robots in total, the further ones start on random tiles reachable from the start with the same battery
+	Regrowth      float64 // share of the floor where dirt comes back
+	RegrowthRate  string  // regrowth of those tiles, "dirt/steps"
+	Horizon       int     // steps the run lasts, 0 for no limit
+}
+
+// GenerateInitialState builds a random map. The same config always gives the same map.
+func GenerateInitialState(config GeneratorConfig) (InitialState, error) {
+	if config.Width <= 0 || config.Height <= 0 {
+		return InitialState{}, fmt.Errorf("Invalid grid size %dx%d", config.Width, config.Height)
+	}
+	if config.WallDensity < 0 || config.WallDensity >= 1 || config.DirtDensity < 0 || config.DirtDensity > 1 {
+		return InitialState{}, errors.New("Wall density must be in [0, 1) and dirt density in [0, 1]")
+	}
+	if config.MaxDirt < 1 || config.MaxDirt >= WALL_VALUE {
+		return InitialState{}, fmt.Errorf("Max dirt must be between 1 and %d", WALL_VALUE-1)
+	}
+	if config.StartX < -1 || config.StartY < -1 || config.StartX >= config.Width || config.StartY >= config.Height {
+		return InitialState{}, fmt.Errorf("Start (%d, %d) is outside the %dx%d grid",
+			config.StartX, config.StartY, config.Width, config.Height)
+	}
+
+	random := rand.New(rand.NewSource(config.Seed))
+
+	startX, startY := config.StartX, config.StartY
+	if startX < 0 {
+		startX = random.Intn(config.Width)
+	}
+	if startY < 0 {
+		startY = random.Intn(config.Height)
+	}
+
+	tiles := make([][]int, config.Height)
+	for y := range tiles {
+		tiles[y] = make([]int, config.Width)
+		for x := range tiles[y] {
+			if random.Float64() < config.WallDensity && (x != startX || y != startY) {
+				tiles[y][x] = WALL_VALUE
+			}
+		}
+	}
+
+	switch config.Distribution {
+	case "uniform":
+		scatterDirt(tiles, random, config.DirtDensity, config.MaxDirt)
+
+	case "clustered":
+		clusterDirt(tiles, random, config.DirtDensity, config.MaxDirt)
+
+	case "jackpot":
+		scatterDirt(tiles, random, config.DirtDensity, config.MaxDirt)
+		for i := 0; i < config.Jackpots; i++ {
+			x, y := random.Intn(config.Width), random.Intn(config.Height)
+			if tiles[y][x] != WALL_VALUE {
+				// an order of magnitude above regular dirt, like the 3000 tile in 7.csv
+				tiles[y][x] = config.MaxDirt * (5 + random.Intn(6))
+				if tiles[y][x] >= WALL_VALUE {
+					tiles[y][x] = WALL_VALUE - 1
+				}
+			}
+		}
+
+	default:
+		return InitialState{}, fmt.Errorf("Unknown dirt distribution %s, expected one of %v",
+			config.Distribution, DIRT_DISTRIBUTIONS)
+	}
+
+	for i := 0; i < config.Docks; i++ {
+		x, y := random.Intn(config.Width), random.Intn(config.Height)
+		if tiles[y][x] != WALL_VALUE && (x != startX || y != startY) {
+			tiles[y][x] = DOCK_VALUE
+		}
+	}
+
+	if config.Reachable {
+		carveToDirt(tiles, startX, startY)
+	}
+
+	// further robots start where the first one can walk to
+	robots := []RobotStart{}
+	taken := map[[2]int]bool{{startX, startY}: true}
+	var reached [][]int
+	if config.Robots > 1 {
+		reached = bfsDistances(&Agent{tiles: tiles}, startX, startY)
+	}
+	for tries := 0; len(robots) < config.Robots-1 && tries < 100*config.Width*config.Height; tries++ {
+		x, y := random.Intn(config.Width), random.Intn(config.Height)
+		if reached[y][x] >= 0 && !taken[[2]int{x, y}] {
+			taken[[2]int{x, y}] = true
+			robots = append(robots, RobotStart{X: x, Y: y, Battery: config.Battery})
+		}
+	}
+
+	var terrain [][]string
+	if config.Carpets > 0 {
+		if _, err := parseTerrain(config.CarpetTerrain); err != nil {
+			return InitialState{}, fmt.Errorf("Invalid carpet terrain: %v", err)
+		}
+		terrain = layCarpets(random, config.Width, config.Height, config.Carpets, strings.TrimSpace(config.CarpetTerrain))
+	}
+
+	var regrowth [][]string
+	if config.Regrowth > 0 {
+		if _, err := parseRegrowth(config.RegrowthRate); err != nil {
+			return InitialState{}, fmt.Errorf("Invalid regrowth rate: %v", err)
+		}
+		regrowth = make([][]string, config.Height)
+		for y, row := range tiles {
+			for _, tile := range row {
+				cell := "0"
+				if tile < WALL_VALUE && random.Float64() < config.Regrowth {
+					cell = strings.TrimSpace(config.RegrowthRate)
+				}
+				regrowth[y] = append(regrowth[y], cell)
+			}
+		}
+	}
+
+	initialState := InitialState{
+		X0:            startX,
+		Y0:            startY,
+		Battery:       config.Battery,
+		MovementCost:  config.MovementCost,
+		VacuumingCost: config.VacuumingCost,
+		Tiles:         make([][]string, config.Height),
+		ChargeRate:    config.ChargeRate,
+		MustEndOnDock: config.MustEndOnDock && config.Docks > 0,
+		Terrain:       terrain,
+		Regrowth:      regrowth,
+		Horizon:       config.Horizon,
+	}
+	if len(robots) > 0 {
+		initialState.Robots = robots
+	}
+	for y, row := range tiles {
+		for _, tile := range row {
+			initialState.Tiles[y] = append(initialState.Tiles[y], strconv.Itoa(tile))
+		}
+	}
+
+	// negative battery, costs, charge rate or horizon
+	if issues := ValidateInitialState(initialState); len(issues) > 0 {
+		return InitialState{}, fmt.Errorf("Invalid generator settings: %v", issues[0])
+	}
+	return initialState, nil
+}
+
+// Every free tile is dirty with the given probability, dirt uniform in [1, maxDirt]
+func scatterDirt(tiles [][]int, random *rand.Rand, density float64, maxDirt int) {
+	for y := range tiles {
+		for x := range tiles[y] {
+			if tiles[y][x] != WALL_VALUE && random.Float64() < density {
+				tiles[y][x] = 1 + random.Intn(maxDirt)
+			}
+		}
+	}
+}
+
+// Dirt gathers around a few random centers, more and heavier closer to a center
+func clusterDirt(tiles [][]int, random *rand.Rand, density float64, maxDirt int) {
+	height, width := len(tiles), len(tiles[0])
+	clusters := int(float64(width*height) * density / 8)
+	if clusters < 1 {
+		clusters = 1
+	}
+	radius := math.Max(1, math.Sqrt(float64(width*height)/float64(clusters))/2)
+
+	centers := make([][2]int, clusters)
+	for i := range centers {
+		centers[i] = [2]int{random.Intn(width), random.Intn(height)}
+	}
+
+	// closeness to the nearest center, tiles further than 3 radii from every center (below 1.2%) get none
+	closeness := make([]float64, width*height)
+	reach := int(math.Ceil(3 * radius))
+	for _, center := range centers {
+		for y := center[1] - reach; y <= center[1]+reach; y++ {
+			for x := center[0] - reach; x <= center[0]+reach; x++ {
+				if x < 0 || y < 0 || x >= width || y >= height {
+					continue
+				}
+				dx, dy := float64(x-center[0]), float64(y-center[1])
+				closeness[y*width+x] = math.Max(closeness[y*width+x], math.Exp(-(dx*dx+dy*dy)/(2*radius*radius)))
+			}
+		}
+	}
+
+	for y := range tiles {
+		for x := range tiles[y] {
+			if tiles[y][x] == WALL_VALUE {
+				continue
+			}
+
+			closeness := closeness[y*width+x]
+			if random.Float64() < closeness {
+				tiles[y][x] = int(closeness * float64(maxDirt) * (0.5 + random.Float64()/2))
+				if tiles[y][x] < 1 {
+					tiles[y][x] = 1
+				}
+			}
+		}
+	}
+}
+
+// Terrain layer of plain floor with random rectangles of carpet until the given share of the grid is covered
+func layCarpets(random *rand.Rand, width int, height int, share float64, carpet string) [][]string {
+	terrain := make([][]string, height)
+	for y := range terrain {
+		terrain[y] = make([]string, width)
+		for x := range terrain[y] {
+			terrain[y][x] = "1"
+		}
+	}
+
+	covered := 0
+	for tries := 0; float64(covered) < share*float64(width*height) && tries < width*height; tries++ {
+		w, h := 1+random.Intn(1+width/3), 1+random.Intn(1+height/3)
+		left, top := random.Intn(width-w+1), random.Intn(height-h+1)
+		for y := top; y < top+h; y++ {
+			for x := left; x < left+w; x++ {
+				if terrain[y][x] != carpet {
+					terrain[y][x] = carpet
+					covered++
+				}
+			}
+		}
+	}
+
+	return terrain
+}
+
+// Removes walls on an L-shaped path from every unreachable dirty tile or dock towards the start.
+// What a path connects is flooded into the reachable area, so the grid is searched only once.
+func carveToDirt(tiles [][]int, startX int, startY int) {
+	agent := Agent{tiles: tiles}
+	width := agent.gridWidth()
+	reached := bfsDistances(&agent, startX, startY) // only >= 0 matters, flooded tiles get 0
+
+	queue := Queue{}
+	connect := func(x int, y int) {
+		if reached[y][x] < 0 {
+			reached[y][x] = 0
+			queue.Enqueue(y*width + x)
+		}
+	}
+
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for y := range tiles {
+		for x := range tiles[y] {
+			if (tiles[y][x] <= 0 || tiles[y][x] >= WALL_VALUE) && tiles[y][x] != DOCK_VALUE || reached[y][x] >= 0 {
+				continue
+			}
+
+			for cx := x; cx != startX; cx += sign(startX - x) {
+				if tiles[y][cx] == WALL_VALUE {
+					tiles[y][cx] = 0
+				}
+				connect(cx, y)
+			}
+			for cy := y; cy != startY; cy += sign(startY - y) {
+				if tiles[cy][startX] == WALL_VALUE {
+					tiles[cy][startX] = 0
+				}
+				connect(startX, cy)
+			}
+
+			// the path ends next to the start, so all it opened up is reachable
+			for !queue.IsEmpty() {
+				curr, _ := queue.Dequeue()
+				for _, dir := range directions {
+					nx, ny := curr%width+dir[1], curr/width+dir[0]
+					if agent.getTileValue(nx, ny) != WALL_VALUE {
+						connect(nx, ny)
+					}
+				}
+			}
+		}
+	}
+}
+
+func sign(value int) int {
+	if value < 0 {
+		return -1
+	} else if value > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Defines the generator flags on the flag set, the config is filled in once the flags are parsed
+func generatorFlags(flags *flag.FlagSet) *GeneratorConfig {
+	config := GeneratorConfig{}
+	flags.Int64Var(&config.Seed, "seed", 1, "Random seed, the same seed gives the same map")
+	flags.IntVar(&config.Width, "width", 10, "Grid width")
+	flags.IntVar(&config.Height, "height", 10, "Grid height")
+	flags.IntVar(&config.StartX, "start-x", -1, "Starting X, -1 for random")
+	flags.IntVar(&config.StartY, "start-y", -1, "Starting Y, -1 for random")
+	flags.Float64Var(&config.WallDensity, "walls", 0.2, "Share of tiles that are walls")
+	flags.StringVar(&config.Distribution, "dirt", "uniform", "Dirt distribution: uniform, clustered or jackpot")
+	flags.Float64Var(&config.DirtDensity, "dirt-density", 0.3, "Share of free tiles that are dirty (uniform/jackpot) or cluster density")
+	flags.IntVar(&config.MaxDirt, "max-dirt", 300, "Highest regular dirt value")
+	flags.IntVar(&config.Jackpots, "jackpots", 2, "Number of jackpot tiles for the jackpot distribution")
+	flags.IntVar(&config.Battery, "battery", 100, "Starting battery")
+	flags.IntVar(&config.MovementCost, "movement-cost", 1, "Movement cost")
+	flags.IntVar(&config.VacuumingCost, "vacuuming-cost", 5, "Vacuuming cost")
+	flags.BoolVar(&config.Reachable, "reachable", false, "Carve through walls so all dirt and docks are reachable from the start")
+	flags.IntVar(&config.Docks, "docks", 0, "Number of charging docks")
+	flags.IntVar(&config.ChargeRate, "charge-rate", 0, "Battery gained per charge action on a dock, 0 charges fully")
+	flags.BoolVar(&config.MustEndOnDock, "end-on-dock", false, "The run has to end on a dock (needs --docks)")
+	flags.IntVar(&config.Robots, "robots", 1, "Number of robots")
+	flags.Float64Var(&config.Carpets, "carpets", 0, "Share of the grid covered by carpets")
+	flags.StringVar(&config.CarpetTerrain, "carpet-terrain", "2:3", "Movement and vacuuming cost multipliers of carpets")
+	flags.Float64Var(&config.Regrowth, "regrowth", 0, "Share of the floor where dirt comes back")
+	flags.StringVar(&config.RegrowthRate, "regrowth-rate", "1/10", "Dirt regrowing tiles get, \"dirt/steps\"")
+	flags.IntVar(&config.Horizon, "horizon", 0, "Steps the run lasts, 0 for no limit")
+	return &config
+}
+
+func runGenerate(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	config := generatorFlags(flags)
+	count := flags.Int("count", 1, "Number of maps, map i uses seed+i")
+	out := flags.String("out", "", "Output file, or directory when count > 1 (default stdout)")
+	flags.Parse(args)
+
+	if *count == 1 && (*out == "" || filepath.Ext(*out) != "") {
+		initialState, err := GenerateInitialState(*config)
+		if err != nil {
+			return err
+		}
+		return writeGenerated(*out, initialState)
+	}
+
+	if *out == "" {
+		return errors.New("An output directory (--out) is needed when generating more than one map")
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+
+	seed := config.Seed
+	for i := 0; i < *count; i++ {
+		config.Seed = seed + int64(i)
+		initialState, err := GenerateInitialState(*config)
+		if err != nil {
+			return err
+		}
+
+		name := fmt.Sprintf("%s_%dx%d_seed%d.csv", config.Distribution, config.Width, config.Height, config.Seed)
+		if err := writeGenerated(filepath.Join(*out, name), initialState); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeGenerated(filePath string, initialState InitialState) error {
+	var w io.Writer = os.Stdout
+	if filePath != "" {
+		f, err := os.Create(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return WriteInitialState(w, initialState)
+}
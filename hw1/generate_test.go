@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Generator config from command line flags, the defaults for the rest
+func testGeneratorConfig(t *testing.T, args string) GeneratorConfig {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	config := generatorFlags(flags)
+	if err := flags.Parse(strings.Fields(args)); err != nil {
+		t.Fatal(err)
+	}
+	return *config
+}
+
+func TestGenerateRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"negative battery", "--battery=-1", "battery is negative"},
+		{"negative movement cost", "--movement-cost=-1", "movement cost is negative"},
+		{"negative vacuuming cost", "--vacuuming-cost=-2", "vacuuming cost is negative"},
+		{"negative charge rate", "--docks=1 --charge-rate=-5", "charge rate is negative"},
+		{"negative horizon", "--horizon=-1", "horizon is negative"},
+		{"start x below -1", "--start-x=-2", "outside"},
+		{"start y below -1", "--start-y=-3", "outside"},
+		{"start x past the grid", "--width=5 --start-x=5", "outside"},
+		{"empty grid", "--height=0", "grid size"},
+		{"all walls", "--walls=1", "density"},
+		{"unknown distribution", "--dirt=piles", "distribution"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := GenerateInitialState(testGeneratorConfig(t, test.args))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one about %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestGenerateMaps(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+	}{
+		{"defaults", ""},
+		{"fixed start", "--start-x=0 --start-y=9"},
+		{"clustered", "--dirt=clustered --seed=4"},
+		{"jackpot", "--dirt=jackpot --jackpots=5"},
+		{"reachable maze", "--walls=0.6 --reachable --seed=2"},
+		{"docks", "--docks=3 --end-on-dock --reachable"},
+		{"fleet in a maze", "--walls=0.5 --robots=4 --seed=7"},
+		{"everything", "--width=30 --height=7 --carpets=0.3 --regrowth=0.2 --horizon=50 --robots=2 --docks=1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := testGeneratorConfig(t, test.args)
+			initialState, err := GenerateInitialState(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, _ := GenerateInitialState(config)
+			if !reflect.DeepEqual(initialState, again) {
+				t.Error("the same config gave a different map")
+			}
+			if issues := ValidateInitialState(initialState); len(issues) > 0 {
+				t.Errorf("invalid map: %v", issues)
+			}
+			if len(initialState.Robots) != config.Robots-1 {
+				t.Errorf("%d further robots, want %d", len(initialState.Robots), config.Robots-1)
+			}
+
+			agent, err := CreateAgent(initialState, RunOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			reached := bfsDistances(&agent, initialState.X0, initialState.Y0)
+			for _, robot := range initialState.Robots {
+				if reached[robot.Y][robot.X] < 0 {
+					t.Errorf("robot at (%d, %d) cannot be reached from the start", robot.X, robot.Y)
+				}
+			}
+			for y, row := range agent.tiles {
+				for x, tile := range row {
+					if config.Reachable && (dirtOf(tile) > 0 || tile == DOCK_VALUE) && reached[y][x] < 0 {
+						t.Errorf("tile (%d, %d) with %d cannot be reached from the start", x, y, tile)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+var RL_KINDS = []string{"tabular", "approximate"}
+var RL_METHODS = []string{"qlearning", "sarsa"}
+var RL_ACTIONS = []Action{ActionUp, ActionDown, ActionLeft, ActionRight, ActionVacuum, ActionCharge}
+var RL_FEATURES = []string{"bias", "dirt vacuumed", "dirt ahead", "towards target", "battery spent", "charge when low"}
+
+const RL_MAX_STEPS = 10_000   // Steps before an episode is cut off, a learned policy may walk in circles
+const RL_REWARD_SCALE = 100.0 // Dirt per unit of reward
+const RL_MAX_TD_ERROR = 10.0  // Updates are clipped to keep jackpot tiles from blowing up the weights
+
+func init() {
+	registerCommand("train", "[--agent=tabular|approximate] [--method=qlearning|sarsa] [--episodes=N] [generator flags] --out=<weights file> [maps...]",
+		"Trains a Q-learning or SARSA agent on the given maps or on generated ones, reproducible from the seed",
+		runTrain)
+	registerCommand("eval", "[--maps=N] [generator flags] <weights file> [maps...]",
+		"Runs a trained agent on the given or generated maps and compares it with the optimal algorithm",
+		runEval)
+}
+
+// QPolicy is a learned action value function: a table over coarse observations of the surroundings,
+// or linear weights over RL_FEATURES. It is saved as JSON together with how it was trained.
+type QPolicy struct {
+	Kind     string               `json:"kind"`   // one of RL_KINDS
+	Method   string               `json:"method"` // one of RL_METHODS
+	Seed     int64                `json:"seed"`
+	Episodes int                  `json:"episodes"`
+	Alpha    float64              `json:"alpha"`
+	Gamma    float64              `json:"gamma"`
+	Epsilon  float64              `json:"epsilon"`
+	Table    map[string][]float64 `json:"table,omitempty"` // values of RL_ACTIONS per observation
+	Features []string             `json:"features,omitempty"`
+	Weights  []float64            `json:"weights,omitempty"`
+}
+
+// What the learner sees of the agent in a state
+type rlObservation struct {
+	key      string      // observation of the tabular agent
+	features [][]float64 // RL_FEATURES of every action, for the approximate agent
+	legal    []bool      // actions that are possible and affordable
+}
+
+// Observes the agent: the four neighbors, the current tile, the direction towards the dirty tile with the
+// most dirt per battery within reach (as the online algorithm picks it) and the battery left in quarters.
+func observe(agent *Agent) rlObservation {
+	route := dijkstraWithin(agent, [][2]int{{agent.posX, agent.posY}}, false, agent.battery, nil)
+	target, targetDirt, targetCost := [2]int{-1, -1}, 0, 0
+	for _, i := range route.order {
+		x, y := route.position(i)
+		dirt := dirtOf(agent.tiles[y][x])
+		cost := route.cost[i] + agent.vacuumCostAt(x, y)
+		if dirt == 0 || cost > agent.battery {
+			continue
+		}
+		if target[0] < 0 || betterValue(dirt, cost, targetDirt, targetCost) {
+			target, targetDirt, targetCost = [2]int{x, y}, dirt, cost
+		}
+	}
+
+	hint := -1 // RL_ACTIONS index that heads for the target
+	if target[0] == agent.posX && target[1] == agent.posY {
+		hint = 4
+	} else if target[0] >= 0 {
+		dir := firstMove(route, target[0], target[1])
+		for i, action := range RL_ACTIONS[:4] {
+			if dx, dy := actionDelta(action); dx == dir[1] && dy == dir[0] {
+				hint = i
+			}
+		}
+	}
+
+	capacity := math.Max(1, float64(agent.capacity))
+	obs := rlObservation{legal: make([]bool, len(RL_ACTIONS)), features: make([][]float64, len(RL_ACTIONS))}
+	key := []byte{}
+	for i, action := range RL_ACTIONS {
+		features := make([]float64, len(RL_FEATURES))
+		features[0] = 1
+		if i == hint {
+			features[3] = 1
+		}
+
+		switch action {
+		case ActionVacuum:
+			dirt := dirtOf(agent.currentTile())
+			cost := agent.vacuumCostAt(agent.posX, agent.posY)
+			obs.legal[i] = dirt > 0 && cost <= agent.battery
+			features[1] = float64(dirt) / RL_REWARD_SCALE
+			features[4] = float64(cost) / capacity
+			if dirt > 0 {
+				key = append(key, '*')
+			} else {
+				key = append(key, '.')
+			}
+
+		case ActionCharge:
+			obs.legal[i] = agent.onDock() && agent.battery < agent.capacity
+			features[5] = 1 - float64(agent.battery)/capacity
+
+		default:
+			dx, dy := actionDelta(action)
+			x, y := agent.posX+dx, agent.posY+dy
+			tile := agent.getTileValue(x, y)
+			cost := agent.moveCostTo(x, y)
+			obs.legal[i] = tile != WALL_VALUE && cost <= agent.battery
+			features[2] = float64(dirtOf(tile)) / RL_REWARD_SCALE
+			features[4] = float64(cost) / capacity
+			switch {
+			case tile == WALL_VALUE:
+				key = append(key, '#')
+			case tile == DOCK_VALUE:
+				key = append(key, '+')
+			case dirtOf(tile) > 0:
+				key = append(key, '*')
+			default:
+				key = append(key, '.')
+			}
+		}
+		obs.features[i] = features
+	}
+
+	obs.key = fmt.Sprintf("%s %d %d", key, hint, 4*agent.battery/int(capacity))
+	return obs
+}
+
+func (policy *QPolicy) value(obs rlObservation, action int) float64 {
+	if policy.Kind == "tabular" {
+		if values, ok := policy.Table[obs.key]; ok {
+			return values[action]
+		}
+		return 0
+	}
+
+	value := 0.0
+	for i, feature := range obs.features[action] {
+		value += policy.Weights[i] * feature
+	}
+	return value
+}
+
+// Best legal action, -1 when there is none. Ties go to a random action when random is given, else the first.
+func (policy *QPolicy) best(obs rlObservation, random *rand.Rand) int {
+	best, ties := -1, 0
+	for action, legal := range obs.legal {
+		if !legal {
+			continue
+		}
+		if best < 0 || policy.value(obs, action) > policy.value(obs, best) {
+			best, ties = action, 1
+		} else if policy.value(obs, action) == policy.value(obs, best) && random != nil {
+			if ties++; random.Intn(ties) == 0 {
+				best = action
+			}
+		}
+	}
+	return best
+}
+
+// Epsilon-greedy action, -1 when there is none
+func (policy *QPolicy) choose(obs rlObservation, epsilon float64, random *rand.Rand) int {
+	legal := []int{}
+	for action, ok := range obs.legal {
+		if ok {
+			legal = append(legal, action)
+		}
+	}
+	if len(legal) == 0 {
+		return -1
+	}
+	if random.Float64() < epsilon {
+		return legal[random.Intn(len(legal))]
+	}
+	return policy.best(obs, random)
+}
+
+// Moves the value of the action in the observed state towards target
+func (policy *QPolicy) update(obs rlObservation, action int, target float64) {
+	change := math.Max(-RL_MAX_TD_ERROR, math.Min(RL_MAX_TD_ERROR, target-policy.value(obs, action)))
+	if policy.Kind == "tabular" {
+		if _, ok := policy.Table[obs.key]; !ok {
+			policy.Table[obs.key] = make([]float64, len(RL_ACTIONS))
+		}
+		policy.Table[obs.key][action] += policy.Alpha * change
+		return
+	}
+
+	for i, feature := range obs.features[action] {
+		policy.Weights[i] += policy.Alpha * change * feature
+	}
+}
+
+// Runs one training episode on the map and returns the dirt cleaned
+func (policy *QPolicy) trainEpisode(initialState InitialState, random *rand.Rand) (int, error) {
+	agent, err := CreateAgent(initialState, RunOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	obs := observe(&agent)
+	action := policy.choose(obs, policy.Epsilon, random)
+	for steps := 0; action >= 0 && steps < RL_MAX_STEPS; steps++ {
+		cleanedBefore := agent.dirtCleaned
+		agent.perform(RL_ACTIONS[action])
+		reward := float64(agent.dirtCleaned-cleanedBefore) / RL_REWARD_SCALE
+
+		next := observe(&agent)
+		nextAction := policy.choose(next, policy.Epsilon, random)
+		target := reward
+		if nextAction >= 0 && policy.Method == "sarsa" {
+			target += policy.Gamma * policy.value(next, nextAction)
+		} else if nextAction >= 0 {
+			target += policy.Gamma * policy.value(next, policy.best(next, nil))
+		}
+		policy.update(obs, action, target)
+
+		obs, action = next, nextAction
+	}
+
+	return agent.dirtCleaned, nil
+}
+
+// RunQPolicy cleans the map taking the best learned action in every state, without exploring
+func RunQPolicy(policy *QPolicy, initialState InitialState) (PlanResult, error) {
+	agent, err := CreateAgent(initialState, RunOptions{})
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	agent.logs = append(agent.logs, fmt.Sprintf("Initial position: (%d, %d)", agent.posX, agent.posY))
+	for steps := 0; steps < RL_MAX_STEPS; steps++ {
+		action := policy.best(observe(&agent), nil)
+		if action < 0 {
+			break
+		}
+		agent.perform(RL_ACTIONS[action])
+	}
+
+	return agent.result(), nil
+}
+
+func isOneOf(value string, known []string) bool {
+	for _, option := range known {
+		if value == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Maps to train or evaluate on: the given files, or count maps generated from consecutive seeds
+func learningMaps(files []string, config GeneratorConfig, count int) ([]InitialState, error) {
+	maps := []InitialState{}
+	for _, file := range files {
+		initialState, err := ReadValidInitialState(file, "")
+		if err != nil {
+			return nil, err
+		}
+		maps = append(maps, initialState)
+	}
+
+	seed := config.Seed
+	for i := 0; len(files) == 0 && i < count; i++ {
+		config.Seed = seed + int64(i)
+		initialState, err := GenerateInitialState(config)
+		if err != nil {
+			return nil, err
+		}
+		initialState.source = fmt.Sprintf("generated (seed %d)", config.Seed)
+		maps = append(maps, initialState)
+	}
+
+	return maps, nil
+}
+
+func runTrain(args []string) error {
+	flags := flag.NewFlagSet("train", flag.ExitOnError)
+	config := generatorFlags(flags)
+	policy := QPolicy{}
+	flags.StringVar(&policy.Kind, "agent", "tabular", "Agent: tabular or approximate (linear in the features)")
+	flags.StringVar(&policy.Method, "method", "qlearning", "Update: qlearning (off-policy) or sarsa (on-policy)")
+	flags.IntVar(&policy.Episodes, "episodes", 1000, "Training episodes, one map each (generated maps use seed+episode)")
+	flags.Float64Var(&policy.Alpha, "alpha", 0.1, "Learning rate")
+	flags.Float64Var(&policy.Gamma, "gamma", 0.95, "Discount of future rewards")
+	flags.Float64Var(&policy.Epsilon, "epsilon", 0.1, "Share of random actions while training")
+	out := flags.String("out", "", "File the learned weights are saved to")
+	flags.Parse(args)
+
+	if *out == "" {
+		return commandUsage("train")
+	}
+	if !isOneOf(policy.Kind, RL_KINDS) || !isOneOf(policy.Method, RL_METHODS) {
+		return fmt.Errorf("Unknown agent %s or method %s, expected one of %v and %v", policy.Kind, policy.Method, RL_KINDS, RL_METHODS)
+	}
+
+	maps, err := learningMaps(flags.Args(), *config, policy.Episodes)
+	if err != nil {
+		return err
+	}
+
+	policy.Seed = config.Seed
+	if policy.Kind == "tabular" {
+		policy.Table = map[string][]float64{}
+	} else {
+		policy.Features, policy.Weights = RL_FEATURES, make([]float64, len(RL_FEATURES))
+	}
+
+	random := rand.New(rand.NewSource(policy.Seed))
+	report := policy.Episodes / 10
+	if report == 0 {
+		report = 1
+	}
+	cleaned := 0
+	for episode := 0; episode < policy.Episodes; episode++ {
+		dirt, err := policy.trainEpisode(maps[episode%len(maps)], random)
+		if err != nil {
+			return fmt.Errorf("Episode %d on %s: %v", episode+1, maps[episode%len(maps)].source, err)
+		}
+
+		cleaned += dirt
+		if (episode+1)%report == 0 || episode+1 == policy.Episodes {
+			fmt.Printf("Episodes up to %d: %.1f dirt cleaned on average\n", episode+1, float64(cleaned)/float64(episode%report+1))
+			cleaned = 0
+		}
+	}
+
+	encoded, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*out, append(encoded, '\n'), 0o644)
+}
+
+// LoadQPolicy reads weights saved by the train command
+func LoadQPolicy(filePath string) (*QPolicy, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file %s: %v", filePath, err)
+	}
+
+	policy := QPolicy{}
+	if err := json.Unmarshal(content, &policy); err != nil {
+		return nil, fmt.Errorf("Error parsing weights %s: %v", filePath, err)
+	}
+	if policy.Kind == "tabular" {
+		for key, values := range policy.Table {
+			if len(values) != len(RL_ACTIONS) {
+				return nil, fmt.Errorf("Weights %s: %d values for %q, expected one per action %v", filePath, len(values), key, RL_ACTIONS)
+			}
+		}
+	} else if policy.Kind != "approximate" || strings.Join(policy.Features, ",") != strings.Join(RL_FEATURES, ",") ||
+		len(policy.Weights) != len(RL_FEATURES) {
+		return nil, fmt.Errorf("Weights %s are not for a tabular agent or for the features %v", filePath, RL_FEATURES)
+	}
+
+	return &policy, nil
+}
+
+func runEval(args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	config := generatorFlags(flags)
+	count := flags.Int("maps", 20, "Generated maps to evaluate on when no map files are given, use a --seed the agent was not trained on")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		return commandUsage("eval")
+	}
+
+	policy, err := LoadQPolicy(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	maps, err := learningMaps(flags.Args()[1:], *config, *count)
+	if err != nil {
+		return err
+	}
+
+	learnedTotal, optimalTotal := 0, 0
+	for _, initialState := range maps {
+		learned, err := RunQPolicy(policy, initialState)
+		if err != nil {
+			return err
+		}
+		optimal, err := FindAndTraverseOptimalPath(initialState, RunOptions{})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: learned %d, optimal %d\n", initialState.source, learned.DirtCleaned, optimal.DirtCleaned)
+		learnedTotal += learned.DirtCleaned
+		optimalTotal += optimal.DirtCleaned
+	}
+
+	ratio := 100.0
+	if optimalTotal > 0 {
+		ratio = 100 * float64(learnedTotal) / float64(optimalTotal)
+	}
+	fmt.Printf("Total over %d maps: learned %d, optimal %d (%.1f%%)\n", len(maps), learnedTotal, optimalTotal, ratio)
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTrainSaveAndLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+	}{
+		{"tabular qlearning", "--agent=tabular --method=qlearning"},
+		{"tabular sarsa", "--agent=tabular --method=sarsa --alpha=0.3 --gamma=0.5"},
+		{"approximate qlearning", "--agent=approximate --method=qlearning --epsilon=0.3"},
+		{"approximate sarsa", "--agent=approximate --method=sarsa"},
+	}
+	flags := " --episodes=20 --seed=4 --width=6 --height=5 --battery=40 --vacuuming-cost=2 "
+	evaluated := testState(30, 1, 1, "0,5,0,9001", "3,9001,7,0", "0,0,0,8")
+	optimal := testResult(t, "optimal", evaluated, RunOptions{})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			saved := [2][]byte{}
+			for i := range saved {
+				out := filepath.Join(dir, []string{"first.json", "second.json"}[i])
+				if err := runTrain(strings.Fields(test.args + flags + "--out=" + out)); err != nil {
+					t.Fatal(err)
+				}
+				content, err := os.ReadFile(out)
+				if err != nil {
+					t.Fatal(err)
+				}
+				saved[i] = content
+			}
+			if !bytes.Equal(saved[0], saved[1]) {
+				t.Error("training twice from the same seed saved different weights")
+			}
+
+			policy, err := LoadQPolicy(filepath.Join(dir, "first.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kind := "--agent=" + policy.Kind + " --method=" + policy.Method; !strings.HasPrefix(test.args, kind) ||
+				policy.Episodes != 20 || policy.Seed != 4 {
+				t.Errorf("loaded %s, %d episodes from seed %d, want %s, 20 episodes from seed 4", kind, policy.Episodes, policy.Seed, test.args)
+			}
+			if policy.Kind == "tabular" && len(policy.Table) == 0 || policy.Kind == "approximate" && len(policy.Weights) != len(RL_FEATURES) {
+				t.Errorf("nothing learned: %d observations, %d weights", len(policy.Table), len(policy.Weights))
+			}
+
+			// the loaded policy acts greedily, the same way every run and never better than optimal
+			first, err := RunQPolicy(policy, evaluated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, _ := RunQPolicy(policy, evaluated)
+			if !reflect.DeepEqual(first.Steps, second.Steps) {
+				t.Error("two runs of the same policy took different steps")
+			}
+			if first.DirtCleaned > optimal.DirtCleaned || first.BatteryLeft < 0 {
+				t.Errorf("cleaned %d dirt with %d battery left, optimal cleans %d", first.DirtCleaned, first.BatteryLeft, optimal.DirtCleaned)
+			}
+		})
+	}
+}
+
+func TestTrainRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"no output file", "--episodes=1", "Usage"},
+		{"unknown agent", "--agent=deep --out=w.json", "Unknown agent"},
+		{"unknown method", "--method=td --out=w.json", "Unknown agent"},
+		{"missing map", "--out=w.json missing.csv", "Error opening file"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := strings.ReplaceAll(test.args, "w.json", filepath.Join(t.TempDir(), "w.json"))
+			if err := runTrain(strings.Fields(args)); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one about %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadQPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"not json", `{"kind": "tabular",`, "Error parsing weights"},
+		{"values per action", `{"kind": "tabular", "table": {"a": [1, 2]}}`, "one per action"},
+		{"unknown kind", `{"kind": "deep"}`, "not for a tabular agent"},
+		{"other features", `{"kind": "approximate", "features": ["bias"], "weights": [1]}`, "not for a tabular agent"},
+		{"weights per feature", `{"kind": "approximate", "features": ["bias", "dirt vacuumed", "dirt ahead", "towards target",
+			"battery spent", "charge when low"], "weights": [1, 2]}`, "not for a tabular agent"},
+		{"missing file", "", "Error opening file"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "weights.json")
+			if test.content != "" {
+				if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := LoadQPolicy(path); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one about %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestQPolicyBest(t *testing.T) {
+	policy := QPolicy{Kind: "tabular", Table: map[string][]float64{"seen": {0.5, 2, 1, 2, -1, 0}}}
+	all := []bool{true, true, true, true, true, true}
+	tests := []struct {
+		name   string
+		key    string
+		legal  []bool
+		action int
+	}{
+		{"highest value", "seen", all, 1},
+		{"highest legal value", "seen", []bool{true, false, true, false, true, true}, 2},
+		{"ties go to the first", "unseen", all, 0},
+		{"nothing legal", "seen", make([]bool, len(RL_ACTIONS)), -1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if action := policy.best(rlObservation{key: test.key, legal: test.legal}, nil); action != test.action {
+				t.Errorf("best action %d, want %d", action, test.action)
+			}
+		})
+	}
+}
@@ -1,17 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"encoding/csv"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 const PRINT_MOVES = true // Print the moves made by the agent
 const WALL_VALUE = 9001
+const DOCK_VALUE = 9002 // Charging station, walkable and never dirty
 
 type InitialState struct {
 	X0            int
@@ -20,20 +26,85 @@ type InitialState struct {
 	MovementCost  int
 	VacuumingCost int
 	Tiles         [][]string
+	ChargeRate    int  // Battery restored per charge action on a dock, 0 charges fully at once
+	MustEndOnDock bool // The run has to finish on a dock
+	// Optional layer of the same shape as Tiles with the terrain of every tile: "m" multiplies
+	// the movement cost (entering the tile) and the vacuuming cost by m, "m:v" sets them separately.
+	// nil means plain floor (1) everywhere.
+	Terrain [][]string
+	Robots  []RobotStart // Further robots of a fleet, the first robot is X0, Y0 and Battery
+	// Manhattan distance within which the robot sees tiles (1: only the tiles next to it),
+	// 0 means it knows the whole map. Only exploring planners keep to it.
+	SensorRadius int
+	// Optional layer of the same shape as Tiles with dirt regrowth: "a/n" adds a dirt every n steps,
+	// "a" every step, "0" never. nil means dirt never comes back.
+	Regrowth [][]string
+	Events   []DirtEvent // Scripted dirt, e.g. at step 20 tile (3, 4) gets 50 dirt
+	Horizon  int         // Steps the run lasts, 0 runs until the planner stops
+	// Chance a move goes where it was meant to on a slippery floor, otherwise the robot slides
+	// sideways or stays put. 0 (or 1) means moves always succeed.
+	MoveSuccess float64
+
+	// where the values came from, only set by ReadInitialState (used for validation messages)
+	source            string
+	settingLines      [10]int
+	tilePositions     [][][2]int // line and column of every tile
+	terrainPositions  [][][2]int
+	regrowthPositions [][][2]int
+	robotLines        []int
+	eventLines        []int
+}
+
+// DirtEvent adds dirt to a tile once the given number of steps was taken
+type DirtEvent struct {
+	Step int `json:"step"`
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	Dirt int `json:"dirt"`
+}
+
+// Start position and battery of a further robot
+type RobotStart struct {
+	X       int `json:"x"`
+	Y       int `json:"y"`
+	Battery int `json:"battery"`
 }
 
 // Parse the initial state from a CSV file
 // https://stackoverflow.com/a/58841827
+// Optional settings are given as comment lines, e.g. "# charge rate: 10", "# end on dock: yes",
+// "# sensor radius: 2", "# horizon: 200", "# move success: 0.8", "# robot: x, y, battery" for every further robot of a fleet
+// and "# event: step, x, y, dirt" for every scripted dirt event.
+// Rows after a "# terrain" or "# regrowth" comment line are that layer instead of tiles.
 func ReadInitialState(filePath string) (InitialState, error) {
-	initialState := InitialState{}
+	initialState := InitialState{source: filePath}
 
-	f, err := os.Open(filePath)
+	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return initialState, errors.New(fmt.Sprintf("Error opening file %s: %v", filePath, err))
+		return initialState, fmt.Errorf("Error opening file %s: %v", filePath, err)
 	}
-	defer f.Close()
 
-	csvReader := csv.NewReader(f)
+	layerLines := map[string]int{} // line of the "# terrain" and "# regrowth" comments
+	for i, line := range strings.Split(string(content), "\n") {
+		comment, isComment := strings.CutPrefix(strings.TrimSpace(line), "#")
+		layer := strings.ToLower(strings.TrimSpace(comment))
+		if isComment && (layer == "terrain" || layer == "regrowth") && layerLines[layer] == 0 {
+			layerLines[layer] = i + 1
+			continue
+		}
+
+		key, value, isSetting := strings.Cut(comment, ":")
+		if !isComment || !isSetting {
+			continue
+		}
+
+		err := parseCommentSetting(&initialState, strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value), i+1)
+		if err != nil {
+			return initialState, fmt.Errorf("Error parsing settings from file %s:%d: %v", filePath, i+1, err)
+		}
+	}
+
+	csvReader := csv.NewReader(bytes.NewReader(content))
 	csvReader.Comment = '#'
 	csvReader.FieldsPerRecord = -1 // Allow variable number of fields per record
 
@@ -41,13 +112,15 @@ func ReadInitialState(filePath string) (InitialState, error) {
 		// First five lines are settings
 		setting, err := csvReader.Read()
 		if err != nil {
-			return initialState, errors.New(fmt.Sprintf("Error reading file %s: %v", filePath, err))
+			return initialState, fmt.Errorf("Error reading file %s: %v", filePath, err)
 		}
 
+		line, _ := csvReader.FieldPos(0)
 		value, err := strconv.Atoi(strings.TrimSpace(strings.Split(setting[0], "#")[0]))
 		if err != nil {
-			return initialState, errors.New(fmt.Sprintf("Error parsing settings from file %s: %v", filePath, err))
+			return initialState, fmt.Errorf("Error parsing settings from file %s:%d: %v", filePath, line, err)
 		}
+		initialState.settingLines[i] = line
 
 		if i == 0 {
 			initialState.X0 = value
@@ -66,34 +139,364 @@ func ReadInitialState(filePath string) (InitialState, error) {
 		}
 	}
 
-	initialState.Tiles, err = csvReader.ReadAll()
-	if err != nil {
-		return initialState, errors.New(fmt.Sprintf("Error reading file %s: %v", filePath, err))
+	initialState.Tiles = [][]string{}
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return initialState, fmt.Errorf("Error reading file %s: %v", filePath, err)
+		}
+
+		positions := make([][2]int, len(row))
+		for i, field := range row {
+			positions[i][0], positions[i][1] = csvReader.FieldPos(i)
+			positions[i][1] += len(field) - len(strings.TrimLeft(field, " \t")) // point at the value, not the padding
+		}
+		// rows belong to the last layer comment above them
+		layer := ""
+		for name, line := range layerLines {
+			if positions[0][0] > line && (layer == "" || line > layerLines[layer]) {
+				layer = name
+			}
+		}
+		if layer == "terrain" {
+			initialState.Terrain = append(initialState.Terrain, row)
+			initialState.terrainPositions = append(initialState.terrainPositions, positions)
+			continue
+		}
+		if layer == "regrowth" {
+			initialState.Regrowth = append(initialState.Regrowth, row)
+			initialState.regrowthPositions = append(initialState.regrowthPositions, positions)
+			continue
+		}
+		initialState.Tiles = append(initialState.Tiles, row)
+		initialState.tilePositions = append(initialState.tilePositions, positions)
 	}
 
 	return initialState, nil
 }
 
-func main() {
-	if len(os.Args) != 3 {
-		fmt.Println("Usage: clean.exe <algorithm('greedy'|'optimal')> <input csv file>")
-		return
+// Sets "charge rate", "end on dock" (yes/no), "sensor radius", "horizon", "move success" or adds a "robot" (x, y, battery)
+// or an "event" (step, x, y, dirt),
+// other keys are ignored so free-text comments stay allowed
+func parseCommentSetting(initialState *InitialState, key string, value string, line int) error {
+	switch key {
+	case "robot":
+		robot := RobotStart{}
+		if err := parseIntegers(value, &robot.X, &robot.Y, &robot.Battery); err != nil {
+			return fmt.Errorf("robot must be \"x, y, battery\", got %q", value)
+		}
+		initialState.Robots = append(initialState.Robots, robot)
+		initialState.robotLines = append(initialState.robotLines, line)
+
+	case "event":
+		event := DirtEvent{}
+		if err := parseIntegers(value, &event.Step, &event.X, &event.Y, &event.Dirt); err != nil {
+			return fmt.Errorf("event must be \"step, x, y, dirt\", got %q", value)
+		}
+		initialState.Events = append(initialState.Events, event)
+		initialState.eventLines = append(initialState.eventLines, line)
+
+	case "horizon":
+		horizon, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("horizon must be an integer, got %q", value)
+		}
+		initialState.Horizon = horizon
+		initialState.settingLines[8] = line
+
+	case "move success":
+		chance, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("move success must be a number, got %q", value)
+		}
+		initialState.MoveSuccess = chance
+		initialState.settingLines[9] = line
+
+	case "charge rate":
+		rate, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("charge rate must be an integer, got %q", value)
+		}
+		initialState.ChargeRate = rate
+		initialState.settingLines[5] = line
+
+	case "end on dock":
+		switch strings.ToLower(value) {
+		case "yes", "true":
+			initialState.MustEndOnDock = true
+		case "no", "false":
+			initialState.MustEndOnDock = false
+		default:
+			return fmt.Errorf("end on dock must be yes or no, got %q", value)
+		}
+		initialState.settingLines[6] = line
+
+	case "sensor radius":
+		radius, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("sensor radius must be an integer, got %q", value)
+		}
+		initialState.SensorRadius = radius
+		initialState.settingLines[7] = line
 	}
 
-	algorithm, filePath := os.Args[1], os.Args[2]
-	initialState, err := ReadInitialState(filePath)
+	return nil
+}
+
+// Parses comma separated integers into the given variables, exactly as many as there are
+func parseIntegers(value string, numbers ...*int) error {
+	fields := strings.Split(value, ",")
+	if len(fields) != len(numbers) {
+		return fmt.Errorf("expected %d numbers, got %d", len(numbers), len(fields))
+	}
+	for i, field := range fields {
+		number, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return err
+		}
+		*numbers[i] = number
+	}
+	return nil
+}
+
+// Dirt added and period in steps of a regrowth cell, "a/n", "a" (every step) or "0"
+func parseRegrowth(cell string) ([2]int, error) {
+	amount, period, periodic := strings.Cut(strings.TrimSpace(cell), "/")
+	if !periodic {
+		period = "1"
+	}
+
+	dirt, errDirt := strconv.Atoi(strings.TrimSpace(amount))
+	steps, errSteps := strconv.Atoi(strings.TrimSpace(period))
+	if errDirt != nil || errSteps != nil {
+		return [2]int{}, fmt.Errorf("regrowth %q is not \"dirt/steps\" or an integer", strings.TrimSpace(cell))
+	}
+	if dirt < 0 || dirt >= WALL_VALUE || steps < 1 {
+		return [2]int{}, fmt.Errorf("regrowth %q needs dirt between 0 and %d and at least 1 step", strings.TrimSpace(cell), WALL_VALUE-1)
+	}
+
+	return [2]int{dirt, steps}, nil
+}
+
+// Movement and vacuuming multipliers of a terrain cell, "m" or "m:v"
+func parseTerrain(cell string) ([2]int, error) {
+	move, vacuum, separate := strings.Cut(strings.TrimSpace(cell), ":")
+	if !separate {
+		vacuum = move
+	}
+
+	moveMultiplier, errMove := strconv.Atoi(strings.TrimSpace(move))
+	vacuumMultiplier, errVacuum := strconv.Atoi(strings.TrimSpace(vacuum))
+	if errMove != nil || errVacuum != nil {
+		return [2]int{}, fmt.Errorf("terrain %q is not an integer multiplier or \"movement:vacuuming\"", strings.TrimSpace(cell))
+	}
+	if moveMultiplier < 0 || vacuumMultiplier < 0 {
+		return [2]int{}, fmt.Errorf("terrain %q has a negative multiplier", strings.TrimSpace(cell))
+	}
+
+	return [2]int{moveMultiplier, vacuumMultiplier}, nil
+}
+
+// Write the initial state in the same CSV format ReadInitialState parses
+func WriteInitialState(w io.Writer, initialState InitialState) error {
+	_, err := fmt.Fprintf(w, "%d # Starting X - horizontal value from left - zero based\n"+
+		"%d # Starting Y - vertical value from top  - zero based\n"+
+		"%d # Starting Battery\n"+
+		"%d # Movement Cost\n"+
+		"%d # Vaccuming Cost\n",
+		initialState.X0, initialState.Y0, initialState.Battery, initialState.MovementCost, initialState.VacuumingCost)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	if initialState.ChargeRate != 0 {
+		fmt.Fprintf(w, "# charge rate: %d\n", initialState.ChargeRate)
+	}
+	if initialState.MustEndOnDock {
+		fmt.Fprintln(w, "# end on dock: yes")
+	}
+	if initialState.SensorRadius != 0 {
+		fmt.Fprintf(w, "# sensor radius: %d\n", initialState.SensorRadius)
+	}
+	if initialState.Horizon != 0 {
+		fmt.Fprintf(w, "# horizon: %d\n", initialState.Horizon)
+	}
+	if initialState.MoveSuccess != 0 {
+		fmt.Fprintf(w, "# move success: %s\n", strconv.FormatFloat(initialState.MoveSuccess, 'g', -1, 64))
+	}
+	for _, event := range initialState.Events {
+		fmt.Fprintf(w, "# event: %d, %d, %d, %d\n", event.Step, event.X, event.Y, event.Dirt)
+	}
+	for _, robot := range initialState.Robots {
+		fmt.Fprintf(w, "# robot: %d, %d, %d\n", robot.X, robot.Y, robot.Battery)
+	}
+
+	for _, row := range initialState.Tiles {
+		cells := []string{}
+		for _, tile := range row {
+			cells = append(cells, strings.TrimSpace(tile))
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(cells, ", ")); err != nil {
+			return err
+		}
+	}
+
+	for _, layer := range []struct {
+		name  string
+		cells [][]string
+	}{{"terrain", initialState.Terrain}, {"regrowth", initialState.Regrowth}} {
+		if layer.cells != nil {
+			fmt.Fprintf(w, "# %s\n", layer.name)
+		}
+		for _, row := range layer.cells {
+			cells := []string{}
+			for _, cell := range row {
+				cells = append(cells, strings.TrimSpace(cell))
+			}
+			if _, err := fmt.Fprintln(w, strings.Join(cells, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Subcommand that runs instead of a planner, e.g. "cleaner verify ..."
+type command struct {
+	usage       string
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{}
+
+// Makes a subcommand available by name. Meant to be called from init().
+func registerCommand(name string, usage string, description string, run func(args []string) error) {
+	commands[name] = command{usage: usage, description: description, run: run}
+}
+
+// Name the program was started as, for usage messages
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// Usage error of a subcommand, with the flags and arguments it was registered with
+func commandUsage(name string) error {
+	return fmt.Errorf("Usage: %s %s %s", programName(), name, commands[name].usage)
+}
+
+func printUsage() {
+	fmt.Printf("Usage: %s [--output=text|json|csv] [--format=csv|json|ascii] [--charge-rate=N] [--end-on-dock] [--sensor-radius=N] [--horizon=N] [--move-success=P] [--seed=N] [--rollouts=N] [--time-budget=D] [--max-steps=N] [--explain] <algorithm> <input map>\n", programName())
+	fmt.Println("Algorithms:")
+	for _, name := range PlannerNames() {
+		planner, _ := GetPlanner(name)
+		fmt.Printf("  %-14s %s\n", name, planner.Description())
+	}
+
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("Commands:")
+	for _, name := range names {
+		fmt.Printf("  %s %s %s\n", programName(), name, commands[name].usage)
+		fmt.Printf("      %s\n", commands[name].description)
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 	}
 
-	if algorithm == "greedy" {
-		FindAndTraverseGreedyPath(initialState)
+	outputPtr := flag.String("output", "text", "Output format: text, json or csv")
+	formatPtr := flag.String("format", "", "Input map format: csv, json or ascii (default from the extension)")
+	chargeRatePtr := flag.Int("charge-rate", -1, "Battery restored per charge action on a dock, 0 for a full charge (default from the map)")
+	endOnDockPtr := flag.Bool("end-on-dock", false, "The run has to finish on a dock")
+	horizonPtr := flag.Int("horizon", -1, "Number of steps the run lasts, 0 until the algorithm stops (default from the map)")
+	sensorRadiusPtr := flag.Int("sensor-radius", -1, "Distance within which exploring algorithms see tiles (default from the map, explore falls back to 1)")
+	moveSuccessPtr := flag.Float64("move-success", -1, "Chance a move succeeds on a slippery floor, 1 for a plain floor (default from the map)")
+	seedPtr := flag.Int64("seed", 1, "Seed of the slips on a slippery floor")
+	rolloutsPtr := flag.Int("rollouts", 1, "Runs with seeds seed, seed+1, ... to report the expected score over")
+	timeBudgetPtr := flag.Duration("time-budget", 0, "Time the anytime algorithm may search, e.g. 500ms or 10s (default 1s)")
+	maxStepsPtr := flag.Int("max-steps", 0, "Most actions a run may take, 0 for 10 per tile (at least 100000), -1 for no limit")
+	explainPtr := flag.Bool("explain", false, "Record why the optimal algorithm chose each move and print it with the trajectory")
+	flag.Usage = printUsage
+	flag.Parse()
 
-	} else if algorithm == "optimal" {
-		FindAndTraverseOptimalPath(initialState)
+	if flag.NArg() != 2 {
+		printUsage()
+		return
+	}
 
-	} else {
+	if !isOutputFormat(*outputPtr) {
+		fmt.Printf("Invalid output format %s\n", *outputPtr)
+		printUsage()
+		return
+	}
+
+	algorithm, filePath := flag.Arg(0), flag.Arg(1)
+	planner, err := GetPlanner(algorithm)
+	if err != nil {
 		fmt.Println("Invalid algorithm")
+		printUsage()
 		return
 	}
+	if *rolloutsPtr > 1 && algorithm == MANUAL_PLANNER {
+		log.Fatal(errors.New("--rollouts replays the run with other seeds, which the manual algorithm cannot do"))
+	}
+
+	initialState, err := ReadValidInitialState(filePath, *formatPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *chargeRatePtr >= 0 {
+		initialState.ChargeRate = *chargeRatePtr
+	}
+	initialState.MustEndOnDock = initialState.MustEndOnDock || *endOnDockPtr
+	if *sensorRadiusPtr >= 0 {
+		initialState.SensorRadius = *sensorRadiusPtr
+	}
+	if *horizonPtr >= 0 {
+		initialState.Horizon = *horizonPtr
+	}
+	if *moveSuccessPtr >= 0 {
+		initialState.MoveSuccess = *moveSuccessPtr
+	}
+	options := RunOptions{Seed: *seedPtr, TimeBudget: *timeBudgetPtr, StepLimit: *maxStepsPtr, Explain: *explainPtr}
+	if len(initialState.Robots) > 0 {
+		log.Fatal(fmt.Errorf("%s has %d robots, plan it with: %s fleet %s", filePath, len(initialState.Robots)+1, programName(), filePath))
+	}
+
+	result, err := planner.Plan(initialState, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *rolloutsPtr > 1 {
+		summary, err := RunRollouts(planner, initialState, options, result, *rolloutsPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		result.Rollouts = &summary
+	}
+	result.Bound, err = ComputeDirtBound(initialState)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = writeResult(os.Stdout, *outputPtr, algorithm, filePath, result)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const MANUAL_PLANNER = "manual" // Interactive, left out of bench runs over every planner
+const MANUAL_VIEW_WIDTH = 40    // Tiles shown around the agent, larger maps scroll
+const MANUAL_VIEW_HEIGHT = 20
+
+// 256-color backgrounds of tiles visited once, twice, ... and at least as often as there are colors
+var TERMINAL_HEAT_COLORS = []int{237, 239, 58, 94, 130, 166, 160, 196}
+
+func init() {
+	RegisterPlanner(NewPlannerFunc(MANUAL_PLANNER,
+		"Played by hand in the terminal with WASD or the arrow keys, reads u/d/l/r/v/c/w lines when not on a terminal",
+		FindAndTraverseManualPath))
+}
+
+// FindAndTraverseManualPath lets a person drive the agent. On a terminal the map is drawn with ANSI colors
+// on stderr and keys are read one by one (the terminal goes into raw mode with stty): WASD or the arrow keys
+// move, space or v vacuums, c charges, . waits and q ends the run. Without a terminal, or without stty, actions
+// are read one per line in the plan file format of the verify command (u, d, l, r, v, c, w or the full names,
+// '#' starts a comment) until q or the end of the input, so a recorded game replays the same way.
+// The run also ends when the map is clean or the agent cannot act any more.
+func FindAndTraverseManualPath(initialState InitialState, options RunOptions) (PlanResult, error) {
+	agent, err := CreateAgent(initialState, options)
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	agent.logs = append(agent.logs, fmt.Sprintf("Initial position: (%d, %d)", agent.posX, agent.posY))
+
+	if isTerminal(os.Stdin) {
+		if restore, err := rawTerminal(); err == nil {
+			playKeys(&agent, os.Stdin, os.Stderr)
+			restore()
+			return agent.result(), nil
+		}
+	}
+
+	playLines(&agent, os.Stdin, os.Stderr)
+	return agent.result(), nil
+}
+
+// Game with single key presses, the view is redrawn after every key
+func playKeys(agent *Agent, in io.Reader, out io.Writer) {
+	reader := bufio.NewReader(in)
+	most := agent.dirt.most
+	message := "WASD or arrows move, space vacuums, c charges, . waits, q quits"
+
+	for {
+		drawTerminalView(out, agent, most, nil, manualStatus(agent), message)
+		if agent.allTilesCleaned() || agent.outOfTime() {
+			fmt.Fprint(out, "Run over, press any key\r\n")
+			reader.ReadByte()
+			return
+		}
+
+		key, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		var action Action
+		switch key {
+		case 'w', 'W':
+			action = ActionUp
+		case 's', 'S':
+			action = ActionDown
+		case 'a', 'A':
+			action = ActionLeft
+		case 'd', 'D':
+			action = ActionRight
+		case ' ', 'v', 'V':
+			action = ActionVacuum
+		case 'c', 'C':
+			action = ActionCharge
+		case '.':
+			action = ActionWait
+		case 'q', 'Q', 3, 4: // Ctrl-C and Ctrl-D arrive as keys in raw mode
+			return
+		case 27: // arrow keys are ESC [ A to D
+			if next, _ := reader.ReadByte(); next != '[' {
+				continue
+			}
+			arrow, _ := reader.ReadByte()
+			action = map[byte]Action{'A': ActionUp, 'B': ActionDown, 'C': ActionRight, 'D': ActionLeft}[arrow]
+		}
+		if action == "" {
+			continue
+		}
+
+		message = manualAct(agent, action)
+	}
+}
+
+// Game read line by line, a short status goes to out after every action
+func playLines(agent *Agent, in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan() && !agent.allTilesCleaned() && !agent.outOfTime(); line++ {
+		text := strings.TrimSpace(strings.Split(scanner.Text(), "#")[0])
+		if text == "" {
+			continue
+		}
+		if text == "q" || text == "quit" {
+			return
+		}
+
+		action, err := ParseAction(text)
+		if err != nil {
+			fmt.Fprintf(out, "Line %d: %v\n", line, err)
+			continue
+		}
+		fmt.Fprintf(out, "%s | %s\n", manualAct(agent, action), manualStatus(agent))
+	}
+}
+
+// Performs the action and says what came of it, the simulator refuses illegal actions without a step
+func manualAct(agent *Agent, action Action) string {
+	before, logs := len(agent.steps), len(agent.logs)
+	agent.perform(action)
+	if len(agent.steps) == before {
+		return fmt.Sprintf("Cannot %s here", action)
+	}
+	return strings.Join(agent.logs[logs:], ", ")
+}
+
+func manualStatus(agent *Agent) string {
+	status := fmt.Sprintf("Battery %d/%d, dirt cleaned %d, %d left, %d tiles moved",
+		agent.battery, agent.capacity, agent.dirtCleaned, agent.dirt.total, agent.tilesMoved)
+	if agent.env != nil && agent.env.horizon > 0 {
+		status += fmt.Sprintf(", turn %d of %d", agent.env.turn, agent.env.horizon)
+	}
+	return status
+}
+
+// Draws the part of the map around the agent with a status and a message below. Dirt shows as 1 to 9 relative
+// to the most dirt on a tile at the start, yellow to red, carpets are blue, docks green and tiles the sensor has
+// not seen yet are blank. With visits, tiles the agent has been on get a background from dark to red.
+func drawTerminalView(out io.Writer, agent *Agent, most int, visits [][]int, status string, message string) {
+	left := clampView(agent.posX-MANUAL_VIEW_WIDTH/2, agent.gridWidth()-MANUAL_VIEW_WIDTH)
+	top := clampView(agent.posY-MANUAL_VIEW_HEIGHT/2, len(agent.tiles)-MANUAL_VIEW_HEIGHT)
+
+	view := strings.Builder{}
+	view.WriteString("\x1b[H\x1b[2J")
+	for y := top; y < top+MANUAL_VIEW_HEIGHT && y < len(agent.tiles); y++ {
+		for x := left; x < left+MANUAL_VIEW_WIDTH && x < len(agent.tiles[y]); x++ {
+			view.WriteString(terminalTile(agent, x, y, most, visits))
+		}
+		view.WriteString("\x1b[0m\r\n")
+	}
+	fmt.Fprintf(&view, "\r\n%s\r\n%s\r\n", status, message)
+	fmt.Fprint(out, view.String())
+}
+
+// First row or column of the view, within 0 and last
+func clampView(first int, last int) int {
+	if first > last {
+		first = last
+	}
+	if first < 0 {
+		first = 0
+	}
+	return first
+}
+
+// Two characters with ANSI colors for a tile
+func terminalTile(agent *Agent, x int, y int, most int, visits [][]int) string {
+	background := "\x1b[0m"
+	if moveCost := agent.moveCostTo(x, y); moveCost > agent.movementCost {
+		background = "\x1b[0;44m"
+	}
+	if visits != nil && visits[y][x] > 0 {
+		heat := visits[y][x]
+		if heat > len(TERMINAL_HEAT_COLORS) {
+			heat = len(TERMINAL_HEAT_COLORS)
+		}
+		background = fmt.Sprintf("\x1b[0;48;5;%dm", TERMINAL_HEAT_COLORS[heat-1])
+	}
+
+	tile := agent.tiles[y][x]
+	switch {
+	case x == agent.posX && y == agent.posY:
+		return background + "\x1b[1;96m@@"
+	case !agent.knows(x, y):
+		return "\x1b[0m  "
+	case tile == WALL_VALUE:
+		return "\x1b[0;47m  "
+	case tile == DOCK_VALUE:
+		return background + "\x1b[1;32m++"
+	case dirtOf(tile) == 0:
+		return background + "\x1b[90m. "
+	}
+
+	level := tile
+	if most > 9 {
+		level = (tile*9 + most - 1) / most
+	}
+	if level > 9 {
+		level = 9
+	}
+	color := "\x1b[33m"
+	if level > 6 {
+		color = "\x1b[1;31m"
+	} else if level > 3 {
+		color = "\x1b[91m"
+	}
+	return fmt.Sprintf("%s%s%d ", background, color, level)
+}
+
+// Whether f is a terminal rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Puts the terminal into raw mode and returns how to restore it, fails where there is no stty
+func rawTerminal() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(saved)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPlayLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		x, y    int
+		dirt    int
+		steps   int
+		replies []string // part of every line written back, in order
+	}{
+		{"short and full names", "r\nv\ndown\nvacuum\n", 1, 1, 9, 4,
+			[]string{"Moved to (1, 0)", "Vacuumed", "Moved to (1, 1)", "Vacuumed"}},
+		{"comments and blank lines", "# a game\n\nr # to the dirt\nv\n", 1, 0, 4, 2,
+			[]string{"Moved to (1, 0)", "Vacuumed"}},
+		{"wall refused", "d\nd\nr\n", 0, 2, 0, 2, []string{"Moved to (0, 1)", "Moved to (0, 2)", "Cannot right here"}},
+		{"unknown action", "jump\nr\n", 1, 0, 0, 1, []string{"Line 1:", "Moved to (1, 0)"}},
+		{"quit", "r\nq\nv\n", 1, 0, 0, 1, []string{"Moved to (1, 0)"}},
+		{"stops once clean", "r\nv\nd\nv\nl\n", 1, 1, 9, 4,
+			[]string{"Moved to (1, 0)", "Vacuumed", "Moved to (1, 1)", "Vacuumed"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			agent, err := CreateAgent(testState(20, 1, 1, "0,4", "0,5", "0,9001"), RunOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := bytes.Buffer{}
+			playLines(&agent, strings.NewReader(test.input), &out)
+
+			if agent.posX != test.x || agent.posY != test.y || agent.dirtCleaned != test.dirt || len(agent.steps) != test.steps {
+				t.Errorf("at (%d, %d) with %d dirt in %d steps, want (%d, %d) with %d in %d",
+					agent.posX, agent.posY, agent.dirtCleaned, len(agent.steps), test.x, test.y, test.dirt, test.steps)
+			}
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != len(test.replies) {
+				t.Fatalf("replies %q, want %d", lines, len(test.replies))
+			}
+			for i, reply := range test.replies {
+				if !strings.Contains(lines[i], reply) {
+					t.Errorf("reply %q, want one with %q", lines[i], reply)
+				}
+			}
+		})
+	}
+}
+
+func TestPlayKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  string
+		x, y  int
+		dirt  int
+		steps int
+	}{
+		{"wasd", "dsa q", 0, 1, 0, 3},
+		{"arrows", "\x1b[C\x1b[B \x1b[Dq", 0, 1, 5, 4},
+		{"capitals and vacuum", "DVq", 1, 0, 4, 2},
+		{"unknown keys ignored", "xz\x1b]d.q", 1, 0, 0, 2},
+		{"ctrl-c", "d\x03d", 1, 0, 0, 1},
+		{"end of input", "dv", 1, 0, 4, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			agent, err := CreateAgent(testState(20, 1, 1, "0,4", "0,5"), RunOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := bytes.Buffer{}
+			playKeys(&agent, strings.NewReader(test.keys), &out)
+
+			if agent.posX != test.x || agent.posY != test.y || agent.dirtCleaned != test.dirt || len(agent.steps) != test.steps {
+				t.Errorf("at (%d, %d) with %d dirt in %d steps, want (%d, %d) with %d in %d",
+					agent.posX, agent.posY, agent.dirtCleaned, len(agent.steps), test.x, test.y, test.dirt, test.steps)
+			}
+			if !strings.Contains(out.String(), "Battery") {
+				t.Error("no status drawn")
+			}
+		})
+	}
+}
+
+func TestTerminalTile(t *testing.T) {
+	state := testState(20, 1, 1, "0,4,9001,9002", "90,0,50,0")
+	state.Terrain = [][]string{{"1", "1", "1", "1"}, {"1", "3", "1", "1"}}
+	agent, err := CreateAgent(state, RunOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	visits := [][]int{{1, 0, 0, 0}, {0, 20, 2, 0}}
+
+	tests := []struct {
+		name   string
+		x, y   int
+		visits [][]int
+		want   string
+	}{
+		{"agent", 0, 0, nil, "\x1b[0m\x1b[1;96m@@"},
+		{"dirt relative to the most", 1, 0, nil, "\x1b[0m\x1b[33m1 "},
+		{"most dirt", 0, 1, nil, "\x1b[0m\x1b[1;31m9 "},
+		{"middle dirt", 2, 1, nil, "\x1b[0m\x1b[91m5 "},
+		{"wall", 2, 0, nil, "\x1b[0;47m  "},
+		{"dock", 3, 0, nil, "\x1b[0m\x1b[1;32m++"},
+		{"carpet", 1, 1, nil, "\x1b[0;44m\x1b[90m. "},
+		{"heat on a carpet", 1, 1, visits, "\x1b[0;48;5;196m\x1b[90m. "},
+		{"heat", 2, 1, visits, "\x1b[0;48;5;239m\x1b[91m5 "},
+		{"no heat without visits", 3, 1, visits, "\x1b[0m\x1b[90m. "},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if tile := terminalTile(&agent, test.x, test.y, 90, test.visits); tile != test.want {
+				t.Errorf("drawn as %q, want %q", tile, test.want)
+			}
+		})
+	}
+}
+
+func TestClampView(t *testing.T) {
+	tests := []struct{ first, last, want int }{
+		{5, 10, 5},
+		{-3, 10, 0},
+		{12, 10, 10},
+		{4, -6, 0}, // map smaller than the view
+	}
+	for _, test := range tests {
+		if got := clampView(test.first, test.last); got != test.want {
+			t.Errorf("clampView(%d, %d) = %d, want %d", test.first, test.last, got, test.want)
+		}
+	}
+}
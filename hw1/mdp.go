@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const MDP_MAX_STATES = 5_000_000 // (position, battery, cleaned set) states value iteration takes on
+const MDP_MAX_DIRTY_TILES = 20   // Cleaned sets are bit masks over the reachable dirty tiles
+const MDP_MAX_SWEEPS = 10_000
+const MDP_TOLERANCE = 1e-9
+
+// Move actions of the MDP as [dx, dy]: up, down, left, right
+var MDP_MOVES = [][2]int{{0, -1}, {0, 1}, {-1, 0}, {1, 0}}
+
+const (
+	MDP_STOP   = -1
+	MDP_VACUUM = 4
+)
+
+func init() {
+	RegisterPlanner(NewPlannerFunc("mdp",
+		"Value iteration over (position, battery, cleaned tiles) on a slippery floor, replans with expected costs on larger maps",
+		FindAndTraverseMDPPath))
+}
+
+// Markov decision process of the agent on a slippery floor. A state is the position, the battery and the
+// set of dirty tiles cleaned so far, the reward is the dirt vacuumed.
+type mdpModel struct {
+	agent  *Agent
+	width  int
+	height int
+	levels int         // battery levels, 0 to the capacity
+	dirty  []dirtyTile // reachable dirty tiles, bit i of a cleaned set is dirty[i]
+	bits   [][]int     // index into dirty per tile, -1 for none
+	values []float64   // best expected dirt still to clean from every state
+	policy []int8      // best action per state, one of MDP_MOVES indexes, MDP_VACUUM or MDP_STOP
+	sweeps int
+}
+
+func newMDPModel(agent *Agent) (*mdpModel, error) {
+	model := mdpModel{agent: agent, height: len(agent.tiles), levels: agent.capacity + 1, dirty: reachableDirtyTiles(agent)}
+	for _, row := range agent.tiles {
+		if len(row) > model.width {
+			model.width = len(row)
+		}
+	}
+
+	if len(model.dirty) > MDP_MAX_DIRTY_TILES {
+		return nil, fmt.Errorf("%d reachable dirty tiles, at most %d supported", len(model.dirty), MDP_MAX_DIRTY_TILES)
+	}
+	states := float64(int(1)<<len(model.dirty)) * float64(model.width*model.height) * float64(model.levels)
+	if states > MDP_MAX_STATES {
+		return nil, fmt.Errorf("%.0f states, at most %d supported", states, MDP_MAX_STATES)
+	}
+
+	model.bits = make([][]int, model.height)
+	for y := range model.bits {
+		model.bits[y] = make([]int, model.width)
+		for x := range model.bits[y] {
+			model.bits[y][x] = -1
+		}
+	}
+	for i, tile := range model.dirty {
+		model.bits[tile.y][tile.x] = i
+	}
+
+	model.values = make([]float64, int(states))
+	model.policy = make([]int8, int(states))
+	return &model, nil
+}
+
+func (model *mdpModel) index(cleaned int, x int, y int, battery int) int {
+	return ((cleaned*model.height+y)*model.width+x)*model.levels + battery
+}
+
+// Whether the agent can stand on (x, y)
+func (model *mdpModel) walkable(x int, y int) bool {
+	return y >= 0 && y < len(model.agent.tiles) && x >= 0 && x < len(model.agent.tiles[y]) && model.agent.tiles[y][x] != WALL_VALUE
+}
+
+// Expected dirt of taking the action in the state and acting best afterwards, false when the action is not possible
+func (model *mdpModel) actionValue(action int, cleaned int, x int, y int, battery int) (float64, bool) {
+	agent := model.agent
+	switch action {
+	case MDP_VACUUM:
+		bit := model.bits[y][x]
+		cost := agent.vacuumCostAt(x, y)
+		if bit < 0 || cleaned&(1<<bit) != 0 || battery < cost {
+			return 0, false
+		}
+		return float64(model.dirty[bit].dirt) + model.values[model.index(cleaned|1<<bit, x, y, battery-cost)], true
+	}
+
+	move := MDP_MOVES[action]
+	if !model.walkable(x+move[0], y+move[1]) {
+		return 0, false
+	}
+	cost := agent.moveCostTo(x+move[0], y+move[1])
+	if battery < cost {
+		return 0, false
+	}
+
+	value := 0.0
+	for i, outcome := range moveOutcomes(move[0], move[1]) {
+		chance := agent.moveSuccess
+		if i > 0 {
+			chance = (1 - agent.moveSuccess) / 3
+		}
+		if chance == 0 {
+			continue
+		}
+		nx, ny := x+outcome[0], y+outcome[1]
+		if !model.walkable(nx, ny) {
+			nx, ny = x, y
+		}
+		value += chance * model.values[model.index(cleaned, nx, ny, battery-cost)]
+	}
+	return value, true
+}
+
+// Value iteration until no value changes by more than MDP_TOLERANCE. Larger cleaned sets and lower batteries
+// go first, so when every action costs battery a single sweep already settles all values (and one more confirms it).
+func (model *mdpModel) solve() {
+	for model.sweeps < MDP_MAX_SWEEPS && !model.agent.pastDeadline() {
+		model.sweeps++
+		change := 0.0
+		for cleaned := 1<<len(model.dirty) - 1; cleaned >= 0; cleaned-- {
+			for battery := 0; battery < model.levels; battery++ {
+				for y := 0; y < model.height; y++ {
+					for x := 0; x < model.width; x++ {
+						if !model.walkable(x, y) {
+							continue
+						}
+						best, bestAction := 0.0, MDP_STOP
+						for action := 0; action <= MDP_VACUUM; action++ {
+							if value, ok := model.actionValue(action, cleaned, x, y, battery); ok && value > best+MDP_TOLERANCE {
+								best, bestAction = value, action
+							}
+						}
+
+						i := model.index(cleaned, x, y, battery)
+						change = math.Max(change, math.Abs(best-model.values[i]))
+						model.values[i], model.policy[i] = best, int8(bestAction)
+					}
+				}
+			}
+		}
+
+		if change <= MDP_TOLERANCE {
+			return
+		}
+	}
+}
+
+// Cleaned set of the agent, the dirty tiles of the model that are clean by now
+func (model *mdpModel) cleaned() int {
+	cleaned := 0
+	for i, tile := range model.dirty {
+		if model.agent.tiles[tile.y][tile.x] == 0 {
+			cleaned |= 1 << i
+		}
+	}
+	return cleaned
+}
+
+// FindAndTraverseMDPPath plans on a slippery floor, where a move only goes as meant with the move success
+// chance and otherwise slides sideways or stays put. On small maps it solves the Markov decision process over
+// (position, battery, cleaned tiles) with value iteration and follows the best action of the state it ends up
+// in, which maximizes the expected dirt cleaned. Larger maps fall back to replanning every step towards the
+// dirty tile with the most dirt per expected battery, travel costs being divided by the move success chance.
+func FindAndTraverseMDPPath(initialState InitialState, options RunOptions) (PlanResult, error) {
+	agent, err := CreateAgent(initialState, options)
+	if err != nil {
+		return PlanResult{}, err
+	}
+	if err := agent.rejectDocks("mdp"); err != nil {
+		return PlanResult{}, err
+	}
+	if agent.env != nil {
+		return PlanResult{}, errors.New("The mdp algorithm does not support dirt that comes back")
+	}
+
+	agent.logs = append(agent.logs, fmt.Sprintf("Initial position: (%d, %d)", agent.posX, agent.posY))
+
+	model, err := newMDPModel(&agent)
+	if err != nil {
+		agent.logs = append(agent.logs, fmt.Sprintf("Too big for value iteration (%v), replanning with expected costs", err))
+		traverseExpectedCosts(&agent)
+		return agent.result(), nil
+	}
+
+	model.solve()
+	start := model.index(0, agent.posX, agent.posY, agent.battery)
+	agent.logs = append(agent.logs, fmt.Sprintf("Value iteration over %d states took %d sweeps, expected dirt cleaned: %.2f",
+		len(model.values), model.sweeps, model.values[start]))
+
+	// a policy with ties may circle without cleaning, no useful run is longer than there are states
+	for steps := 0; steps < len(model.values) && !agent.outOfTime(); steps++ {
+		action := int(model.policy[model.index(model.cleaned(), agent.posX, agent.posY, agent.battery)])
+		switch action {
+		case MDP_STOP:
+			return agent.result(), nil
+		case MDP_VACUUM:
+			agent.vacuumIfDirty()
+		default:
+			move := MDP_MOVES[action]
+			directionArrayToAction([2]int{move[1], move[0]})(&agent)
+		}
+	}
+
+	return agent.result(), nil
+}
+
+// Closed-loop replanning for large slippery maps: every step heads for the dirty tile with the most dirt
+// per expected battery and stops when nothing is affordable.
+func traverseExpectedCosts(agent *Agent) {
+	for {
+		route := dijkstraWithin(agent, [][2]int{{agent.posX, agent.posY}}, false, agent.battery, nil)
+
+		target, targetDirt, targetCost := [2]int{-1, -1}, 0, 0
+		for _, i := range route.order {
+			x, y := route.position(i)
+			dirt := dirtOf(agent.tiles[y][x])
+			expected := int(math.Ceil(float64(route.cost[i])/agent.moveSuccess)) + agent.vacuumCostAt(x, y)
+			if dirt == 0 || route.cost[i]+agent.vacuumCostAt(x, y) > agent.battery {
+				continue
+			}
+			if target[0] < 0 || betterValue(dirt, expected, targetDirt, targetCost) {
+				target, targetDirt, targetCost = [2]int{x, y}, dirt, expected
+			}
+		}
+
+		if target[0] < 0 {
+			agent.logs = append(agent.logs, "Nothing left to clean within reach")
+			return
+		}
+
+		if target[0] == agent.posX && target[1] == agent.posY {
+			if agent.vacuumIfDirty() == 0 {
+				return
+			}
+			continue
+		}
+
+		before := len(agent.steps)
+		directionArrayToAction(firstMove(route, target[0], target[1]))(agent)
+		if len(agent.steps) == before {
+			return // not enough battery to move
+		}
+	}
+}
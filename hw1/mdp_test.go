@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMDPRejectsDocks(t *testing.T) {
+	endOnDock := testState(5, 1, 1, "0,9002,4")
+	endOnDock.MustEndOnDock = true
+	tests := []struct {
+		name  string
+		state InitialState
+	}{
+		{"dock", testState(5, 1, 1, "0,9002,4")},
+		{"end on dock", endOnDock},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := FindAndTraverseMDPPath(test.state, RunOptions{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
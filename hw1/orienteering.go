@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func init() {
+	RegisterPlanner(NewPlannerFunc("orienteering",
+		"Greedy insertion tour over a dirty tile distance matrix, improved with 2-opt/or-opt",
+		FindAndTraverseOrienteeringPath))
+}
+
+const ORIENTEERING_MAX_SEGMENT = 3 // Longest run of tiles or-opt moves at once
+
+// Prize-collecting orienteering over reachable dirty tiles.
+// Distances are computed once, the tour starts at the agent position and does not need to return
+// unless returnCost is set (battery needed to reach a dock from every tile, the start included).
+type orienteering struct {
+	tiles      []dirtyTile
+	dist       [][]int // battery costs of moving between tiles, the start is the last row/column
+	start      int
+	battery    int
+	returnCost []int
+	deadline   time.Time    // local search stops early once it passed, the zero time for no limit
+	banned     map[int]bool // tiles greedy insertion leaves out, nil for none
+}
+
+func (problem *orienteering) expired() bool {
+	return !problem.deadline.IsZero() && time.Now().After(problem.deadline)
+}
+
+// Battery used by visiting the tiles of the tour in order and vacuuming each of them
+func (problem *orienteering) tourCost(tour []int) int {
+	cost := 0
+	prev := problem.start
+	for _, tile := range tour {
+		cost += problem.dist[prev][tile] + problem.tiles[tile].vacuumCost
+		prev = tile
+	}
+	if problem.returnCost != nil {
+		cost += problem.returnCost[prev]
+	}
+	return cost
+}
+
+// Adds unvisited tiles one by one, always the one with the best dirt per extra battery
+// over all insertion positions, until nothing else fits into the battery.
+func (problem *orienteering) greedyInsertion(tour []int) []int {
+	visited := make([]bool, len(problem.tiles))
+	for _, tile := range tour {
+		visited[tile] = true
+	}
+	for tile := range problem.banned {
+		visited[tile] = true
+	}
+
+	cost := problem.tourCost(tour)
+	for !problem.expired() {
+		bestTile, bestPos, bestExtra := -1, -1, 0
+		for tile := range problem.tiles {
+			if visited[tile] {
+				continue
+			}
+
+			for pos := 0; pos <= len(tour); pos++ {
+				extra := problem.insertionCost(tour, tile, pos)
+				if cost+extra > problem.battery {
+					continue
+				}
+
+				// compare dirt/extra ratios without dividing, prefer cheaper and then dirtier insertions on ties
+				dirt, bestDirt := problem.tiles[tile].dirt, 0
+				if bestTile != -1 {
+					bestDirt = problem.tiles[bestTile].dirt
+				}
+				if bestTile == -1 || dirt*bestExtra > bestDirt*extra ||
+					(dirt*bestExtra == bestDirt*extra && (extra < bestExtra || (extra == bestExtra && dirt > bestDirt))) {
+					bestTile, bestPos, bestExtra = tile, pos, extra
+				}
+			}
+		}
+
+		if bestTile == -1 {
+			return tour
+		}
+
+		tour = append(tour[:bestPos], append([]int{bestTile}, tour[bestPos:]...)...)
+		visited[bestTile] = true
+		cost += bestExtra
+	}
+	return tour
+}
+
+// Extra battery needed to visit tile between positions pos-1 and pos of the tour
+func (problem *orienteering) insertionCost(tour []int, tile int, pos int) int {
+	prev := problem.start
+	if pos > 0 {
+		prev = tour[pos-1]
+	}
+
+	extra := problem.dist[prev][tile] + problem.tiles[tile].vacuumCost
+	if pos < len(tour) {
+		next := tour[pos]
+		extra += problem.dist[tile][next] - problem.dist[prev][next]
+	} else if problem.returnCost != nil {
+		extra += problem.returnCost[tile] - problem.returnCost[prev]
+	}
+	return extra
+}
+
+// Battery saved by leaving out the tile at position i of the tour
+func (problem *orienteering) removalSaving(tour []int, i int) int {
+	prev, tile := problem.start, tour[i]
+	if i > 0 {
+		prev = tour[i-1]
+	}
+
+	saving := problem.dist[prev][tile] + problem.tiles[tile].vacuumCost
+	if i+1 < len(tour) {
+		next := tour[i+1]
+		saving += problem.dist[tile][next] - problem.dist[prev][next]
+	} else if problem.returnCost != nil {
+		saving += problem.returnCost[tile] - problem.returnCost[prev]
+	}
+	return saving
+}
+
+// Shortens the tour with 2-opt (segment reversal) and or-opt (segment relocation) moves.
+// Returns true when the tour got cheaper.
+func (problem *orienteering) improve(tour []int) bool {
+	improved := false
+	cost := problem.tourCost(tour)
+
+	for changed := true; changed && !problem.expired(); {
+		changed = false
+
+		// 2-opt: reverse tour[i..j]
+		for i := 0; i < len(tour)-1 && !changed; i++ {
+			for j := i + 1; j < len(tour) && !changed; j++ {
+				reverseTour(tour, i, j)
+				if newCost := problem.tourCost(tour); newCost < cost {
+					cost, changed = newCost, true
+				} else {
+					reverseTour(tour, i, j)
+				}
+			}
+		}
+
+		// or-opt: move tour[i..i+length) to another position
+		for length := 1; length <= ORIENTEERING_MAX_SEGMENT && !changed; length++ {
+			for i := 0; i+length <= len(tour) && !changed; i++ {
+				segment := append([]int{}, tour[i:i+length]...)
+				rest := append(append([]int{}, tour[:i]...), tour[i+length:]...)
+
+				for pos := 0; pos <= len(rest) && !changed; pos++ {
+					if pos == i {
+						continue
+					}
+
+					candidate := append(append(append([]int{}, rest[:pos]...), segment...), rest[pos:]...)
+					if newCost := problem.tourCost(candidate); newCost < cost {
+						copy(tour, candidate)
+						cost, changed = newCost, true
+					}
+				}
+			}
+		}
+
+		improved = improved || changed
+	}
+
+	return improved
+}
+
+// Replaces a visited tile with a dirtier unvisited one when the battery allows it.
+// Returns the new tour and true when the dirt cleaned grew.
+func (problem *orienteering) swapForDirtier(tour []int) ([]int, bool) {
+	visited := make([]bool, len(problem.tiles))
+	for _, tile := range tour {
+		visited[tile] = true
+	}
+
+	for i, old := range tour {
+		for tile := range problem.tiles {
+			if visited[tile] || problem.tiles[tile].dirt <= problem.tiles[old].dirt {
+				continue
+			}
+
+			rest := append(append([]int{}, tour[:i]...), tour[i+1:]...)
+			restCost := problem.tourCost(rest)
+			for pos := 0; pos <= len(rest); pos++ {
+				if restCost+problem.insertionCost(rest, tile, pos) <= problem.battery {
+					return append(rest[:pos], append([]int{tile}, rest[pos:]...)...), true
+				}
+			}
+		}
+	}
+
+	return tour, false
+}
+
+// Inserts an unvisited tile at its cheapest position and, while the tour is over the battery, drops the
+// tile saving the most battery per dirt. Gets out of tours full of cheap tiles that leave no room for a
+// far away dirty one. Returns the new tour and true when the dirt cleaned grew.
+func (problem *orienteering) insertAndRepair(tour []int) ([]int, bool) {
+	visited := make([]bool, len(problem.tiles))
+	for _, tile := range tour {
+		visited[tile] = true
+	}
+	dirt := problem.tourDirt(tour)
+
+	for tile := range problem.tiles {
+		if visited[tile] || problem.tiles[tile].dirt == 0 {
+			continue
+		}
+
+		bestPos, bestExtra := 0, -1
+		for pos := 0; pos <= len(tour); pos++ {
+			if extra := problem.insertionCost(tour, tile, pos); bestExtra < 0 || extra < bestExtra {
+				bestPos, bestExtra = pos, extra
+			}
+		}
+		candidate := append(append(append([]int{}, tour[:bestPos]...), tile), tour[bestPos:]...)
+
+		for cost := problem.tourCost(candidate); cost > problem.battery; {
+			drop, dropSaving := -1, 0
+			for i, other := range candidate {
+				if other == tile {
+					continue
+				}
+				// compare saved battery per dirt without dividing
+				saving := problem.removalSaving(candidate, i)
+				if drop < 0 || saving*problem.tiles[candidate[drop]].dirt > dropSaving*problem.tiles[other].dirt {
+					drop, dropSaving = i, saving
+				}
+			}
+			if drop < 0 {
+				candidate = nil // the tile alone is over the battery
+				break
+			}
+			candidate = append(candidate[:drop], candidate[drop+1:]...)
+			cost -= dropSaving
+		}
+
+		if candidate != nil && problem.tourDirt(candidate) > dirt {
+			return candidate, true
+		}
+	}
+
+	return tour, false
+}
+
+// Greedy insertion followed by local search, repeated while the tour keeps getting better
+func (problem *orienteering) solve() []int {
+	return problem.search(problem.greedyInsertion([]int{}))
+}
+
+// Local search from a feasible tour until no move helps (or the deadline passed)
+func (problem *orienteering) search(tour []int) []int {
+	for !problem.expired() {
+		improved := problem.improve(tour)
+		if improved {
+			// shorter tour may leave room for more tiles
+			tour = problem.greedyInsertion(tour)
+		}
+
+		swapped := false
+		tour, swapped = problem.swapForDirtier(tour)
+		if swapped {
+			tour = problem.greedyInsertion(tour)
+		}
+
+		repaired := false
+		if !improved && !swapped {
+			tour, repaired = problem.insertAndRepair(tour)
+		}
+		if repaired {
+			tour = problem.greedyInsertion(tour)
+		}
+
+		if !improved && !swapped && !repaired {
+			return tour
+		}
+	}
+	return tour
+}
+
+func reverseTour(tour []int, i int, j int) {
+	for ; i < j; i, j = i+1, j-1 {
+		tour[i], tour[j] = tour[j], tour[i]
+	}
+}
+
+// Plans one tour from the agent position and drives the agent along it.
+// With toDock set the tour keeps enough battery to reach a dock afterwards (and goes there).
+// Returns the dirt cleaned on the way.
+func traverseOrienteeringTour(agent *Agent, toDock *travelCosts) int {
+	tiles := reachableDirtyTiles(agent)
+	nodes := append(append([]dirtyTile{}, tiles...), dirtyTile{x: agent.posX, y: agent.posY})
+	dist, ok := tileTravelCostsUntil(agent, nodes, agent.deadline)
+	if !ok {
+		return 0
+	}
+	problem := orienteering{
+		tiles:    tiles,
+		dist:     dist,
+		start:    len(tiles),
+		battery:  agent.battery,
+		deadline: agent.deadline,
+	}
+
+	if toDock != nil {
+		problem.returnCost = make([]int, len(nodes))
+		for i, node := range nodes {
+			if toDock.costAt(node.x, node.y) < 0 {
+				problem.returnCost[i] = agent.capacity + 1 // no way back, never worth ending there
+			} else {
+				problem.returnCost[i] = toDock.costAt(node.x, node.y)
+			}
+		}
+		if problem.returnCost[problem.start] > agent.battery {
+			return 0
+		}
+	}
+
+	cleanedBefore := agent.dirtCleaned
+	for _, tile := range problem.solve() {
+		walkTo(agent, routeTo(agent, tiles[tile].x, tiles[tile].y))
+		agent.vacuumIfDirty()
+	}
+	if toDock != nil {
+		walkTo(agent, *toDock)
+	}
+
+	return agent.dirtCleaned - cleanedBefore
+}
+
+// FindAndTraverseOrienteeringPath treats the task as a prize-collecting orienteering problem:
+// cheapest paths between the start and every reachable dirty tile are computed once, a tour is built
+// by greedy insertion within the battery and then shortened with 2-opt/or-opt local search, tiles left out
+// pushing their way in when nothing else helps (see insertAndRepair).
+// The tour is then expanded back into single moves of the agent.
+// On maps with docks the agent makes dock-to-dock trips, charging in between, as long as a trip cleans anything.
+// The last tour may end anywhere unless the run has to end on a dock.
+func FindAndTraverseOrienteeringPath(initialState InitialState, options RunOptions) (PlanResult, error) {
+	agent, err := CreateAgent(initialState, options)
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	agent.logs = append(agent.logs, fmt.Sprintf("Initial position: (%d, %d)", agent.posX, agent.posY))
+
+	docks := agent.docks()
+	if len(docks) > 0 {
+		toDock := dijkstra(&agent, docks, true)
+		for {
+			for agent.onDock() && agent.charge() > 0 {
+			}
+
+			if traverseOrienteeringTour(&agent, &toDock) == 0 {
+				break
+			}
+		}
+
+		if agent.mustEndOnDock {
+			walkTo(&agent, toDock)
+			return agent.result(), nil
+		}
+	}
+
+	traverseOrienteeringTour(&agent, nil)
+
+	return agent.result(), nil
+}
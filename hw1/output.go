@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var OUTPUT_FORMATS = []string{"text", "json", "csv"}
+
+type jsonPosition struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type jsonStatistics struct {
+	DirtCleaned      int             `json:"dirtCleaned"`
+	TilesMoved       int             `json:"tilesMoved"`
+	BatteryRemaining int             `json:"batteryRemaining"`
+	Termination      string          `json:"termination,omitempty"`
+	EndedOnDock      *bool           `json:"endedOnDock,omitempty"`     // only when the run has to end on a dock
+	TilesDiscovered  *int            `json:"tilesDiscovered,omitempty"` // only with a sensor radius
+	TotalTiles       *int            `json:"totalTiles,omitempty"`
+	Turns            *int            `json:"turns,omitempty"` // only when dirt comes back or with a horizon
+	Horizon          *int            `json:"horizon,omitempty"`
+	DirtAppeared     *int            `json:"dirtAppeared,omitempty"`
+	AverageDirt      *float64        `json:"averageDirt,omitempty"`
+	Rollouts         *RolloutSummary `json:"rollouts,omitempty"` // only with --rollouts
+	UpperBound       *DirtBound      `json:"upperBound,omitempty"`
+	BoundRatio       *float64        `json:"boundRatio,omitempty"` // dirt cleaned over the upper bound
+	Progress         []ProgressPoint `json:"progress,omitempty"`   // only for anytime planners
+}
+
+type jsonTrajectory struct {
+	Algorithm  string         `json:"algorithm,omitempty"` // empty for robots of a fleet
+	Input      string         `json:"input,omitempty"`
+	Start      jsonPosition   `json:"start"`
+	Battery    int            `json:"battery"`
+	Steps      []Step         `json:"steps"`
+	Decisions  []Decision     `json:"decisions,omitempty"` // only with --explain
+	Statistics jsonStatistics `json:"statistics"`
+}
+
+func isOutputFormat(format string) bool {
+	for _, known := range OUTPUT_FORMATS {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes the run in one of OUTPUT_FORMATS
+func writeResult(w io.Writer, format string, algorithm string, input string, result PlanResult) error {
+	switch format {
+	case "text":
+		return writeText(w, result)
+	case "json":
+		return writeJSON(w, algorithm, input, result)
+	case "csv":
+		return writeCSV(w, result)
+	}
+
+	return fmt.Errorf("Unknown output format %s, expected one of %v", format, OUTPUT_FORMATS)
+}
+
+// Free-text logs (when PRINT_MOVES is set) followed by the statistics
+func writeText(w io.Writer, result PlanResult) error {
+	var err error
+	for i := 0; PRINT_MOVES && err == nil && i < len(result.Logs); i++ {
+		_, err = fmt.Fprintln(w, result.Logs[i])
+	}
+	if err == nil && len(result.Decisions) > 0 {
+		_, err = fmt.Fprintln(w, "Decisions:")
+		for i := 0; err == nil && i < len(result.Decisions); i++ {
+			_, err = fmt.Fprintf(w, "  %s\n", result.Decisions[i])
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return result.printStatistics(w)
+}
+
+func (result PlanResult) printStatistics(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "Dirt cleaned: %d\nTiles moved: %d\nBattery remaining: %d\n",
+		result.DirtCleaned, result.TilesMoved, result.BatteryLeft)
+	if err == nil && result.Termination != "" {
+		_, err = fmt.Fprintf(w, "Termination: %s\n", result.Termination)
+	}
+	if err == nil && result.MustEndOnDock {
+		_, err = fmt.Fprintf(w, "Ended on dock: %s\n", yesNo(result.EndedOnDock))
+	}
+	if err == nil && result.SensorRadius > 0 {
+		_, err = fmt.Fprintf(w, "Map discovered: %d of %d tiles (%.1f%%)\n", result.Discovered, result.TotalTiles, result.DiscoveredPercent())
+	}
+	if err == nil && result.Dynamic {
+		_, err = fmt.Fprintf(w, "Turns: %s\nDirt appeared: %d\nAverage dirt on the map: %.2f\n",
+			result.turnsOfHorizon(), result.DirtAppeared, result.AverageDirt)
+	}
+	if err == nil && result.Bound != nil {
+		_, err = fmt.Fprintf(w, "Upper bound: %d dirt (%s, %d reachable), %.1f%% of it cleaned\n",
+			result.Bound.Bound, result.Bound.Method, result.Bound.Reachable, 100*result.BoundRatio())
+	}
+	if err == nil && result.Rollouts != nil {
+		_, err = fmt.Fprintf(w, "Expected dirt cleaned: %.2f (std. dev. %.2f, min %d, max %d over %d runs from seed %d)\n",
+			result.Rollouts.Mean, result.Rollouts.StdDev, result.Rollouts.Min, result.Rollouts.Max, result.Rollouts.Runs, result.Rollouts.Seed)
+	}
+	if err == nil && len(result.Progress) > 0 {
+		_, err = fmt.Fprintln(w, "Score over time:")
+		for i := 0; err == nil && i < len(result.Progress); i++ {
+			point := result.Progress[i]
+			_, err = fmt.Fprintf(w, "  %6d ms: %d dirt (%s)\n", point.Millis, point.DirtCleaned, point.Found)
+		}
+	}
+	return err
+}
+
+// Share of the map seen with the sensor, in percent
+func (result PlanResult) DiscoveredPercent() float64 {
+	if result.TotalTiles == 0 {
+		return 100
+	}
+	return 100 * float64(result.Discovered) / float64(result.TotalTiles)
+}
+
+// Turns taken, out of the horizon when there is one
+func (result PlanResult) turnsOfHorizon() string {
+	if result.Horizon > 0 {
+		return fmt.Sprintf("%d of %d", result.Turns, result.Horizon)
+	}
+	return strconv.Itoa(result.Turns)
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+func writeJSON(w io.Writer, algorithm string, input string, result PlanResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(newJSONTrajectory(algorithm, input, result))
+}
+
+func newJSONTrajectory(algorithm string, input string, result PlanResult) jsonTrajectory {
+	steps := result.Steps
+	if steps == nil {
+		steps = []Step{}
+	}
+
+	var endedOnDock *bool
+	if result.MustEndOnDock {
+		endedOnDock = &result.EndedOnDock
+	}
+	var discovered, totalTiles *int
+	if result.SensorRadius > 0 {
+		discovered, totalTiles = &result.Discovered, &result.TotalTiles
+	}
+	var turns, horizon, dirtAppeared *int
+	var averageDirt *float64
+	if result.Dynamic {
+		turns, dirtAppeared, averageDirt = &result.Turns, &result.DirtAppeared, &result.AverageDirt
+		if result.Horizon > 0 {
+			horizon = &result.Horizon
+		}
+	}
+
+	var boundRatio *float64
+	if result.Bound != nil {
+		ratio := result.BoundRatio()
+		boundRatio = &ratio
+	}
+
+	return jsonTrajectory{
+		Algorithm: algorithm,
+		Input:     input,
+		Start:     jsonPosition{X: result.StartX, Y: result.StartY},
+		Battery:   result.Battery,
+		Steps:     steps,
+		Decisions: result.Decisions,
+		Statistics: jsonStatistics{
+			DirtCleaned:      result.DirtCleaned,
+			TilesMoved:       result.TilesMoved,
+			BatteryRemaining: result.BatteryLeft,
+			Termination:      result.Termination,
+			EndedOnDock:      endedOnDock,
+			TilesDiscovered:  discovered,
+			TotalTiles:       totalTiles,
+			Turns:            turns,
+			Horizon:          horizon,
+			DirtAppeared:     dirtAppeared,
+			AverageDirt:      averageDirt,
+			Rollouts:         result.Rollouts,
+			UpperBound:       result.Bound,
+			BoundRatio:       boundRatio,
+			Progress:         result.Progress,
+		},
+	}
+}
+
+// One row per step, row 0 is the start position. Statistics follow as '#' comment lines,
+// the same comment style the input files use.
+func writeCSV(w io.Writer, result PlanResult) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"step", "action", "x", "y", "battery_before", "battery_after", "dirt_vacuumed"})
+	writer.Write([]string{"0", "start", strconv.Itoa(result.StartX), strconv.Itoa(result.StartY),
+		strconv.Itoa(result.Battery), strconv.Itoa(result.Battery), "0"})
+
+	for i, step := range result.Steps {
+		writer.Write([]string{strconv.Itoa(i + 1), string(step.Action), strconv.Itoa(step.X), strconv.Itoa(step.Y),
+			strconv.Itoa(step.BatteryBefore), strconv.Itoa(step.BatteryAfter), strconv.Itoa(step.DirtVacuumed)})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "# Dirt cleaned: %d\n# Tiles moved: %d\n# Battery remaining: %d\n",
+		result.DirtCleaned, result.TilesMoved, result.BatteryLeft)
+	if err == nil && result.Termination != "" {
+		_, err = fmt.Fprintf(w, "# Termination: %s\n", result.Termination)
+	}
+	if err == nil && result.MustEndOnDock {
+		_, err = fmt.Fprintf(w, "# Ended on dock: %s\n", yesNo(result.EndedOnDock))
+	}
+	if err == nil && result.SensorRadius > 0 {
+		_, err = fmt.Fprintf(w, "# Map discovered: %d of %d tiles\n", result.Discovered, result.TotalTiles)
+	}
+	if err == nil && result.Dynamic {
+		_, err = fmt.Fprintf(w, "# Turns: %s\n# Dirt appeared: %d\n# Average dirt on the map: %.2f\n",
+			result.turnsOfHorizon(), result.DirtAppeared, result.AverageDirt)
+	}
+	if err == nil && result.Bound != nil {
+		_, err = fmt.Fprintf(w, "# Upper bound: %d (%s, %d reachable)\n# Bound ratio: %.4f\n",
+			result.Bound.Bound, result.Bound.Method, result.Bound.Reachable, result.BoundRatio())
+	}
+	if err == nil && result.Rollouts != nil {
+		_, err = fmt.Fprintf(w, "# Expected dirt cleaned: %.2f over %d runs (std. dev. %.2f, min %d, max %d)\n",
+			result.Rollouts.Mean, result.Rollouts.Runs, result.Rollouts.StdDev, result.Rollouts.Min, result.Rollouts.Max)
+	}
+	for _, point := range result.Progress {
+		if err == nil {
+			_, err = fmt.Fprintf(w, "# Score at %d ms: %d (%s)\n", point.Millis, point.DirtCleaned, point.Found)
+		}
+	}
+	for _, decision := range result.Decisions {
+		if err == nil {
+			_, err = fmt.Fprintf(w, "# Decision: %s\n", decision)
+		}
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// Runs the planner and adds the bound like main does
+func testResult(t *testing.T, algorithm string, state InitialState, options RunOptions) PlanResult {
+	planner, err := GetPlanner(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := planner.Plan(state, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Bound, err = ComputeDirtBound(state); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+var outputTests = []struct {
+	name      string
+	algorithm string
+	state     InitialState
+}{
+	{"optimal", "optimal", testState(12, 1, 1, "0,5,0,9001", "3,9001,7,0", "0,0,0,8")},
+	{"exact", "exact", testState(12, 1, 1, "0,5,0,9001", "3,9001,7,0", "0,0,0,8")},
+	{"no steps", "optimal", testState(0, 1, 1, "0,5")},
+	{"terrain", "orienteering", InitialState{Battery: 20, MovementCost: 1, VacuumingCost: 2,
+		Tiles: [][]string{{"0", "4", "9"}, {"6", "0", "2"}}, Terrain: [][]string{{"1", "3", "1"}, {"1", "2:1", "1"}}}},
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, test := range outputTests {
+		t.Run(test.name, func(t *testing.T) {
+			result := testResult(t, test.algorithm, test.state, RunOptions{})
+			out := bytes.Buffer{}
+			if err := writeJSON(&out, test.algorithm, "map.csv", result); err != nil {
+				t.Fatal(err)
+			}
+
+			trajectory := jsonTrajectory{}
+			if err := json.Unmarshal(out.Bytes(), &trajectory); err != nil {
+				t.Fatal(err)
+			}
+			if want := newJSONTrajectory(test.algorithm, "map.csv", result); !reflect.DeepEqual(trajectory, want) {
+				t.Errorf("read back %+v, wrote %+v", trajectory, want)
+			}
+			checkReplay(t, test.state, "run.json", out.Bytes(), result)
+		})
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	for _, test := range outputTests {
+		t.Run(test.name, func(t *testing.T) {
+			result := testResult(t, test.algorithm, test.state, RunOptions{})
+			out := bytes.Buffer{}
+			if err := writeCSV(&out, result); err != nil {
+				t.Fatal(err)
+			}
+
+			reader := csv.NewReader(bytes.NewReader(out.Bytes()))
+			reader.Comment = '#'
+			rows, err := reader.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(result.Steps)+2 {
+				t.Fatalf("%d rows for %d steps", len(rows), len(result.Steps))
+			}
+			start := []string{"0", "start", strconv.Itoa(result.StartX), strconv.Itoa(result.StartY),
+				strconv.Itoa(result.Battery), strconv.Itoa(result.Battery), "0"}
+			if !reflect.DeepEqual(rows[1], start) {
+				t.Errorf("start row %v, want %v", rows[1], start)
+			}
+			for i, row := range rows[2:] {
+				step := Step{Action: Action(row[1])}
+				for j, field := range []*int{&step.X, &step.Y, &step.BatteryBefore, &step.BatteryAfter, &step.DirtVacuumed} {
+					if *field, err = strconv.Atoi(row[2+j]); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if row[0] != strconv.Itoa(i+1) || step != result.Steps[i] {
+					t.Errorf("row %v, want step %d %+v", row, i+1, result.Steps[i])
+				}
+			}
+			checkReplay(t, test.state, "run.csv", out.Bytes(), result)
+		})
+	}
+}
+
+// The written run read back as a plan replays to the same steps
+func checkReplay(t *testing.T, state InitialState, name string, content []byte, result PlanResult) {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	actions, err := ReadActions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, issues, err := VerifyPlan(state, RunOptions{}, actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) > 0 || !reflect.DeepEqual(replayed.Steps, result.Steps) || replayed.DirtCleaned != result.DirtCleaned {
+		t.Errorf("replay cleaned %d dirt in %d steps with issues %v, the run %d in %d steps",
+			replayed.DirtCleaned, len(replayed.Steps), issues, result.DirtCleaned, len(result.Steps))
+	}
+}
+
+// Writer that fails once the given number of bytes are written
+type failingWriter struct {
+	left int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.left {
+		written := w.left
+		w.left = 0
+		return written, errors.New("disk full")
+	}
+	w.left -= len(p)
+	return len(p), nil
+}
+
+func TestWritersReturnErrors(t *testing.T) {
+	state := testState(12, 1, 1, "0,5,0,9001", "3,9001,7,0", "0,0,0,8")
+	state.Terrain = [][]string{{"1", "2", "1", "1"}, {"1", "1", "1", "1"}, {"1", "1", "1", "1"}}
+	result := testResult(t, "optimal", state, RunOptions{})
+	result.Progress = []ProgressPoint{{Millis: 1, DirtCleaned: 5, Found: "greedy"}, {Millis: 9, DirtCleaned: 13, Found: "2-opt"}}
+	result.Decisions = []Decision{{Step: 0, Branch: "first"}, {Step: 1, Branch: "second"}}
+
+	writers := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"text", func(w io.Writer) error { return writeText(w, result) }},
+		{"json", func(w io.Writer) error { return writeJSON(w, "optimal", "map.csv", result) }},
+		{"csv", func(w io.Writer) error { return writeCSV(w, result) }},
+		{"json map", func(w io.Writer) error { return WriteInitialStateJSON(w, state) }},
+		{"ascii map", func(w io.Writer) error { return WriteInitialStateASCII(w, state) }},
+	}
+
+	for _, writer := range writers {
+		t.Run(writer.name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			if err := writer.write(&out); err != nil {
+				t.Fatal(err)
+			}
+			// failing anywhere, up to the last byte, is reported
+			for _, left := range []int{0, out.Len() / 2, out.Len() - 1} {
+				if err := writer.write(&failingWriter{left: left}); err == nil {
+					t.Errorf("no error when the writer fails after %d of %d bytes", left, out.Len())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+var perfState *InitialState
+
+// Large generated map where every dirty tile can be reached, with the generator's defaults otherwise.
+// Built once and shared by the benchmarks.
+func perfInitialState(b *testing.B) InitialState {
+	if perfState == nil {
+		flags := flag.NewFlagSet("perf", flag.ContinueOnError)
+		config := generatorFlags(flags)
+		if err := flags.Parse([]string{"--width=1000", "--height=1000", "--battery=20000", "--reachable"}); err != nil {
+			b.Fatal(err)
+		}
+		initialState, err := GenerateInitialState(*config)
+		if err != nil {
+			b.Fatal(err)
+		}
+		perfState = &initialState
+	}
+	return *perfState
+}
+
+func perfAgent(b *testing.B) Agent {
+	agent, err := CreateAgent(perfInitialState(b), RunOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return agent
+}
+
+func BenchmarkBFS(b *testing.B) {
+	agent := perfAgent(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		bfsDistances(&agent, agent.posX, agent.posY)
+	}
+}
+
+// Without terrain the search is a breadth-first search
+func BenchmarkDijkstra(b *testing.B) {
+	agent := perfAgent(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		dijkstra(&agent, [][2]int{{agent.posX, agent.posY}}, false)
+	}
+}
+
+// Same costs as the map, the terrain only makes the search take the priority queue
+func BenchmarkDijkstraTerrain(b *testing.B) {
+	agent := perfAgent(b)
+	agent.terrain = make([][][2]int, len(agent.tiles))
+	for y, row := range agent.tiles {
+		agent.terrain[y] = make([][2]int, len(row))
+		for x := range row {
+			agent.terrain[y][x] = [2]int{1, 1}
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		dijkstra(&agent, [][2]int{{agent.posX, agent.posY}}, false)
+	}
+}
+
+func benchmarkPlanner(b *testing.B, name string) {
+	initialState := perfInitialState(b)
+	planner, err := GetPlanner(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := planner.Plan(initialState, RunOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPlanGreedy(b *testing.B) {
+	benchmarkPlanner(b, "greedy")
+}
+
+func BenchmarkPlanOptimal(b *testing.B) {
+	benchmarkPlanner(b, "optimal")
+}
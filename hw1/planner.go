@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Single step taken by the agent
+type Action string
+
+const (
+	ActionUp     Action = "up"
+	ActionDown   Action = "down"
+	ActionLeft   Action = "left"
+	ActionRight  Action = "right"
+	ActionVacuum Action = "vacuum"
+	ActionCharge Action = "charge"
+	ActionWait   Action = "wait" // a fleet robot skipping a tick
+)
+
+// Step is a single successful action with the agent state right after it
+type Step struct {
+	Action        Action `json:"action"`
+	X             int    `json:"x"`
+	Y             int    `json:"y"`
+	BatteryBefore int    `json:"batteryBefore"`
+	BatteryAfter  int    `json:"batteryAfter"`
+	DirtVacuumed  int    `json:"dirtVacuumed"`
+}
+
+// PlanResult is what a planner returns after driving the agent through the map
+type PlanResult struct {
+	StartX        int
+	StartY        int
+	Battery       int      // Battery at the start
+	Actions       []Action // Successful actions in the order they were taken
+	Steps         []Step   // Same actions with positions and battery levels
+	DirtCleaned   int
+	TilesMoved    int
+	BatteryLeft   int
+	MustEndOnDock bool
+	EndedOnDock   bool
+	SensorRadius  int // 0 when the whole map was known
+	Discovered    int // tiles seen with the sensor, walls included
+	TotalTiles    int
+	Dynamic       bool            // dirt came back or the run had a horizon
+	Horizon       int             // 0 when the run was not limited
+	Turns         int             // steps the environment advanced
+	DirtAppeared  int             // regrowth and events
+	AverageDirt   float64         // dirt on the map averaged over the turns, lower is cleaner
+	Rollouts      *RolloutSummary // expected score over several seeds, nil for a single run
+	Progress      []ProgressPoint // best score found over time, only for anytime planners
+	Termination   string          // why the run ended, one of the TERMINATION_ reasons
+	Bound         *DirtBound      // upper bound on the dirt cleaned, nil when dirt comes back
+	Logs          []string
+	StepLogs      []int      // index into Logs of the log of every step, the logs before it explain the step
+	Decisions     []Decision // why each move was chosen, only with --explain and only by the optimal algorithm
+}
+
+// Reasons a run ended
+const (
+	TERMINATION_CLEAN       = "all clean"
+	TERMINATION_BATTERY     = "battery depleted" // dirt is left that the battery could not get to
+	TERMINATION_UNREACHABLE = "unreachable dirt" // the dirt left is walled off
+	TERMINATION_STEP_CAP    = "step cap"
+	TERMINATION_STALLED     = "stalled" // the planner went on without making progress
+	TERMINATION_HORIZON     = "horizon reached"
+	TERMINATION_STOPPED     = "planner stopped" // the battery could still have cleaned dirt or charged
+	TERMINATION_DEADLINE    = "deadline"        // the caller's time for the run ran out, see RunOptions.Deadline
+)
+
+// RunOptions are the settings of a single run that are not part of the map
+type RunOptions struct {
+	Seed       int64         // Seed of the slips and of random choices
+	TimeBudget time.Duration // How long anytime planners may search
+	StepLimit  int           // Most actions a run may take, 0 for the default and negative for none
+	Explain    bool          // Record the planner's decisions
+	Deadline   time.Time     // When the planner has to give up and return, the zero time for never
+}
+
+// Planner is a cleaning algorithm that can be selected by name
+type Planner interface {
+	Name() string
+	Description() string
+	Plan(initialState InitialState, options RunOptions) (PlanResult, error)
+}
+
+// Adapter so a plain function can be registered as a Planner
+type plannerFunc struct {
+	name        string
+	description string
+	plan        func(InitialState, RunOptions) (PlanResult, error)
+}
+
+func (planner plannerFunc) Name() string        { return planner.name }
+func (planner plannerFunc) Description() string { return planner.description }
+func (planner plannerFunc) Plan(initialState InitialState, options RunOptions) (PlanResult, error) {
+	return planner.plan(initialState, options)
+}
+
+// NewPlannerFunc wraps a planning function into a Planner
+func NewPlannerFunc(name string, description string, plan func(InitialState, RunOptions) (PlanResult, error)) Planner {
+	return plannerFunc{name: name, description: description, plan: plan}
+}
+
+var planners = map[string]Planner{}
+
+// RegisterPlanner makes a planner available by its name. Meant to be called from init().
+func RegisterPlanner(planner Planner) {
+	if _, exists := planners[planner.Name()]; exists {
+		panic(fmt.Sprintf("Planner %s registered twice", planner.Name()))
+	}
+	planners[planner.Name()] = planner
+}
+
+// GetPlanner looks up a registered planner by name
+func GetPlanner(name string) (Planner, error) {
+	planner, ok := planners[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown algorithm %s", name)
+	}
+	return planner, nil
+}
+
+// PlannerNames lists registered planners in alphabetical order
+func PlannerNames() []string {
+	names := []string{}
+	for name := range planners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
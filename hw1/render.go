@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerCommand("render", "[--algorithm=optimal] [--plan=actions file] [--cell=32] [--out=file.png] <input map>",
+		"Draws the map and the cleaning trajectory into a PNG image",
+		runRender)
+}
+
+var (
+	colorFloor    = color.RGBA{211, 211, 211, 255}
+	colorGridLine = color.RGBA{128, 128, 128, 255}
+	colorWall     = color.RGBA{0, 0, 0, 255}
+	colorDirt     = color.RGBA{139, 69, 19, 255}
+	colorCleaned  = color.RGBA{0, 160, 0, 255}
+	colorText     = color.RGBA{0, 0, 0, 255}
+	colorVisits   = color.RGBA{0, 0, 255, 255}
+	colorStart    = color.RGBA{255, 255, 255, 255}
+	colorEnd      = color.RGBA{255, 215, 0, 255}
+	colorDock     = color.RGBA{100, 149, 237, 255}
+)
+
+// 3x5 pixel glyphs, enough for dirt values and the header line
+var glyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'B': {"##.", "#.#", "##.", "#.#", "##."},
+	'E': {"###", "#..", "##.", "#..", "###"},
+	'S': {"###", "#..", "###", "..#", "###"},
+	'V': {"#.#", "#.#", "#.#", "#.#", ".#."},
+	'=': {"...", "###", "...", "###", "..."},
+	',': {"...", "...", "...", ".#.", "#.."},
+	' ': {"...", "...", "...", "...", "..."},
+}
+
+// RenderTrajectory draws walls, dirt intensity, the path in step order (colors go around the hue circle),
+// visit counts above one, vacuumed tiles (green frame), docks (blue), the start (white) and the end (yellow).
+func RenderTrajectory(initialState InitialState, result PlanResult, cellSize int) (*image.RGBA, error) {
+	agent, err := CreateAgent(initialState, RunOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if cellSize < 8 {
+		return nil, errors.New("Cell size must be at least 8 pixels")
+	}
+
+	rows, cols := len(agent.tiles), 0
+	maxDirt := 0
+	for _, row := range agent.tiles {
+		if len(row) > cols {
+			cols = len(row)
+		}
+		for _, tile := range row {
+			if tile > maxDirt && tile < WALL_VALUE {
+				maxDirt = tile
+			}
+		}
+	}
+
+	scale := cellSize / 16
+	if scale < 1 {
+		scale = 1
+	}
+	header := 7 * scale
+	img := image.NewRGBA(image.Rect(0, 0, cols*cellSize, rows*cellSize+header))
+	fillRect(img, img.Bounds(), colorStart)
+	drawText(img, scale, scale, fmt.Sprintf("B=%d, E=%d, V=%d", initialState.Battery, initialState.MovementCost, initialState.VacuumingCost), colorText, scale)
+
+	cell := func(x int, y int) image.Rectangle {
+		return image.Rect(x*cellSize, header+y*cellSize, (x+1)*cellSize, header+(y+1)*cellSize)
+	}
+	center := func(x int, y int) (int, int) {
+		return x*cellSize + cellSize/2, header + y*cellSize + cellSize/2
+	}
+
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			tile := agent.getTileValue(x, y)
+			if tile == WALL_VALUE {
+				fillRect(img, cell(x, y), colorWall)
+				continue
+			}
+
+			fill := colorFloor
+			if tile == DOCK_VALUE {
+				fill = colorDock
+			} else if tile > 0 && tile < WALL_VALUE {
+				fill = blend(colorFloor, colorDirt, math.Log(1+float64(tile))/math.Log(1+float64(maxDirt)))
+			}
+			fillRect(img, cell(x, y), fill)
+			strokeRect(img, cell(x, y), colorGridLine, 1)
+
+			if tile > 0 && tile < WALL_VALUE {
+				drawText(img, x*cellSize+2, header+y*cellSize+2, strconv.Itoa(tile), colorText, scale)
+			}
+		}
+	}
+
+	visits := map[[2]int]int{{result.StartX, result.StartY}: 1}
+	prevX, prevY := result.StartX, result.StartY
+	for i, step := range result.Steps {
+		if step.Action == ActionVacuum {
+			strokeRect(img, cell(step.X, step.Y), colorCleaned, 1+scale)
+			continue
+		}
+
+		x1, y1 := center(prevX, prevY)
+		x2, y2 := center(step.X, step.Y)
+		drawLine(img, x1, y1, x2, y2, hue(float64(i)/float64(len(result.Steps))), scale)
+		visits[[2]int{step.X, step.Y}]++
+		prevX, prevY = step.X, step.Y
+	}
+
+	for pos, count := range visits {
+		if count > 1 {
+			drawText(img, pos[0]*cellSize+2, header+(pos[1]+1)*cellSize-2-5*scale, strconv.Itoa(count), colorVisits, scale)
+		}
+	}
+
+	x, y := center(result.StartX, result.StartY)
+	fillCircle(img, x, y, cellSize/6, colorStart)
+	x, y = center(prevX, prevY)
+	fillCircle(img, x, y, cellSize/8, colorEnd)
+
+	return img, nil
+}
+
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func strokeRect(img *image.RGBA, rect image.Rectangle, c color.RGBA, width int) {
+	fillRect(img, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+width), c)
+	fillRect(img, image.Rect(rect.Min.X, rect.Max.Y-width, rect.Max.X, rect.Max.Y), c)
+	fillRect(img, image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+width, rect.Max.Y), c)
+	fillRect(img, image.Rect(rect.Max.X-width, rect.Min.Y, rect.Max.X, rect.Max.Y), c)
+}
+
+func fillCircle(img *image.RGBA, cx int, cy int, radius int, c color.RGBA) {
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if x*x+y*y <= radius*radius && image.Pt(cx+x, cy+y).In(img.Bounds()) {
+				img.SetRGBA(cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+// Straight line made of width x width squares
+func drawLine(img *image.RGBA, x1 int, y1 int, x2 int, y2 int, c color.RGBA, width int) {
+	steps := int(math.Max(math.Abs(float64(x2-x1)), math.Abs(float64(y2-y1))))
+	for i := 0; i <= steps; i++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		x := x1 + int(math.Round(t*float64(x2-x1)))
+		y := y1 + int(math.Round(t*float64(y2-y1)))
+		fillRect(img, image.Rect(x-width/2, y-width/2, x-width/2+width, y-width/2+width), c)
+	}
+}
+
+func drawText(img *image.RGBA, x int, y int, text string, c color.RGBA, scale int) {
+	for _, char := range strings.ToUpper(text) {
+		glyph, ok := glyphs[char]
+		if !ok {
+			glyph = glyphs[' ']
+		}
+		for row, line := range glyph {
+			for col, pixel := range line {
+				if pixel == '#' {
+					fillRect(img, image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale), c)
+				}
+			}
+		}
+		x += 4 * scale
+	}
+}
+
+func blend(from color.RGBA, to color.RGBA, t float64) color.RGBA {
+	mix := func(a uint8, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t)
+	}
+	return color.RGBA{mix(from.R, to.R), mix(from.G, to.G), mix(from.B, to.B), 255}
+}
+
+// Fully saturated color at position t in [0, 1) of the hue circle
+func hue(t float64) color.RGBA {
+	h := math.Mod(t, 1) * 6
+	f := h - math.Floor(h)
+	up, down := uint8(255*f), uint8(255*(1-f))
+	switch int(h) {
+	case 0:
+		return color.RGBA{255, up, 0, 255}
+	case 1:
+		return color.RGBA{down, 255, 0, 255}
+	case 2:
+		return color.RGBA{0, 255, up, 255}
+	case 3:
+		return color.RGBA{0, down, 255, 255}
+	case 4:
+		return color.RGBA{up, 0, 255, 255}
+	}
+	return color.RGBA{255, 0, down, 255}
+}
+
+func runRender(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	algorithm := flags.String("algorithm", "optimal", "Algorithm whose run is drawn")
+	plan := flags.String("plan", "", "Draw this action sequence (see verify) instead of running an algorithm")
+	cellSize := flags.Int("cell", 32, "Tile size in pixels")
+	out := flags.String("out", "", "Output PNG file (default derived from the input path, e.g. inputs_6.csv.png)")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return commandUsage("render")
+	}
+
+	filePath := flags.Arg(0)
+	initialState, err := ReadValidInitialState(filePath, "")
+	if err != nil {
+		return err
+	}
+
+	var result PlanResult
+	if *plan != "" {
+		actions, err := ReadActions(*plan)
+		if err != nil {
+			return err
+		}
+		result, _, err = VerifyPlan(initialState, RunOptions{}, actions)
+		if err != nil {
+			return err
+		}
+	} else {
+		planner, err := GetPlanner(*algorithm)
+		if err != nil {
+			return err
+		}
+		result, err = planner.Plan(initialState, RunOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	img, err := RenderTrajectory(initialState, result, *cellSize)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		// same naming as writeup/vizualize.py
+		*out = strings.Trim(strings.NewReplacer("/", "_", "\\", "_").Replace(filePath), "._") + ".png"
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return png.Encode(f, img)
+}
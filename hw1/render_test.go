@@ -0,0 +1,174 @@
+package main
+
+import (
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Pixel to look at in the tile (x, y): "center", "corner" (inside the bottom right, clear of text, the path and
+// frames), "frame" (the middle of the top edge), "count" (the first pixel of the visit count)
+func tilePixel(cellSize int, x int, y int, spot string) (int, int) {
+	scale := cellSize / 16
+	if scale < 1 {
+		scale = 1
+	}
+	left, top := x*cellSize, 7*scale+y*cellSize
+	switch spot {
+	case "center":
+		return left + cellSize/2, top + cellSize/2
+	case "corner":
+		return left + cellSize - 5, top + cellSize - 5
+	case "frame":
+		return left + cellSize/2, top
+	}
+	return left + 2, top + cellSize - 2 - 5*scale
+}
+
+func TestRenderTrajectory(t *testing.T) {
+	type pixel struct {
+		x, y  int
+		spot  string
+		color color.RGBA
+	}
+	dirt3 := blend(colorFloor, colorDirt, math.Log(4)/math.Log(6))
+
+	tests := []struct {
+		name     string
+		state    InitialState
+		plan     string // actions as in a plan file, separated by spaces
+		cellSize int
+		pixels   []pixel
+	}{
+		{"no steps", testState(10, 1, 1, "0,5,9001", "9002,0,3"), "", 32, []pixel{
+			{2, 0, "corner", colorWall}, {0, 1, "corner", colorDock}, {1, 0, "corner", colorDirt}, {2, 1, "corner", dirt3},
+			{1, 1, "corner", colorFloor}, {1, 1, "frame", colorGridLine}, {0, 0, "center", colorEnd},
+		}},
+		{"path and vacuum", testState(10, 1, 1, "0,5,9001", "9002,0,3"), "r v d", 32, []pixel{
+			{0, 0, "center", colorStart}, {1, 1, "center", colorEnd}, {1, 0, "frame", colorCleaned},
+			{1, 0, "corner", colorDirt}, {2, 1, "frame", colorGridLine}, {0, 0, "count", colorFloor},
+		}},
+		{"visits counted", testState(10, 1, 1, "0,0,0"), "r l r", 32, []pixel{
+			{1, 0, "count", colorVisits}, {0, 0, "count", colorVisits}, {2, 0, "count", colorFloor}, {1, 0, "center", colorEnd},
+		}},
+		{"smallest cells", testState(10, 1, 1, "0,4", "0,0"), "d", 8, []pixel{
+			{1, 0, "corner", colorDirt}, {0, 0, "center", colorStart}, {0, 1, "center", colorEnd},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actions := []PlannedAction{}
+			for i, field := range strings.Fields(test.plan) {
+				action, err := ParseAction(field)
+				if err != nil {
+					t.Fatal(err)
+				}
+				actions = append(actions, PlannedAction{Action: action, Line: i + 1})
+			}
+			result, _, err := VerifyPlan(test.state, RunOptions{}, actions)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := RenderTrajectory(test.state, result, test.cellSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scale := test.cellSize / 16
+			if scale < 1 {
+				scale = 1
+			}
+			width, height := len(test.state.Tiles[0])*test.cellSize, len(test.state.Tiles)*test.cellSize+7*scale
+			if bounds := img.Bounds(); bounds.Dx() != width || bounds.Dy() != height {
+				t.Errorf("image %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), width, height)
+			}
+			for _, want := range test.pixels {
+				x, y := tilePixel(test.cellSize, want.x, want.y, want.spot)
+				if got := img.RGBAAt(x, y); got != want.color {
+					t.Errorf("%s of (%d, %d) is %v, want %v", want.spot, want.x, want.y, got, want.color)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    InitialState
+		cellSize int
+		want     string
+	}{
+		{"small cells", testState(10, 1, 1, "0,4"), 7, "at least 8 pixels"},
+		{"start on a wall", testState(10, 1, 1, "9001,4"), 32, "wall"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := RenderTrajectory(test.state, PlanResult{}, test.cellSize); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one about %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestHue(t *testing.T) {
+	tests := []struct {
+		t    float64
+		want color.RGBA
+	}{
+		{0, color.RGBA{255, 0, 0, 255}},
+		{0.5, color.RGBA{0, 255, 255, 255}},
+		{1.0 / 12, color.RGBA{255, 127, 0, 255}},
+		{0.75, color.RGBA{127, 0, 255, 255}},
+		{1, color.RGBA{255, 0, 0, 255}},
+	}
+	for _, test := range tests {
+		if got := hue(test.t); got != test.want {
+			t.Errorf("hue(%g) = %v, want %v", test.t, got, test.want)
+		}
+	}
+}
+
+func TestRunRender(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "map.png")
+	tests := []struct {
+		name string
+		args []string
+		want string // part of the error, empty for none
+	}{
+		{"optimal", []string{"--cell=16", "--out=" + out, "inputs/1.csv"}, ""},
+		{"other algorithm", []string{"--algorithm=greedy", "--cell=16", "--out=" + out, "inputs/1.csv"}, ""},
+		{"no map", []string{"--out=" + out}, "Usage"},
+		{"unknown algorithm", []string{"--algorithm=fastest", "--out=" + out, "inputs/1.csv"}, "fastest"},
+		{"missing plan", []string{"--plan=missing.txt", "--out=" + out, "inputs/1.csv"}, "Error opening file"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Remove(out)
+			err := runRender(test.args)
+			if test.want != "" {
+				if err == nil || !strings.Contains(err.Error(), test.want) {
+					t.Errorf("got error %v, want one about %q", err, test.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := png.Decode(f); err != nil {
+				t.Errorf("not a PNG: %v", err)
+			}
+		})
+	}
+}
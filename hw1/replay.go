@@ -0,0 +1,236 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const REPLAY_MAX_SPEED = 1000 // Steps per second the + key speeds up to
+
+func init() {
+	registerCommand("replay", "[--algorithm=optimal] [--plan=actions file] [--speed=10] [--heatmap] [--paused] <input map>",
+		"Plays a run step by step in the terminal, with pause, single steps, speed keys and a visit heatmap",
+		runReplay)
+}
+
+// Replay of a finished run on a copy of the map, the agent follows the recorded steps instead of the simulator
+// rules so slips happen again as they did and dirt comes back on the same turns
+type replay struct {
+	agent   *Agent
+	result  PlanResult
+	step    int     // steps shown so far
+	visits  [][]int // times the agent stood on every tile
+	most    int
+	speed   float64 // steps per second
+	paused  bool
+	heatmap bool
+}
+
+func newReplay(initialState InitialState, result PlanResult) (*replay, error) {
+	agent, err := CreateAgent(initialState, RunOptions{StepLimit: -1})
+	if err != nil {
+		return nil, err
+	}
+
+	show := replay{agent: &agent, result: result, most: agent.dirt.most, visits: make([][]int, len(agent.tiles))}
+	for y := range show.visits {
+		show.visits[y] = make([]int, len(agent.tiles[y]))
+	}
+	show.visits[agent.posY][agent.posX]++
+	return &show, nil
+}
+
+func (show *replay) done() bool {
+	return show.step >= len(show.result.Steps)
+}
+
+// Moves the agent on to the next recorded step
+func (show *replay) forward() {
+	agent, step := show.agent, show.result.Steps[show.step]
+	show.step++
+
+	if step.X != agent.posX || step.Y != agent.posY {
+		agent.tilesMoved++
+	}
+	agent.posX, agent.posY, agent.battery = step.X, step.Y, step.BatteryAfter
+	if step.DirtVacuumed > 0 {
+		agent.dirt.change(agent.tiles[step.Y][step.X], 0)
+		agent.tiles[step.Y][step.X] = 0
+		agent.dirtCleaned += step.DirtVacuumed
+	}
+	agent.sense()
+	agent.steps = append(agent.steps, step)
+	if agent.env != nil {
+		agent.env.advance(agent.tiles)
+	}
+	show.visits[step.Y][step.X]++
+}
+
+// What the planner logged for the last step shown: its notes since the step before, then the step itself,
+// after the decision that led to the step when the planner recorded its decisions
+func (show *replay) decision() string {
+	if show.step == 0 {
+		return "Start"
+	}
+	step := show.result.Steps[show.step-1]
+	if len(show.result.StepLogs) < show.step {
+		return string(step.Action)
+	}
+
+	from := 0
+	if show.step > 1 {
+		from = show.result.StepLogs[show.step-2] + 1
+	}
+	logs := strings.Join(show.result.Logs[from:show.result.StepLogs[show.step-1]+1], ", ")
+	for i := len(show.result.Decisions) - 1; i >= 0; i-- {
+		if show.result.Decisions[i].Step < show.step {
+			return show.result.Decisions[i].String() + "\r\n" + logs
+		}
+	}
+	return logs
+}
+
+func (show *replay) draw(out io.Writer, keys bool) {
+	status := fmt.Sprintf("Step %d of %d | %s | %g steps/s", show.step, len(show.result.Steps), manualStatus(show.agent), show.speed)
+	if show.paused {
+		status += ", paused"
+	}
+	var visits [][]int
+	if show.heatmap {
+		visits = show.visits
+	}
+
+	message := show.decision()
+	if keys {
+		message += "\r\nspace pauses, n steps, + and - change the speed, h toggles the heatmap, q quits"
+	}
+	drawTerminalView(out, show.agent, show.most, visits, status, message)
+}
+
+// Plays the run to out. With keys (a terminal in raw mode) they control it, otherwise it plays through,
+// without delays at speed 0.
+func (show *replay) play(out io.Writer, keys <-chan byte) {
+	for {
+		show.draw(out, keys != nil)
+		if show.done() {
+			if keys != nil {
+				fmt.Fprint(out, "Replay over, press any key\r\n")
+				<-keys
+			}
+			return
+		}
+
+		var tick <-chan time.Time
+		if !show.paused && show.speed > 0 {
+			tick = time.After(time.Duration(float64(time.Second) / show.speed))
+		} else if !show.paused {
+			show.forward()
+			continue
+		}
+
+		select {
+		case <-tick:
+			show.forward()
+		case key, ok := <-keys:
+			if !ok {
+				return
+			}
+			switch key {
+			case ' ', 'p':
+				show.paused = !show.paused
+			case 'n', '.':
+				show.paused = true
+				if !show.done() {
+					show.forward()
+				}
+			case '+', '=':
+				if show.speed < REPLAY_MAX_SPEED {
+					show.speed *= 2
+				}
+			case '-', '_':
+				show.speed /= 2
+			case 'h':
+				show.heatmap = !show.heatmap
+			case 'q', 3, 4:
+				return
+			}
+		}
+	}
+}
+
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	algorithm := flags.String("algorithm", "optimal", "Algorithm whose run is played")
+	plan := flags.String("plan", "", "Play this action sequence (see verify) instead of running an algorithm")
+	speed := flags.Float64("speed", 10, "Steps per second, 0 to play through without delays")
+	heatmap := flags.Bool("heatmap", false, "Start with the visit heatmap shown")
+	paused := flags.Bool("paused", false, "Start paused, n steps")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return commandUsage("replay")
+	}
+	if *speed < 0 {
+		return fmt.Errorf("Invalid speed %g, expected 0 or more steps per second", *speed)
+	}
+
+	initialState, err := ReadValidInitialState(flags.Arg(0), "")
+	if err != nil {
+		return err
+	}
+
+	var result PlanResult
+	if *plan != "" {
+		actions, err := ReadActions(*plan)
+		if err != nil {
+			return err
+		}
+		result, _, err = VerifyPlan(initialState, RunOptions{}, actions)
+		if err != nil {
+			return err
+		}
+	} else {
+		planner, err := GetPlanner(*algorithm)
+		if err != nil {
+			return err
+		}
+		result, err = planner.Plan(initialState, RunOptions{Explain: true})
+		if err != nil {
+			return err
+		}
+	}
+
+	show, err := newReplay(initialState, result)
+	if err != nil {
+		return err
+	}
+	show.speed, show.heatmap, show.paused = *speed, *heatmap, *paused
+
+	var keys chan byte
+	if isTerminal(os.Stdin) {
+		if restore, err := rawTerminal(); err == nil {
+			defer restore()
+			keys = make(chan byte)
+			go func() {
+				buffer := make([]byte, 1)
+				for {
+					if n, err := os.Stdin.Read(buffer); err != nil || n == 0 {
+						close(keys)
+						return
+					}
+					keys <- buffer[0]
+				}
+			}()
+		}
+	}
+	if keys == nil {
+		show.paused = false // nothing could resume it
+	}
+
+	show.play(os.Stdout, keys)
+	return nil
+}
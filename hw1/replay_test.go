@@ -0,0 +1,232 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		actions []Action
+		lines   []int
+	}{
+		{"plain text", "# a plan\nr\n\nvacuum # the dirt\nD\n", []Action{ActionRight, ActionVacuum, ActionDown}, []int{2, 4, 5}},
+		{"csv trajectory", "step,action,x,y,batteryBefore,batteryAfter,dirtVacuumed\n0,start,0,0,5,5,0\n1,right,1,0,5,4,0\n2,vacuum,1,0,4,3,5\n",
+			[]Action{ActionRight, ActionVacuum}, []int{3, 4}},
+		{"json trajectory", `{"start": {"x": 0, "y": 0}, "steps": [{"action": "up"}, {"action": "charge"}, {"action": "wait"}]}`,
+			[]Action{ActionUp, ActionCharge, ActionWait}, []int{1, 2, 3}},
+		{"empty", "# nothing\n\n", []Action{}, []int{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plan.txt")
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			planned, err := ReadActions(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actions, lines := []Action{}, []int{}
+			for _, action := range planned {
+				actions, lines = append(actions, action.Action), append(lines, action.Line)
+			}
+			if !reflect.DeepEqual(actions, test.actions) || !reflect.DeepEqual(lines, test.lines) {
+				t.Errorf("read %v on lines %v, want %v on %v", actions, lines, test.actions, test.lines)
+			}
+		})
+	}
+}
+
+func TestReadActionsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown action", "r\njump\n", "line 2: Unknown action \"jump\""},
+		{"unknown csv action", "step,action,x\n1,fly,0\n", "line 2: Unknown action \"fly\""},
+		{"json syntax", `{"steps": [`, "Error parsing JSON trajectory"},
+		{"unknown json action", `{"steps": [{"action": "right"}, {"action": "fly"}]}`, "step 2: Unknown action"},
+		{"missing file", "", "Error opening file"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plan.txt")
+			if test.content != "" {
+				if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := ReadActions(path); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one about %q", err, test.want)
+			}
+		})
+	}
+}
+
+// A replay played to the end is where the run ended, even where moves slipped or dirt came back
+func TestReplayFollowsRun(t *testing.T) {
+	slippery := testState(30, 1, 1, "0,4,0,9001", "6,9001,0,7", "0,0,3,0")
+	slippery.MoveSuccess = 0.6
+	growing := testState(30, 1, 1, "0,4,0", "6,0,0")
+	growing.Horizon = 20
+	growing.Regrowth = [][]string{{"0", "0", "2/3"}, {"0", "1", "0"}}
+	sensing := testState(30, 1, 1, "0,4,0,9001", "6,9001,0,7", "0,0,3,0")
+	sensing.SensorRadius = 1
+
+	tests := []struct {
+		name      string
+		algorithm string
+		state     InitialState
+	}{
+		{"optimal", "optimal", testState(30, 1, 1, "0,4,0,9001", "6,9001,0,7", "0,0,3,0")},
+		{"moves slip", "mdp", slippery},
+		{"dirt comes back", "online", growing},
+		{"sensor", "explore", sensing},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := testResult(t, test.algorithm, test.state, RunOptions{Seed: 5})
+			show, err := newReplay(test.state, result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for !show.done() {
+				show.forward()
+			}
+
+			agent, visits := show.agent, 0
+			for _, row := range show.visits {
+				for _, count := range row {
+					visits += count
+				}
+			}
+			if agent.dirtCleaned != result.DirtCleaned || agent.battery != result.BatteryLeft || agent.tilesMoved != result.TilesMoved {
+				t.Errorf("replayed %d dirt, %d battery, %d tiles moved, the run %d, %d, %d", agent.dirtCleaned,
+					agent.battery, agent.tilesMoved, result.DirtCleaned, result.BatteryLeft, result.TilesMoved)
+			}
+			if last := result.Steps[len(result.Steps)-1]; agent.posX != last.X || agent.posY != last.Y {
+				t.Errorf("replay ended at (%d, %d), the run at (%d, %d)", agent.posX, agent.posY, last.X, last.Y)
+			}
+			if visits != len(result.Steps)+1 {
+				t.Errorf("%d visits for %d steps", visits, len(result.Steps))
+			}
+		})
+	}
+}
+
+func TestReplayDecision(t *testing.T) {
+	state := testState(30, 1, 1, "0,4,0", "6,0,9")
+	explained := testResult(t, "optimal", state, RunOptions{Explain: true})
+	plain := testResult(t, "optimal", state, RunOptions{})
+	bare := plain
+	bare.Logs, bare.StepLogs = nil, nil
+
+	tests := []struct {
+		name   string
+		result PlanResult
+		want   func(step Step, i int) string // part of the line shown after step i
+	}{
+		{"step logs", plain, func(step Step, i int) string { return plain.Logs[plain.StepLogs[i]] }},
+		{"with decisions", explained, func(step Step, i int) string { return "\r\n" + explained.Logs[explained.StepLogs[i]] }},
+		{"only the action", bare, func(step Step, i int) string { return string(step.Action) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			show, err := newReplay(state, test.result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision := show.decision(); decision != "Start" {
+				t.Errorf("before the first step %q", decision)
+			}
+			for i, step := range test.result.Steps {
+				show.forward()
+				if decision := show.decision(); !strings.Contains(decision, test.want(step, i)) {
+					t.Errorf("step %d shows %q, want %q in it", i+1, decision, test.want(step, i))
+				}
+			}
+		})
+	}
+}
+
+func TestReplayPlay(t *testing.T) {
+	state := testState(30, 1, 1, "0,4,0", "6,0,9")
+	result := testResult(t, "optimal", state, RunOptions{})
+
+	tests := []struct {
+		name    string
+		keys    string // sent in order, then the keys end
+		noKeys  bool
+		steps   int
+		speed   float64
+		heatmap bool
+	}{
+		{"plays through without keys", "", true, len(result.Steps), 0, false},
+		{"single steps", "nn.", false, 3, 10, false},
+		{"quit", "nq", false, 1, 10, false},
+		{"speed and heatmap", "++-hnh", false, 1, 20, false},
+		{"speed capped", "+++++++++++++++", false, 0, 1280, false},
+		{"steps stop at the end", strings.Repeat("n", 50), false, len(result.Steps), 10, false},
+		{"heatmap on", "h", false, 0, 10, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			show, err := newReplay(state, result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			show.speed, show.paused = 10, true
+
+			var keys chan byte
+			if test.noKeys {
+				show.speed, show.paused = 0, false
+			} else {
+				keys = make(chan byte, len(test.keys))
+				for _, key := range []byte(test.keys) {
+					keys <- key
+				}
+				close(keys)
+			}
+
+			out := strings.Builder{}
+			show.play(&out, keys)
+			if show.step != test.steps || show.speed != test.speed || show.heatmap != test.heatmap {
+				t.Errorf("%d steps at %g steps/s with heatmap %v, want %d at %g with %v",
+					show.step, show.speed, show.heatmap, test.steps, test.speed, test.heatmap)
+			}
+			if !strings.Contains(out.String(), "Step 0 of") {
+				t.Error("the start was not drawn")
+			}
+		})
+	}
+}
+
+func TestRunReplayRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"no map", "--speed=0", "Usage"},
+		{"negative speed", "--speed=-1 inputs/1.csv", "Invalid speed"},
+		{"unknown algorithm", "--algorithm=fastest --speed=0 inputs/1.csv", "fastest"},
+		{"missing plan", "--plan=missing.txt --speed=0 inputs/1.csv", "Error opening file"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := runReplay(strings.Fields(test.args)); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one about %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math"
+)
+
+// Dirt cleaned over several runs of a planner with consecutive seeds, the expected score on a slippery floor
+type RolloutSummary struct {
+	Runs   int     `json:"runs"`
+	Seed   int64   `json:"seed"` // seed of the first run
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+}
+
+// RunRollouts plans the map runs times with the seeds options.Seed, options.Seed+1, ...
+// first is the run with options.Seed that the caller already planned, it counts as the first rollout.
+func RunRollouts(planner Planner, initialState InitialState, options RunOptions, first PlanResult, runs int) (RolloutSummary, error) {
+	summary := RolloutSummary{Runs: runs, Seed: options.Seed}
+	scores := []float64{}
+	for i := 0; i < runs; i++ {
+		result := first
+		if i > 0 {
+			options.Seed = summary.Seed + int64(i)
+			var err error
+			if result, err = planner.Plan(initialState, options); err != nil {
+				return summary, err
+			}
+		}
+
+		if i == 0 || result.DirtCleaned < summary.Min {
+			summary.Min = result.DirtCleaned
+		}
+		if i == 0 || result.DirtCleaned > summary.Max {
+			summary.Max = result.DirtCleaned
+		}
+		scores = append(scores, float64(result.DirtCleaned))
+		summary.Mean += float64(result.DirtCleaned) / float64(runs)
+	}
+
+	for _, score := range scores {
+		summary.StdDev += (score - summary.Mean) * (score - summary.Mean) / float64(runs)
+	}
+	summary.StdDev = math.Sqrt(summary.StdDev)
+	return summary, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Branches a decision of the optimal algorithm can take
+const (
+	DECISION_NEIGHBOR = "neighbor" // greedy pick of a dirty neighbor
+	DECISION_SEARCH   = "search"   // no dirty neighbor, heads for the tile findNearestValuable found
+	DECISION_STOP     = "stop"     // nothing dirty within battery
+)
+
+// Neighbor the optimal algorithm looked at before a decision
+type DecisionCandidate struct {
+	Action  Action `json:"action"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Dirt    int    `json:"dirt"`
+	Cost    int    `json:"cost"`              // battery of moving there and vacuuming
+	Skipped string `json:"skipped,omitempty"` // "wall" or "battery" when the move is not possible
+}
+
+// One decision of the optimal algorithm, recorded with --explain. The steps from Step on carry it out.
+type Decision struct {
+	Step       int                 `json:"step"` // actions taken before it
+	X          int                 `json:"x"`
+	Y          int                 `json:"y"`
+	Battery    int                 `json:"battery"`
+	Branch     string              `json:"branch"` // one of the DECISION_ branches
+	Candidates []DecisionCandidate `json:"candidates"`
+	TargetX    int                 `json:"targetX"` // -1 when stopping
+	TargetY    int                 `json:"targetY"`
+	TargetDirt int                 `json:"targetDirt"`
+	Distance   int                 `json:"distance"` // moves to the target
+	Cost       int                 `json:"cost"`     // battery of the moves and vacuuming the target
+	TieBreak   string              `json:"tieBreak,omitempty"`
+}
+
+func (decision Decision) String() string {
+	text := fmt.Sprintf("Step %d at (%d, %d), battery %d: ", decision.Step, decision.X, decision.Y, decision.Battery)
+	switch decision.Branch {
+	case DECISION_STOP:
+		text += "stop, no dirty tile within battery"
+	case DECISION_NEIGHBOR:
+		text += fmt.Sprintf("neighbor (%d, %d) with %d dirt for %d battery with vacuuming",
+			decision.TargetX, decision.TargetY, decision.TargetDirt, decision.Cost)
+	default:
+		text += fmt.Sprintf("search to (%d, %d) with %d dirt, %d moves for %d battery with vacuuming",
+			decision.TargetX, decision.TargetY, decision.TargetDirt, decision.Distance, decision.Cost)
+	}
+	if decision.TieBreak != "" {
+		text += fmt.Sprintf(" (%s)", decision.TieBreak)
+	}
+
+	candidates := []string{}
+	for _, candidate := range decision.Candidates {
+		if candidate.Skipped != "" {
+			candidates = append(candidates, fmt.Sprintf("%s %s", candidate.Action, candidate.Skipped))
+			continue
+		}
+		candidates = append(candidates, fmt.Sprintf("%s %d/%d", candidate.Action, candidate.Dirt, candidate.Cost))
+	}
+	return text + "; neighbors dirt/battery: " + strings.Join(candidates, ", ")
+}
+
+// Starts a decision at the agent's position, nil when the agent does not explain itself
+func (agent *Agent) newDecision() *Decision {
+	if !agent.explain {
+		return nil
+	}
+	return &Decision{Step: len(agent.steps), X: agent.posX, Y: agent.posY, Battery: agent.battery,
+		Candidates: []DecisionCandidate{}, TargetX: -1, TargetY: -1}
+}
+
+// Records the decision, nothing when it is nil
+func (agent *Agent) decide(decision *Decision) {
+	if decision != nil {
+		agent.decisions = append(agent.decisions, *decision)
+	}
+}
+
+// Why the neighbor pick chose the candidate with the given action over the other dirty ones, see betterValue
+func neighborTieBreak(candidates []DecisionCandidate, chosen Action) string {
+	var best DecisionCandidate
+	for _, candidate := range candidates {
+		if candidate.Action == chosen {
+			best = candidate
+		}
+	}
+
+	rule := "only dirty neighbor"
+	for _, other := range candidates {
+		if other.Action == chosen || other.Skipped != "" || other.Dirt == 0 {
+			continue
+		}
+		switch {
+		case other.Dirt*best.Cost == best.Dirt*other.Cost && other.Dirt == best.Dirt:
+			return "same dirt per battery and dirt, first in up, down, left, right order"
+		case other.Dirt*best.Cost == best.Dirt*other.Cost:
+			rule = "same dirt per battery, more dirt"
+		case rule == "only dirty neighbor":
+			rule = "most dirt per battery"
+		}
+	}
+	return rule
+}
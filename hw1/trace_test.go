@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOptimalFirstDecision(t *testing.T) {
+	carpet := testState(20, 1, 1, "0,4", "12,0")
+	carpet.Terrain = [][]string{{"1", "1"}, {"3", "1"}}
+
+	tests := []struct {
+		name     string
+		state    InitialState
+		decision Decision // without the candidates
+		skipped  []string // of the up, down, left and right candidates
+	}{
+		{"most dirt per battery", testState(20, 1, 1, "0,4", "6,9001"),
+			Decision{Battery: 20, Branch: DECISION_NEIGHBOR, TargetX: 0, TargetY: 1, TargetDirt: 6, Distance: 1, Cost: 2,
+				TieBreak: "most dirt per battery"}, []string{"wall", "", "wall", ""}},
+		{"same dirt per battery, more dirt", carpet,
+			Decision{Battery: 20, Branch: DECISION_NEIGHBOR, TargetX: 0, TargetY: 1, TargetDirt: 12, Distance: 1, Cost: 6,
+				TieBreak: "same dirt per battery, more dirt"}, []string{"wall", "", "wall", ""}},
+		{"first in direction order", testState(20, 1, 1, "0,5", "5,0"),
+			Decision{Battery: 20, Branch: DECISION_NEIGHBOR, TargetX: 0, TargetY: 1, TargetDirt: 5, Distance: 1, Cost: 2,
+				TieBreak: "same dirt per battery and dirt, first in up, down, left, right order"}, []string{"wall", "", "wall", ""}},
+		{"only dirty neighbor", testState(20, 1, 1, "0,3", "0,0"),
+			Decision{Battery: 20, Branch: DECISION_NEIGHBOR, TargetX: 1, TargetY: 0, TargetDirt: 3, Distance: 1, Cost: 2,
+				TieBreak: "only dirty neighbor"}, []string{"wall", "", "wall", ""}},
+		{"search for the most dirt", testState(20, 1, 1, "0,0,7"),
+			Decision{Battery: 20, Branch: DECISION_SEARCH, TargetX: 2, TargetY: 0, TargetDirt: 7, Distance: 2, Cost: 3,
+				TieBreak: "most dirt on the map"}, []string{"wall", "wall", "wall", ""}},
+		{"search within the battery", testState(6, 1, 1, "0,0,5,0,0,0,0,9"),
+			Decision{Battery: 6, Branch: DECISION_SEARCH, TargetX: 2, TargetY: 0, TargetDirt: 5, Distance: 2, Cost: 3,
+				TieBreak: "most dirt within battery"}, []string{"wall", "wall", "wall", ""}},
+		{"search for the cheapest", testState(6, 1, 1, "0,0,5,0,5,0,0,0,9"),
+			Decision{Battery: 6, Branch: DECISION_SEARCH, TargetX: 2, TargetY: 0, TargetDirt: 5, Distance: 2, Cost: 3,
+				TieBreak: "same dirt, cheapest"}, []string{"wall", "wall", "wall", ""}},
+		{"stop", testState(1, 1, 1, "0,0,7"),
+			Decision{Battery: 1, Branch: DECISION_STOP, TargetX: -1, TargetY: -1}, []string{"wall", "wall", "wall", ""}},
+		{"battery for no move", testState(1, 2, 1, "0,5"),
+			Decision{Battery: 1, Branch: DECISION_STOP, TargetX: -1, TargetY: -1}, []string{"wall", "wall", "wall", "battery"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := testResult(t, "optimal", test.state, RunOptions{Explain: true})
+			if len(result.Decisions) == 0 {
+				t.Fatal("no decisions recorded")
+			}
+			decision := result.Decisions[0]
+
+			skipped := []string{}
+			for _, candidate := range decision.Candidates {
+				skipped = append(skipped, candidate.Skipped)
+			}
+			if !reflect.DeepEqual(skipped, test.skipped) {
+				t.Errorf("candidates %+v, want skipped %q", decision.Candidates, test.skipped)
+			}
+			decision.Candidates = nil
+			if !reflect.DeepEqual(decision, test.decision) {
+				t.Errorf("decided %+v, want %+v", decision, test.decision)
+			}
+		})
+	}
+}
+
+// Explaining records the decisions without changing the run, each where and when the agent was
+func TestExplainKeepsRun(t *testing.T) {
+	maps, err := filepath.Glob("inputs/*.csv")
+	if err != nil || len(maps) == 0 {
+		t.Fatalf("no sample maps: %v", err)
+	}
+
+	for _, path := range maps {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			state, err := LoadInitialState(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain := testResult(t, "optimal", state, RunOptions{})
+			explained := testResult(t, "optimal", state, RunOptions{Explain: true})
+
+			if len(plain.Decisions) != 0 {
+				t.Errorf("%d decisions recorded without --explain", len(plain.Decisions))
+			}
+			if !reflect.DeepEqual(plain.Steps, explained.Steps) {
+				t.Fatal("explaining changed the steps")
+			}
+			for i, decision := range explained.Decisions {
+				x, y, battery := explained.StartX, explained.StartY, explained.Battery
+				if decision.Step > 0 {
+					step := explained.Steps[decision.Step-1]
+					x, y, battery = step.X, step.Y, step.BatteryAfter
+				}
+				if decision.X != x || decision.Y != y || decision.Battery != battery {
+					t.Fatalf("decision %d at (%d, %d) with %d battery, the agent at (%d, %d) with %d",
+						i+1, decision.X, decision.Y, decision.Battery, x, y, battery)
+				}
+				if i > 0 && decision.Step <= explained.Decisions[i-1].Step {
+					t.Fatalf("decision %d at step %d after one at step %d", i+1, decision.Step, explained.Decisions[i-1].Step)
+				}
+			}
+		})
+	}
+}
+
+func TestNeighborTieBreak(t *testing.T) {
+	wall := DecisionCandidate{Action: ActionUp, Skipped: "wall"}
+	tests := []struct {
+		name       string
+		candidates []DecisionCandidate
+		chosen     Action
+		want       string
+	}{
+		{"alone", []DecisionCandidate{wall, {Action: ActionDown, Dirt: 3, Cost: 2}, {Action: ActionLeft, Cost: 2}}, ActionDown, "only dirty neighbor"},
+		{"better ratio", []DecisionCandidate{{Action: ActionDown, Dirt: 3, Cost: 2}, {Action: ActionRight, Dirt: 4, Cost: 4}}, ActionDown, "most dirt per battery"},
+		{"same ratio", []DecisionCandidate{{Action: ActionDown, Dirt: 3, Cost: 2}, {Action: ActionRight, Dirt: 6, Cost: 4}}, ActionRight, "same dirt per battery, more dirt"},
+		{"same everything", []DecisionCandidate{{Action: ActionDown, Dirt: 3, Cost: 2}, {Action: ActionRight, Dirt: 3, Cost: 2}}, ActionDown,
+			"same dirt per battery and dirt, first in up, down, left, right order"},
+		{"skipped dirt left out", []DecisionCandidate{{Action: ActionUp, Dirt: 9, Skipped: "battery"}, {Action: ActionDown, Dirt: 3, Cost: 2}}, ActionDown,
+			"only dirty neighbor"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := neighborTieBreak(test.candidates, test.chosen); got != test.want {
+				t.Errorf("tie-break %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecisionString(t *testing.T) {
+	candidates := []DecisionCandidate{{Action: ActionUp, Skipped: "wall"}, {Action: ActionRight, Dirt: 4, Cost: 2}}
+	tests := []struct {
+		name     string
+		decision Decision
+		want     string
+	}{
+		{"neighbor", Decision{Step: 2, X: 1, Y: 0, Battery: 9, Branch: DECISION_NEIGHBOR, Candidates: candidates,
+			TargetX: 2, TargetY: 0, TargetDirt: 4, Distance: 1, Cost: 2, TieBreak: "only dirty neighbor"},
+			"Step 2 at (1, 0), battery 9: neighbor (2, 0) with 4 dirt for 2 battery with vacuuming (only dirty neighbor); " +
+				"neighbors dirt/battery: up wall, right 4/2"},
+		{"search", Decision{Battery: 9, Branch: DECISION_SEARCH, Candidates: candidates, TargetX: 3, TargetY: 2,
+			TargetDirt: 7, Distance: 5, Cost: 6, TieBreak: "most dirt on the map"},
+			"Step 0 at (0, 0), battery 9: search to (3, 2) with 7 dirt, 5 moves for 6 battery with vacuuming (most dirt on the map); " +
+				"neighbors dirt/battery: up wall, right 4/2"},
+		{"stop", Decision{Step: 7, X: 4, Y: 4, Branch: DECISION_STOP, Candidates: []DecisionCandidate{}, TargetX: -1, TargetY: -1},
+			"Step 7 at (4, 4), battery 0: stop, no dirty tile within battery; neighbors dirt/battery: "},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.decision.String(); got != test.want {
+				t.Errorf("written as\n%q, want\n%q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecisionsWritten(t *testing.T) {
+	result := testResult(t, "optimal", testState(20, 1, 1, "0,4", "6,0"), RunOptions{Explain: true})
+	line := result.Decisions[0].String()
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"text", "Decisions:\n  " + line + "\n"},
+		{"csv", "# Decision: " + line + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			out := bytes.Buffer{}
+			if err := writeResult(&out, test.format, "optimal", "map.csv", result); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), test.want) {
+				t.Errorf("output\n%s\nwithout %q", out.String(), test.want)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		out := bytes.Buffer{}
+		if err := writeResult(&out, "json", "optimal", "map.csv", result); err != nil {
+			t.Fatal(err)
+		}
+		trajectory := jsonTrajectory{}
+		if err := json.Unmarshal(out.Bytes(), &trajectory); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(trajectory.Decisions, result.Decisions) {
+			t.Errorf("decisions read back as %+v, want %+v", trajectory.Decisions, result.Decisions)
+		}
+	})
+}
@@ -1,29 +1,73 @@
 package main
 
-// These Queue utilities were created using chatgpt.
-// Queue struct to manage BFS traversal
+// Queue is a ring buffer of flat tile indexes (y*width+x) to manage BFS traversal.
+// It doubles when full, so the slots of dequeued elements are used again.
 type Queue struct {
-	data [][2]int
+	data  []int
+	head  int
+	count int
 }
 
 // Enqueue adds an element to the queue
-func (q *Queue) Enqueue(val [2]int) {
-	q.data = append(q.data, val)
+func (q *Queue) Enqueue(val int) {
+	if q.count == len(q.data) {
+		grown := make([]int, 2*len(q.data)+16)
+		copied := copy(grown, q.data[q.head:])
+		copy(grown[copied:], q.data[:q.head])
+		q.data, q.head = grown, 0
+	}
+	q.data[(q.head+q.count)%len(q.data)] = val
+	q.count++
 }
 
 // Dequeue removes and returns the first element from the queue
-func (q *Queue) Dequeue() ([2]int, bool) {
-	if len(q.data) == 0 {
-		return [2]int{}, false
+func (q *Queue) Dequeue() (int, bool) {
+	if q.count == 0 {
+		return 0, false
 	}
-	val := q.data[0]
-	q.data = q.data[1:]
+	val := q.data[q.head]
+	q.head = (q.head + 1) % len(q.data)
+	q.count--
 	return val, true
 }
 
 // IsEmpty checks if the queue is empty
 func (q *Queue) IsEmpty() bool {
-	return len(q.data) == 0
+	return q.count == 0
+}
+
+// Tile waiting in Dijkstra's search
+type costItem struct {
+	tile  int // flat index like the Queue items
+	cost  int
+	steps int
+	seq   int // insertion order, keeps ties first in first out like the BFS Queue
+}
+
+// PriorityQueue is a min-heap of costItems for container/heap, ordered by cost, steps and insertion
+type PriorityQueue []costItem
+
+func (pq PriorityQueue) Len() int { return len(pq) }
+
+func (pq PriorityQueue) Less(i, j int) bool {
+	if pq[i].cost != pq[j].cost {
+		return pq[i].cost < pq[j].cost
+	}
+	if pq[i].steps != pq[j].steps {
+		return pq[i].steps < pq[j].steps
+	}
+	return pq[i].seq < pq[j].seq
+}
+
+func (pq PriorityQueue) Swap(i, j int) { pq[i], pq[j] = pq[j], pq[i] }
+
+func (pq *PriorityQueue) Push(item any) { *pq = append(*pq, item.(costItem)) }
+
+func (pq *PriorityQueue) Pop() any {
+	old := *pq
+	item := old[len(old)-1]
+	*pq = old[:len(old)-1]
+	return item
 }
 
 // TODO: remove this since it turned out to be unnecessary
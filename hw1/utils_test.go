@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestQueueWraparound(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  string // 'e' enqueues the next number, 'd' dequeues and expects the oldest one left
+	}{
+		{"empty", "d"},
+		{"in order", "eeeddd"},
+		{"wraps before growing", "eeeeeeeeeeddddddddeeeeeeeeeeeedddddddddddddddd"},
+		{"grows while wrapped", "eeeeeeeeeeeeeeeedddddddddd" + "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee" + "ddddddddddddddddddddddddddddddddddddddddddd"},
+		{"alternating", "ededededededededededededededededededed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := Queue{}
+			next, oldest := 0, 0
+			for i, op := range test.ops {
+				if op == 'e' {
+					q.Enqueue(next)
+					next++
+					continue
+				}
+
+				val, ok := q.Dequeue()
+				if oldest == next {
+					if ok {
+						t.Fatalf("op %d: dequeued %d from an empty queue", i, val)
+					}
+					continue
+				}
+				if !ok || val != oldest {
+					t.Fatalf("op %d: got %d, %v, want %d", i, val, ok, oldest)
+				}
+				oldest++
+			}
+			if q.IsEmpty() != (oldest == next) {
+				t.Errorf("IsEmpty() = %v with %d elements left", q.IsEmpty(), next-oldest)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerCommand("validate", "<input map>...",
+		"Checks input maps and lists every problem with its line and column",
+		runValidate)
+}
+
+// Problem found in an input map. Line and Column are 0 when the map was not read from a file.
+type ValidationIssue struct {
+	Source  string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (issue ValidationIssue) String() string {
+	if issue.Line == 0 && issue.Source == "" {
+		return issue.Message
+	}
+	if issue.Line == 0 {
+		return fmt.Sprintf("%s: %s", issue.Source, issue.Message)
+	}
+	if issue.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s", issue.Source, issue.Line, issue.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", issue.Source, issue.Line, issue.Column, issue.Message)
+}
+
+// ValidateInitialState returns every problem of the map: negative battery, costs, charge rate or sensor radius, ragged rows,
+// tiles that are not integers, negative or above WALL_VALUE (other than DOCK_VALUE), a start outside the grid
+// or on a wall, a required return to a dock on a map without docks, terrain or regrowth layers that do not match
+// the grid or have invalid cells, further robots starting off the floor or on another robot, a negative horizon,
+// a move success that is not a chance
+// and dirt events before the first step, off the floor or with invalid dirt.
+func ValidateInitialState(initialState InitialState) []ValidationIssue {
+	issues := []ValidationIssue{}
+
+	settingIssue := func(setting int, message string) {
+		issues = append(issues, ValidationIssue{Source: initialState.source, Line: initialState.settingLines[setting], Message: message})
+	}
+	tileIssue := func(x int, y int, message string) {
+		issue := ValidationIssue{Source: initialState.source, Message: fmt.Sprintf("tile (%d, %d): %s", x, y, message)}
+		if y < len(initialState.tilePositions) && x < len(initialState.tilePositions[y]) {
+			issue.Line, issue.Column = initialState.tilePositions[y][x][0], initialState.tilePositions[y][x][1]
+		}
+		issues = append(issues, issue)
+	}
+
+	if initialState.Battery < 0 {
+		settingIssue(2, fmt.Sprintf("starting battery is negative (%d)", initialState.Battery))
+	}
+	if initialState.MovementCost < 0 {
+		settingIssue(3, fmt.Sprintf("movement cost is negative (%d)", initialState.MovementCost))
+	}
+	if initialState.VacuumingCost < 0 {
+		settingIssue(4, fmt.Sprintf("vacuuming cost is negative (%d)", initialState.VacuumingCost))
+	}
+
+	if len(initialState.Tiles) == 0 {
+		settingIssue(4, "the map has no tile rows after the five settings")
+		return issues
+	}
+
+	width := len(initialState.Tiles[0])
+	docks := 0
+	for y, row := range initialState.Tiles {
+		if len(row) != width {
+			issue := ValidationIssue{Source: initialState.source,
+				Message: fmt.Sprintf("row %d has %d tiles, the first row has %d", y, len(row), width)}
+			if y < len(initialState.tilePositions) && len(initialState.tilePositions[y]) > 0 {
+				issue.Line = initialState.tilePositions[y][0][0]
+			}
+			issues = append(issues, issue)
+		}
+
+		for x, tile := range row {
+			value, err := strconv.Atoi(strings.TrimSpace(tile))
+			if err != nil {
+				tileIssue(x, y, fmt.Sprintf("%q is not an integer", strings.TrimSpace(tile)))
+			} else if value < 0 {
+				tileIssue(x, y, fmt.Sprintf("negative value %d", value))
+			} else if value > WALL_VALUE && value != DOCK_VALUE {
+				tileIssue(x, y, fmt.Sprintf("value %d is above %d, it is neither dirt, a wall nor a dock (%d)", value, WALL_VALUE, DOCK_VALUE))
+			} else if value == DOCK_VALUE {
+				docks++
+			}
+		}
+	}
+
+	for _, layer := range []struct {
+		name      string
+		cells     [][]string
+		positions [][][2]int
+		parse     func(string) ([2]int, error)
+	}{
+		{"terrain", initialState.Terrain, initialState.terrainPositions, parseTerrain},
+		{"regrowth", initialState.Regrowth, initialState.regrowthPositions, parseRegrowth},
+	} {
+		if layer.cells == nil {
+			continue
+		}
+
+		layerIssue := func(x int, y int, message string) {
+			issue := ValidationIssue{Source: initialState.source, Message: fmt.Sprintf("%s (%d, %d): %s", layer.name, x, y, message)}
+			if y < len(layer.positions) && x < len(layer.positions[y]) {
+				issue.Line, issue.Column = layer.positions[y][x][0], layer.positions[y][x][1]
+			}
+			issues = append(issues, issue)
+		}
+
+		if len(layer.cells) != len(initialState.Tiles) {
+			issues = append(issues, ValidationIssue{Source: initialState.source,
+				Message: fmt.Sprintf("%s has %d rows, the grid has %d", layer.name, len(layer.cells), len(initialState.Tiles))})
+		}
+		for y, row := range layer.cells {
+			if y < len(initialState.Tiles) && len(row) != len(initialState.Tiles[y]) {
+				layerIssue(0, y, fmt.Sprintf("row has %d cells, the grid row has %d", len(row), len(initialState.Tiles[y])))
+			}
+			for x, cell := range row {
+				if _, err := layer.parse(cell); err != nil {
+					layerIssue(x, y, err.Error())
+				}
+			}
+		}
+	}
+
+	if initialState.SensorRadius < 0 {
+		settingIssue(7, fmt.Sprintf("sensor radius is negative (%d)", initialState.SensorRadius))
+	}
+	if initialState.MoveSuccess < 0 || initialState.MoveSuccess > 1 {
+		settingIssue(9, fmt.Sprintf("move success %g is not a chance between 0 and 1", initialState.MoveSuccess))
+	}
+	if initialState.Horizon < 0 {
+		settingIssue(8, fmt.Sprintf("horizon is negative (%d)", initialState.Horizon))
+	}
+	if initialState.ChargeRate < 0 {
+		settingIssue(5, fmt.Sprintf("charge rate is negative (%d)", initialState.ChargeRate))
+	}
+	if initialState.MustEndOnDock && docks == 0 {
+		settingIssue(6, fmt.Sprintf("the run has to end on a dock but the map has no dock (%d) tiles", DOCK_VALUE))
+	}
+
+	x, y := initialState.X0, initialState.Y0
+	row := initialState.Tiles[0]
+	if y < 0 || y >= len(initialState.Tiles) {
+		settingIssue(1, fmt.Sprintf("start y %d is outside the grid of %d rows", y, len(initialState.Tiles)))
+	} else {
+		row = initialState.Tiles[y]
+	}
+	if x < 0 || x >= len(row) {
+		settingIssue(0, fmt.Sprintf("start x %d is outside the grid of %d columns", x, len(row)))
+	} else if y >= 0 && y < len(initialState.Tiles) && isWallTile(row[x]) {
+		settingIssue(0, fmt.Sprintf("start (%d, %d) is on a wall", x, y))
+	}
+
+	starts := map[[2]int]int{{x, y}: 1}
+	for i, robot := range initialState.Robots {
+		issue := ValidationIssue{Source: initialState.source}
+		if i < len(initialState.robotLines) {
+			issue.Line = initialState.robotLines[i]
+		}
+		robotIssue := func(message string) {
+			issue.Message = fmt.Sprintf("robot %d: %s", i+2, message)
+			issues = append(issues, issue)
+		}
+
+		x, y := robot.X, robot.Y
+		if robot.Battery < 0 {
+			robotIssue(fmt.Sprintf("starting battery is negative (%d)", robot.Battery))
+		}
+		if y < 0 || y >= len(initialState.Tiles) || x < 0 || x >= len(initialState.Tiles[y]) {
+			robotIssue(fmt.Sprintf("start (%d, %d) is outside the grid", x, y))
+		} else if isWallTile(initialState.Tiles[y][x]) {
+			robotIssue(fmt.Sprintf("start (%d, %d) is on a wall", x, y))
+		} else if other, taken := starts[[2]int{x, y}]; taken {
+			robotIssue(fmt.Sprintf("start (%d, %d) is taken by robot %d", x, y, other))
+		}
+		if _, taken := starts[[2]int{x, y}]; !taken {
+			starts[[2]int{x, y}] = i + 2
+		}
+	}
+
+	for i, event := range initialState.Events {
+		issue := ValidationIssue{Source: initialState.source}
+		if i < len(initialState.eventLines) {
+			issue.Line = initialState.eventLines[i]
+		}
+		eventIssue := func(message string) {
+			issue.Message = fmt.Sprintf("event %d: %s", i+1, message)
+			issues = append(issues, issue)
+		}
+
+		x, y := event.X, event.Y
+		if event.Step < 1 {
+			eventIssue(fmt.Sprintf("step %d is before the first step", event.Step))
+		}
+		if event.Dirt < 1 || event.Dirt >= WALL_VALUE {
+			eventIssue(fmt.Sprintf("dirt %d is not between 1 and %d", event.Dirt, WALL_VALUE-1))
+		}
+		if y < 0 || y >= len(initialState.Tiles) || x < 0 || x >= len(initialState.Tiles[y]) {
+			eventIssue(fmt.Sprintf("tile (%d, %d) is outside the grid", x, y))
+		} else if value, err := strconv.Atoi(strings.TrimSpace(initialState.Tiles[y][x])); err == nil && value >= WALL_VALUE {
+			eventIssue(fmt.Sprintf("tile (%d, %d) is a wall or a dock", x, y))
+		}
+	}
+
+	return issues
+}
+
+// Joins all issues into one error, nil for a valid map
+func validationError(issues []ValidationIssue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+
+	lines := []string{fmt.Sprintf("Invalid map, %d problems:", len(issues))}
+	for _, issue := range issues {
+		lines = append(lines, "  "+issue.String())
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+// ReadValidInitialState reads the map (format "" detects it from the extension)
+// and fails with every validation problem found
+func ReadValidInitialState(filePath string, format string) (InitialState, error) {
+	initialState, err := LoadInitialState(filePath, format)
+	if err != nil {
+		return initialState, err
+	}
+	return initialState, validationError(ValidateInitialState(initialState))
+}
+
+func runValidate(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return commandUsage("validate")
+	}
+
+	invalid := 0
+	for _, filePath := range flags.Args() {
+		initialState, err := LoadInitialState(filePath, "")
+		if err != nil {
+			fmt.Println(err)
+			invalid++
+			continue
+		}
+
+		issues := ValidateInitialState(initialState)
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		if len(issues) > 0 {
+			invalid++
+		} else {
+			fmt.Printf("%s: OK\n", filePath)
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d maps are invalid", invalid, flags.NArg())
+	}
+	return nil
+}
+
+// Tile field holding WALL_VALUE, however it is padded
+func isWallTile(tile string) bool {
+	value, err := strconv.Atoi(strings.TrimSpace(tile))
+	return err == nil && value == WALL_VALUE
+}
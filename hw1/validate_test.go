@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestValidateStart(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  string
+		row    string
+		issues []string // line and message of every issue
+	}{
+		{"on the floor", "1\n0", "0, 5, 0", nil},
+		{"on a wall", "1\n0", "0, 9001, 0", []string{"1:start (1, 0) is on a wall"}},
+		{"on a padded wall", "1\n0", "0,09001,0", []string{"1:start (1, 0) is on a wall"}},
+		{"x outside", "3\n0", "0, 5, 0", []string{"1:start x 3 is outside the grid of 3 columns"}},
+		{"y outside", "0\n-1", "0, 5, 0", []string{"2:start y -1 is outside the grid of 1 rows"}},
+		{"both outside", "-1\n4", "0, 5, 0", []string{"2:start y 4 is outside", "1:start x -1 is outside"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "map.csv")
+			if err := os.WriteFile(path, []byte(test.start+"\n10\n1\n1\n"+test.row+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			initialState, err := LoadInitialState(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			issues := ValidateInitialState(initialState)
+			if len(issues) != len(test.issues) {
+				t.Fatalf("got issues %v, want %v", issues, test.issues)
+			}
+			for i, issue := range issues {
+				line, message, _ := strings.Cut(test.issues[i], ":")
+				if strconv.Itoa(issue.Line) != line || !strings.Contains(issue.Message, message) {
+					t.Errorf("issue %q, want %q", issue, test.issues[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func init() {
+	registerCommand("verify", "[--move-success=P] [--seed=N] <input map> <actions file>",
+		"Replays an action sequence with the simulator rules and reports the score and illegal steps",
+		runVerify)
+}
+
+// Action read from a plan file together with where it came from
+type PlannedAction struct {
+	Action Action
+	Line   int
+}
+
+// Step the simulator refused to perform
+type StepIssue struct {
+	Step   int // 1-based index in the plan, 0 for problems with the start
+	Line   int
+	Action Action
+	Reason string
+}
+
+func (issue StepIssue) String() string {
+	if issue.Step == 0 {
+		return fmt.Sprintf("Start: %s", issue.Reason)
+	}
+	return fmt.Sprintf("Step %d (line %d, %s): %s", issue.Step, issue.Line, issue.Action, issue.Reason)
+}
+
+// ParseAction accepts full action names or their first letter, case-insensitive
+func ParseAction(text string) (Action, error) {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "up", "u":
+		return ActionUp, nil
+	case "down", "d":
+		return ActionDown, nil
+	case "left", "l":
+		return ActionLeft, nil
+	case "right", "r":
+		return ActionRight, nil
+	case "vacuum", "v":
+		return ActionVacuum, nil
+	case "charge", "c":
+		return ActionCharge, nil
+	case "wait", "w":
+		return ActionWait, nil
+	}
+	return "", fmt.Errorf("Unknown action %q", text)
+}
+
+// ReadActions reads a plan from one of:
+// - plain text, one action per line ('#' starts a comment),
+// - the --output=csv trajectory (the action column is used, the start row is skipped),
+// - the --output=json trajectory.
+func ReadActions(filePath string) ([]PlannedAction, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file %s: %v", filePath, err)
+	}
+
+	actions := []PlannedAction{}
+
+	if strings.HasPrefix(strings.TrimSpace(string(content)), "{") {
+		trajectory := jsonTrajectory{}
+		if err := json.Unmarshal(content, &trajectory); err != nil {
+			return nil, fmt.Errorf("Error parsing JSON trajectory %s: %v", filePath, err)
+		}
+		for i, step := range trajectory.Steps {
+			action, err := ParseAction(string(step.Action))
+			if err != nil {
+				return nil, fmt.Errorf("Error in %s, step %d: %v", filePath, i+1, err)
+			}
+			actions = append(actions, PlannedAction{Action: action, Line: i + 1})
+		}
+		return actions, nil
+	}
+
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(strings.Split(line, "#")[0])
+		if line == "" {
+			continue
+		}
+
+		if strings.Contains(line, ",") {
+			// step,action,x,y,... rows of the CSV trajectory
+			fields := strings.Split(line, ",")
+			line = strings.TrimSpace(fields[1])
+			if line == "action" || line == "start" {
+				continue
+			}
+		}
+
+		action, err := ParseAction(line)
+		if err != nil {
+			return nil, fmt.Errorf("Error in %s, line %d: %v", filePath, i+1, err)
+		}
+		actions = append(actions, PlannedAction{Action: action, Line: i + 1})
+	}
+
+	return actions, nil
+}
+
+// VerifyPlan replays the actions with the Agent rules. Illegal steps are reported and skipped,
+// the agent stays where it was, just like the simulator refuses such moves.
+func VerifyPlan(initialState InitialState, options RunOptions, actions []PlannedAction) (PlanResult, []StepIssue, error) {
+	options.StepLimit = -1 // a plan is checked in full, however long
+	agent, err := CreateAgent(initialState, options)
+	if err != nil {
+		return PlanResult{}, nil, err
+	}
+
+	issues := []StepIssue{}
+	if reason := agent.illegalPosition(agent.posX, agent.posY); reason != "" {
+		issues = append(issues, StepIssue{Reason: fmt.Sprintf("position (%d, %d) %s", agent.posX, agent.posY, reason)})
+		return agent.result(), issues, nil
+	}
+
+	for i, planned := range actions {
+		reason := ""
+		if agent.outOfTime() {
+			reason = fmt.Sprintf("taken after the horizon of %d steps", agent.env.horizon)
+		} else if planned.Action == ActionCharge {
+			if !agent.onDock() {
+				reason = fmt.Sprintf("charging at (%d, %d) which is not a dock", agent.posX, agent.posY)
+			}
+		} else if planned.Action == ActionVacuum {
+			dirt := agent.currentTile()
+			if cost := agent.vacuumCostAt(agent.posX, agent.posY); dirt > 0 && dirt < WALL_VALUE && agent.battery < cost {
+				reason = fmt.Sprintf("battery exhausted, vacuuming needs %d, %d left", cost, agent.battery)
+			}
+		} else if planned.Action != ActionWait {
+			dx, dy := actionDelta(planned.Action)
+			x, y := agent.posX+dx, agent.posY+dy
+			if reason = agent.illegalPosition(x, y); reason != "" {
+				reason = fmt.Sprintf("moves to (%d, %d) %s", x, y, reason)
+			} else if cost := agent.moveCostTo(x, y); agent.battery < cost {
+				reason = fmt.Sprintf("battery exhausted, moving needs %d, %d left", cost, agent.battery)
+			}
+		}
+
+		if reason != "" {
+			issues = append(issues, StepIssue{Step: i + 1, Line: planned.Line, Action: planned.Action, Reason: reason})
+			continue
+		}
+
+		agent.perform(planned.Action)
+	}
+
+	if agent.mustEndOnDock && !agent.onDock() {
+		issue := StepIssue{Reason: fmt.Sprintf("the run has to end on a dock but ends at (%d, %d)", agent.posX, agent.posY)}
+		if len(actions) > 0 {
+			last := actions[len(actions)-1]
+			issue.Step, issue.Line, issue.Action = len(actions), last.Line, last.Action
+		}
+		issues = append(issues, issue)
+	}
+
+	return agent.result(), issues, nil
+}
+
+// Why the agent cannot stand on (x, y), empty when it can
+func (agent *Agent) illegalPosition(x int, y int) string {
+	if y < 0 || y >= len(agent.tiles) || x < 0 || x >= len(agent.tiles[y]) {
+		return "which is outside the grid"
+	}
+	if agent.tiles[y][x] == WALL_VALUE {
+		return "which is a wall"
+	}
+	return ""
+}
+
+func runVerify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	moveSuccess := flags.Float64("move-success", -1, "Chance a move succeeds on a slippery floor (default from the map)")
+	seed := flags.Int64("seed", 1, "Seed of the slips, the one the plan was made with")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return commandUsage("verify")
+	}
+
+	initialState, err := ReadValidInitialState(flags.Arg(0), "")
+	if err != nil {
+		return err
+	}
+	if *moveSuccess >= 0 {
+		initialState.MoveSuccess = *moveSuccess
+	}
+
+	actions, err := ReadActions(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	result, issues, err := VerifyPlan(initialState, RunOptions{Seed: *seed}, actions)
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	result.printStatistics(os.Stdout)
+
+	if len(issues) > 0 {
+		return fmt.Errorf("Plan has %d illegal steps", len(issues))
+	}
+	fmt.Println("Plan is legal")
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyPlan(t *testing.T) {
+	endOnDock := testState(5, 1, 1, "0,9002,5")
+	endOnDock.MustEndOnDock = true
+
+	tests := []struct {
+		name        string
+		state       InitialState
+		plan        string // actions as in a plan file, separated by spaces
+		issues      map[int]string
+		dirt        int
+		battery     int
+		termination string
+	}{
+		{"legal", testState(5, 1, 1, "0,5"), "r v", nil, 5, 3, TERMINATION_CLEAN},
+		{"into a wall", testState(5, 1, 1, "0,9001,5", "0,0,0"), "r d", map[int]string{1: "which is a wall"}, 0, 4, TERMINATION_STOPPED},
+		{"off the grid", testState(5, 1, 1, "0,5"), "u l r v", map[int]string{1: "outside the grid", 2: "outside the grid"}, 5, 3, TERMINATION_CLEAN},
+		{"battery for a move", testState(1, 2, 1, "0,5"), "r", map[int]string{1: "moving needs 2, 1 left"}, 0, 1, TERMINATION_BATTERY},
+		{"battery for vacuuming", testState(1, 1, 2, "0,5"), "r v", map[int]string{2: "vacuuming needs 2, 0 left"}, 0, 0, TERMINATION_BATTERY},
+		{"charge off a dock", testState(5, 1, 1, "0,5"), "c r v", map[int]string{1: "not a dock"}, 5, 3, TERMINATION_CLEAN},
+		{"stops with battery left", testState(9, 1, 1, "0,0,0,7"), "r", nil, 0, 8, TERMINATION_STOPPED},
+		{"ends off the dock", endOnDock, "r r v", map[int]string{3: "has to end on a dock"}, 5, 2, TERMINATION_CLEAN},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actions := []PlannedAction{}
+			for i, field := range strings.Fields(test.plan) {
+				action, err := ParseAction(field)
+				if err != nil {
+					t.Fatal(err)
+				}
+				actions = append(actions, PlannedAction{Action: action, Line: i + 1})
+			}
+
+			result, issues, err := VerifyPlan(test.state, RunOptions{}, actions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(issues) != len(test.issues) {
+				t.Errorf("got issues %v, want %d", issues, len(test.issues))
+			}
+			for _, issue := range issues {
+				if want, ok := test.issues[issue.Step]; !ok || !strings.Contains(issue.Reason, want) {
+					t.Errorf("unexpected issue %q", issue)
+				}
+			}
+			if result.DirtCleaned != test.dirt || result.BatteryLeft != test.battery || result.Termination != test.termination {
+				t.Errorf("got dirt %d, battery %d, %q, want %d, %d, %q", result.DirtCleaned, result.BatteryLeft,
+					result.Termination, test.dirt, test.battery, test.termination)
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 # Just a simple script to visualize the path of the agent in the grid environment.
 # Usage: go run . optimal input.csv | python visualize.py input.csv
+#    or: go run . --output=json optimal input.csv | python visualize.py input.csv
 # Generated using ChatGPT
 import sys
 import numpy as np
 import csv
 import ast
+import json
 import matplotlib.pyplot as plt
 import matplotlib.colors as mcolors
 import random
@@ -28,10 +30,19 @@ def read_csv(file_path):
     return grid, (start_x, start_y), battery, movement_cost, cleaning_cost
 
 def parse_stdin():
-    """ Reads the agent's movement path from stdin. """
+    """ Reads the agent's movement path from stdin (text output or --output=json). """
     path = []
+    text = sys.stdin.read()
 
-    for line in sys.stdin:
+    if text.lstrip().startswith("{"):
+        trajectory = json.loads(text)
+        path.append((trajectory["start"]["y"], trajectory["start"]["x"]))
+        for step in trajectory["steps"]:
+            if step["action"] != "vacuum":
+                path.append((step["y"], step["x"]))
+        return path
+
+    for line in text.splitlines():
         line = line.strip()
         
         if line.startswith("Moved to"):